@@ -0,0 +1,87 @@
+package cxx
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// cppcheckTemplate makes cppcheck report its findings in the same form as compilers and clang-tidy do
+const cppcheckTemplate = "{file}:{line}:{column}: {severity}: {message} [{id}]"
+
+// analyzeFinding matches a finding in the output of cppcheck, with the template above
+var analyzeFinding = regexp.MustCompile(`^(.+?):\d+:\d+: (error|warning|style|performance|portability|information): `)
+
+// cppcheckStd returns the --std= flag for cppcheck, which only knows about the c++ standards
+func cppcheckStd(std string) string {
+	v := strings.TrimPrefix(strings.TrimPrefix(std, "gnu++"), "c++")
+	if _, err := strconv.Atoi(v); err != nil {
+		return ""
+	}
+	return "--std=c++" + v
+}
+
+// cppcheckArgs returns the arguments for cppcheck, with the include directories and the defines
+// that the sources are built with
+func cppcheckArgs(o *Options) []string {
+	args := []string{"--quiet", "--inline-suppr", "--enable=warning,style,performance,portability",
+		"--suppress=missingIncludeSystem", "--template=" + cppcheckTemplate, "-j", strconv.Itoa(max(o.Jobs, 1))}
+	if std := cppcheckStd(o.Std); std != "" {
+		args = append(args, std)
+	}
+	for _, d := range o.IncludeDirs {
+		if dirExists(d) {
+			args = append(args, "-I"+d)
+		}
+	}
+	for _, f := range o.ExtraCFlags {
+		if strings.HasPrefix(f, "-D") || strings.HasPrefix(f, "-U") {
+			args = append(args, f)
+		}
+	}
+	return append(args, lintSources(o)...)
+}
+
+// runAnalyze runs cppcheck on the sources and prints its findings, followed by how many there are
+// of every severity. Errors make it fail, so that it can be used for stopping a build in CI.
+func runAnalyze(o *Options) error {
+	if !haveCmd("cppcheck") {
+		return fmt.Errorf("cppcheck was not found, install it with: %s", installSuggestion(o.DetectedDistro, "cppcheck"))
+	}
+	args := cppcheckArgs(o)
+	fmt.Println("cppcheck " + strings.Join(args, " "))
+	var findings bytes.Buffer
+	cmd := exec.Command("cppcheck", args...)
+	cmd.Stdout = os.Stdout
+	// The findings are written to stderr
+	cmd.Stderr = &findings
+	if err := cmd.Run(); err != nil {
+		os.Stderr.Write(findings.Bytes())
+		return fmt.Errorf("cppcheck failed: %w", err)
+	}
+	recordDiagnostics(o, "cppcheck", findings.String())
+	severities := map[string]int{}
+	for _, line := range strings.Split(strings.TrimSpace(findings.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		fmt.Println(line)
+		if m := analyzeFinding.FindStringSubmatch(line); m != nil {
+			severities[m[2]]++
+		}
+	}
+	if len(severities) == 0 {
+		fmt.Println("No findings")
+		return nil
+	}
+	fmt.Println("Findings per severity:")
+	printCounts(severities)
+	if n := severities["error"]; n > 0 {
+		return fmt.Errorf("cppcheck found %d error(s)", n)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+)
+
+const (
+	// defaultAndroidABI is the ABI that is built for when no --abi= is given
+	defaultAndroidABI = "arm64-v8a"
+	// defaultAndroidAPI is the minimum API level when no --api= is given
+	defaultAndroidAPI = 24
+)
+
+// androidABIs maps from the Android ABI names to the target triples of the NDK clang
+var androidABIs = map[string]string{
+	"arm64-v8a":   "aarch64-linux-android",
+	"armeabi-v7a": "armv7a-linux-androideabi",
+	"x86":         "i686-linux-android",
+	"x86_64":      "x86_64-linux-android",
+}
+
+// androidNDKPath returns the NDK in $ANDROID_NDK_HOME or $ANDROID_NDK_ROOT, or "" if neither is set
+func androidNDKPath() string {
+	for _, env := range []string{"ANDROID_NDK_HOME", "ANDROID_NDK_ROOT"} {
+		if dir := os.Getenv(env); dir != "" {
+			return dir
+		}
+	}
+	return ""
+}
+
+// androidToolchain returns the prebuilt LLVM toolchain of the NDK for the host.
+// The NDK only ships x86_64 toolchains, which also run on arm64 macOS.
+func androidToolchain(ndk string) string {
+	return filepath.Join(ndk, "toolchains", "llvm", "prebuilt", runtime.GOOS+"-x86_64")
+}
+
+// setupAndroid switches to the NDK clang++ for the ABI and API level, with the NDK sysroot.
+// The C++ standard library is linked statically, so that the result can be pushed with adb as it is.
+// The rest of the cross compilation setup is done by setupCross.
+func setupAndroid(o *Options) error {
+	if !o.Android {
+		return nil
+	}
+	if o.Wasm || o.Wasi || o.Target != "" || o.CrossPreset != "" {
+		return fmt.Errorf("--android can not be combined with other cross compilation options")
+	}
+	ndk := androidNDKPath()
+	if ndk == "" {
+		return fmt.Errorf("set ANDROID_NDK_HOME to where the Android NDK is installed")
+	}
+	if o.AndroidABI == "" {
+		o.AndroidABI = defaultAndroidABI
+	}
+	if o.AndroidAPI == 0 {
+		o.AndroidAPI = defaultAndroidAPI
+	}
+	triple, ok := androidABIs[o.AndroidABI]
+	if !ok {
+		return fmt.Errorf("unknown ABI %s, use one of arm64-v8a, armeabi-v7a, x86 and x86_64", o.AndroidABI)
+	}
+	toolchain := androidToolchain(ndk)
+	o.CXX = filepath.Join(toolchain, "bin", "clang++")
+	if !fileExists(o.CXX) {
+		return fmt.Errorf("%s was not found, ANDROID_NDK_HOME should point to NDK r19 or later", o.CXX)
+	}
+	if o.Sysroot == "" {
+		o.Sysroot = filepath.Join(toolchain, "sysroot")
+	}
+	// The API level is a part of the target, like aarch64-linux-android24
+	o.Target = triple + strconv.Itoa(o.AndroidAPI)
+	o.ExtraLDFlags = append(o.ExtraLDFlags, "-static-libstdc++")
+	return nil
+}
+
+// androidSystemIncludeDirs returns the include directories of the NDK sysroot
+func androidSystemIncludeDirs(o *Options) []string {
+	return []string{
+		filepath.Join(o.Sysroot, "usr", "include"),
+		filepath.Join(o.Sysroot, "usr", "include", androidABIs[o.AndroidABI]),
+	}
+}
+
+// androidLibraryName returns the name of a shared library for Android, which does not use versioned sonames
+func androidLibraryName(name string) string {
+	return "lib" + name + ".so"
+}
+
+// printAdbUsage shows how the executable can be pushed to a device and run
+func printAdbUsage(exe string) {
+	remote := "/data/local/tmp/" + filepath.Base(exe)
+	fmt.Printf("Run it on a device with: adb push %s %s && adb shell %s\n", exe, remote, remote)
+}
@@ -0,0 +1,66 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// escapeWorkflowData escapes the message of a GitHub Actions workflow command
+func escapeWorkflowData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeWorkflowProperty escapes a property of a GitHub Actions workflow command
+func escapeWorkflowProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// workflowPath returns the given path relative to the repository that GitHub Actions checked out, since
+// that is what annotations refer to, if it is in it
+func workflowPath(file string) string {
+	ws := os.Getenv("GITHUB_WORKSPACE")
+	if ws == "" {
+		return filepath.ToSlash(file)
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return filepath.ToSlash(file)
+	}
+	if rel, err := filepath.Rel(ws, abs); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(file)
+}
+
+// annotate prints the given diagnostics of the given tool as GitHub Actions workflow commands, so that they
+// are shown next to the code in pull requests
+func annotate(o *Options, tool string, ds []diagnostic) {
+	s := o.state
+	s.annotatedMu.Lock()
+	defer s.annotatedMu.Unlock()
+	if s.annotated == nil {
+		s.annotated = map[string]bool{}
+	}
+	for _, d := range ds {
+		if s.annotated[d.key()] {
+			continue
+		}
+		s.annotated[d.key()] = true
+		command := d.level
+		if command == "note" {
+			command = "notice"
+		}
+		title := tool
+		if d.rule != "" {
+			title += " " + d.rule
+		}
+		props := "file=" + escapeWorkflowProperty(workflowPath(d.file)) + ",line=" + fmt.Sprint(d.line)
+		if d.column > 0 {
+			props += ",col=" + fmt.Sprint(d.column)
+		}
+		props += ",title=" + escapeWorkflowProperty(title)
+		fmt.Printf("::%s %s::%s\n", command, props, escapeWorkflowData(d.message))
+	}
+}
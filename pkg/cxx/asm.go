@@ -0,0 +1,70 @@
+package cxx
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// isAsmSource checks if the source is assembly: GNU assembly in .s, or .S that is preprocessed first,
+// which are built with the compiler, or NASM assembly in .asm
+func isAsmSource(s string) bool {
+	switch strings.ToLower(filepath.Ext(s)) {
+	case ".s", ".asm":
+		return true
+	}
+	return false
+}
+
+// isNASMSource checks if the source is NASM assembly, which is built with nasm
+func isNASMSource(s string) bool {
+	return strings.ToLower(filepath.Ext(s)) == ".asm"
+}
+
+// nasmFormat returns the nasm output format for the platform that is built for,
+// unless another format is configured
+func nasmFormat(o *Options) string {
+	switch {
+	case o.NASMFormat != "":
+		return o.NASMFormat
+	case o.Win64Docker || runtime.GOOS == "windows":
+		return "win64"
+	case runtime.GOOS == "darwin":
+		return "macho64"
+	}
+	return "elf64"
+}
+
+// hasSources checks if any of the sources match, unless they also match the exception
+func hasSources(srcs []string, match func(string) bool, except ...func(string) bool) bool {
+	for _, s := range srcs {
+		if match(s) && (len(except) == 0 || !except[0](s)) {
+			return true
+		}
+	}
+	return false
+}
+
+// setupAsm checks that the assembly sources can be built with the selected compiler,
+// and that nasm is there when there are .asm sources
+func setupAsm(o *Options) error {
+	gnu := hasSources(o.Sources, isAsmSource, isNASMSource)
+	nasm := hasSources(o.Sources, isNASMSource)
+	if gnu && isMSVC(o) {
+		return fmt.Errorf("GNU assembly sources can not be built with msvc")
+	}
+	if (gnu || nasm) && (o.Wasm || o.Wasi) {
+		return fmt.Errorf("assembly sources can not be built for WebAssembly")
+	}
+	if nasm && !haveCmd("nasm") {
+		return fmt.Errorf("nasm is needed for the .asm sources, install it with: %s", installSuggestion(o.DetectedDistro, "nasm"))
+	}
+	return nil
+}
+
+// nasmCompileCmd returns the nasm command for assembling the given source into the given object file.
+// The include directories and the -D defines are passed on, since nasm takes them in the same form.
+func nasmCompileCmd(o *Options, src, obj string) string {
+	return joinNonEmpty([]string{"nasm", "-f", nasmFormat(o), includeFlags(o), defineFlags(o), src, "-o", obj})
+}
@@ -0,0 +1,99 @@
+package cxx
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// baselineFilename is the file with the warnings that are accepted in strict mode, which is written by cxx2 baseline
+const baselineFilename = ".cxx2-warnings-baseline"
+
+// baselineShown is how many of the warnings that are not in the baseline are listed in the error
+const baselineShown = 5
+
+// baselineKey returns how the warning is written in the baseline file. The line and column are left out,
+// so that the warnings are still found in the baseline after the code around them has changed.
+func baselineKey(d diagnostic) string {
+	file, _ := sarifURI(d.file)
+	return file + "\t" + d.rule + "\t" + d.message
+}
+
+// loadBaseline reads the baseline file, if there is one and the build is in strict mode
+func loadBaseline(o *Options) map[string]bool {
+	if !o.Strict || o.Baseline {
+		return nil
+	}
+	f, err := os.Open(baselineFilename)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	keys := make(map[string]bool)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if line := sc.Text(); line != "" && !strings.HasPrefix(line, "#") {
+			keys[line] = true
+		}
+	}
+	return keys
+}
+
+// checkBaseline returns an error if the given compiler output has warnings that are not in the baseline
+func checkBaseline(o *Options, output string) error {
+	baseline := o.state.baseline
+	if baseline == nil {
+		return nil
+	}
+	var added []string
+	for _, d := range parseDiagnostics(stripANSI(output)) {
+		if d.level == "warning" && !baseline[baselineKey(d)] {
+			added = append(added, fmt.Sprintf("%s:%d: %s", d.file, d.line, d.message))
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	if len(added) > baselineShown {
+		added = append(added[:baselineShown], fmt.Sprintf("and %d more", len(added)-baselineShown))
+	}
+	return fmt.Errorf("there are warnings that are not in %s:\n  %s", baselineFilename, strings.Join(added, "\n  "))
+}
+
+// recordBaseline compiles every source again, and writes the warnings to the baseline file, for cxx2 baseline.
+// Later builds in strict mode then only fail on the warnings that are not in it.
+func recordBaseline(o *Options) error {
+	warningLog := &diagnosticLog{tools: map[string][]diagnostic{}, seen: map[string]bool{}}
+	o.state.warningLog = warningLog
+	defer func() { o.state.warningLog = nil }()
+	o.Force = true
+	if err := buildWithReport(o); err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	var keys []string
+	for _, ds := range warningLog.tools {
+		for _, d := range ds {
+			if key := baselineKey(d); d.level == "warning" && !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	sb.WriteString("# Generated by cxx2 baseline. Builds in strict mode fail on the warnings that are not listed here.\n")
+	for _, key := range keys {
+		sb.WriteString(key + "\n")
+	}
+	if err := writeFile(o, baselineFilename, []byte(sb.String())); err != nil {
+		return err
+	}
+	if o.DryRun {
+		return nil
+	}
+	fmt.Printf("Wrote %d warnings to %s\n", len(keys), baselineFilename)
+	return nil
+}
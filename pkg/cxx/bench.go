@@ -0,0 +1,108 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// isBenchSource checks if the given source is a benchmark, like sort_bench.cpp
+func isBenchSource(s string) bool {
+	l := strings.ToLower(filepath.Base(s))
+	for _, ext := range []string{".cpp", ".cc", ".cxx", ".c"} {
+		if strings.HasSuffix(l, "_bench"+ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// usesGoogleBenchmark checks if the given benchmark source includes Google Benchmark
+func usesGoogleBenchmark(o *Options, src string) bool {
+	for _, inc := range discoverIncludes(o, src) {
+		if inc == "benchmark/benchmark.h" {
+			return true
+		}
+	}
+	return false
+}
+
+// benchExecutable returns where the benchmark executable for the given benchmark source is placed
+func benchExecutable(o *Options, src string) string {
+	return filepath.Join(o.BuildDir, ensureExeSuffix(strings.TrimSuffix(filepath.Base(src), filepath.Ext(src)), o.Win64Docker))
+}
+
+// benchmarkMainLibrary returns the library that provides a main function for Google Benchmark sources without one
+func benchmarkMainLibrary(o *Options) string {
+	if isMSVC(o) {
+		return "benchmark_main.lib"
+	}
+	return "-lbenchmark_main"
+}
+
+// buildAndRunBenchmarks builds an optimized executable for each benchmark source, linked with the
+// sources that are not entry points, and runs them. For Google Benchmark executables, the filter is
+// passed on as --benchmark_filter, while other benchmark executables only run if their name matches it.
+func buildAndRunBenchmarks(o *Options, cc *CompileCache) error {
+	var filter *regexp.Regexp
+	if o.BenchFilter != "" {
+		var err error
+		if filter, err = regexp.Compile(o.BenchFilter); err != nil {
+			return fmt.Errorf("invalid benchmark filter: %w", err)
+		}
+	}
+	var benchSrcs []string
+	for _, s := range o.BenchSources {
+		if filter == nil || usesGoogleBenchmark(o, s) || filter.MatchString(filepath.Base(benchExecutable(o, s))) {
+			benchSrcs = append(benchSrcs, s)
+		}
+	}
+	var normalSrcs []string
+	for _, s := range o.Sources {
+		if !isTestSource(s) && s != o.MainSource && targetOf(o, s) == nil {
+			normalSrcs = append(normalSrcs, s)
+		}
+	}
+	objs, err := compileAll(o, cc, append(normalSrcs, benchSrcs...))
+	if err != nil {
+		return err
+	}
+	saveCache(o, cc)
+	normalObjs, benchObjs := objs[:len(normalSrcs)], objs[len(normalSrcs):]
+	for i, s := range benchSrcs {
+		exe := benchExecutable(o, s)
+		google := usesGoogleBenchmark(o, s)
+		in := append([]string{benchObjs[i]}, normalObjs...)
+		if google && !hasBenchmarkMain(s) {
+			in = append(in, benchmarkMainLibrary(o))
+		}
+		if err := linkObjects(o, in, exe); err != nil {
+			return err
+		}
+		fmt.Println("Running benchmark:", exe)
+		if o.Win64Docker && wineCommand() == "" {
+			fmt.Println("Cannot run Windows .exe benchmark without wine.")
+			continue
+		}
+		if o.Target != "" && !o.Wasi {
+			fmt.Printf("Cannot run a benchmark that is cross-compiled for %s.\n", o.Target)
+			continue
+		}
+		args := o.ProgramArgs
+		if google && filter != nil {
+			args = append([]string{"--benchmark_filter=" + o.BenchFilter}, args...)
+		}
+		if err := runProgramTo(o, exe, args, os.Stdout, os.Stderr, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasBenchmarkMain checks if the given Google Benchmark source defines a main function, possibly with BENCHMARK_MAIN
+func hasBenchmarkMain(src string) bool {
+	b, e := os.ReadFile(src)
+	return e == nil && (strings.Contains(string(b), " main(") || strings.Contains(string(b), "BENCHMARK_MAIN"))
+}
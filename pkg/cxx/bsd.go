@@ -0,0 +1,36 @@
+package cxx
+
+import (
+	"runtime"
+)
+
+// bsdPrefixes are where the BSD package managers install packages, and where X11 is found
+var bsdPrefixes = map[string][]string{
+	"freebsd":   {"/usr/local"},
+	"dragonfly": {"/usr/local"},
+	"openbsd":   {"/usr/local", "/usr/X11R6"},
+	"netbsd":    {"/usr/pkg", "/usr/X11R7"},
+}
+
+// onBSD checks if the build is for a BSD, which is not the case when building with Docker
+func onBSD(o *Options) bool {
+	_, ok := bsdPrefixes[runtime.GOOS]
+	return ok && !o.Win64Docker && o.Target == "" && !o.Wasm
+}
+
+// defaultCompiler returns the compiler that is used when nothing else is configured,
+// which is clang++ on the BSDs, since that is what the base system comes with
+func defaultCompiler() string {
+	if _, ok := bsdPrefixes[runtime.GOOS]; ok {
+		return "clang++"
+	}
+	return "g++"
+}
+
+// setupBSD adds the include and library directories of the BSD package prefixes,
+// since these are not searched by the compiler by default
+func setupBSD(o *Options) {
+	for _, p := range bsdPrefixes[runtime.GOOS] {
+		addPackagePrefix(o, p)
+	}
+}
@@ -0,0 +1,132 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultBuildDir is where object files, the cache and other intermediate artifacts are placed
+const defaultBuildDir = ".cxx"
+
+// objectPath returns the object file for the given source, mirroring the source tree below the build directory
+func objectPath(o *Options, src string) string {
+	ext := ".o"
+	if isMSVC(o) {
+		ext = ".obj"
+	}
+	if isResourceSource(src) {
+		// Not to clash with the object of a source with the same name, like app.cpp and app.rc
+		ext = ".res"
+	}
+	if rel, err := filepath.Rel(o.BuildDir, src); err == nil && !strings.HasPrefix(rel, "..") {
+		// Generated in the build directory, like the sources from moc
+		src = rel
+	} else if filepath.IsAbs(src) {
+		// Outside of the project, like the std module of the standard library
+		src = filepath.Join("ext", filepath.Base(src))
+	}
+	return filepath.Join(o.BuildDir, "obj", strings.TrimSuffix(src, filepath.Ext(src))+ext)
+}
+
+// testExecutable returns where the test executable for the given test source is placed
+func testExecutable(o *Options, src string) string {
+	exe := ensureExeSuffix(strings.TrimSuffix(filepath.Base(src), filepath.Ext(src)), o.Win64Docker)
+	if o.Wasm {
+		// Tests are run with node
+		exe += ".js"
+	}
+	if o.Wasi {
+		exe += ".wasm"
+	}
+	return filepath.Join(o.BuildDir, "test", exe)
+}
+
+func cachePath(o *Options) string {
+	return filepath.Join(o.BuildDir, "cache.json")
+}
+
+// stampIsNewer checks if the given stamp file exists and is newer than all of the given files that exist
+func stampIsNewer(stamp string, files ...string) bool {
+	si, err := os.Stat(stamp)
+	if err != nil {
+		return false
+	}
+	for _, f := range files {
+		if fi, err := os.Stat(f); err == nil && fi.ModTime().After(si.ModTime()) {
+			return false
+		}
+	}
+	return true
+}
+
+// inputChanged checks if the input has been changed since the outputs were generated from it,
+// according to the timestamp in the compile cache, or if any of the outputs are missing
+func inputChanged(cc *CompileCache, file string, outputs ...string) bool {
+	for _, out := range outputs {
+		if out != "" && !fileExists(out) {
+			return true
+		}
+	}
+	fi, err := os.Stat(file)
+	if err != nil {
+		return true
+	}
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.Timestamps[file] != fi.ModTime().Unix()
+}
+
+// prepareBuildDir creates the build directory, with a .gitignore file that ignores everything in it
+func prepareBuildDir(o *Options) error {
+	if err := makeDir(o, o.BuildDir); err != nil {
+		return err
+	}
+	gi := filepath.Join(o.BuildDir, ".gitignore")
+	if !fileExists(gi) {
+		return writeFile(o, gi, []byte("*\n"))
+	}
+	return nil
+}
+
+// forceRebuild removes the objects, compiled module interfaces, precompiled headers and the compile cache
+// from the build directory, so that everything is compiled again. The built dependencies are kept.
+func forceRebuild(o *Options) {
+	for _, sub := range []string{"obj", "modules", "pch"} {
+		removeDir(o, filepath.Join(o.BuildDir, sub))
+	}
+	removeFile(o, cachePath(o))
+}
+
+// removeBuildDir removes the build directory and everything in it,
+// unless it is the project directory itself or one of its parents
+func removeBuildDir(o *Options) {
+	d := filepath.Clean(o.BuildDir)
+	if d == "." || d == ".." || strings.HasPrefix(d, ".."+string(filepath.Separator)) || d == string(filepath.Separator) || !dirExists(d) {
+		return
+	}
+	if abs, err := filepath.Abs(d); err != nil || strings.HasPrefix(mustPwd(), abs) {
+		return
+	}
+	fmt.Printf("Removing %s\n", d)
+	if daemonListening(o) {
+		// Keep the socket, so that the running daemon can still be reached
+		entries, _ := os.ReadDir(d)
+		for _, e := range entries {
+			if p := filepath.Join(d, e.Name()); p != daemonSocket(o) {
+				removeDir(o, p)
+			}
+		}
+		return
+	}
+	removeDir(o, d)
+}
+
+// runnable returns a path that can be given to exec.Command, also for files in the current directory
+func runnable(p string) string {
+	if filepath.IsAbs(p) || strings.HasPrefix(p, ".") {
+		return p
+	}
+	return "./" + p
+}
@@ -0,0 +1,120 @@
+// Package cxx builds C and C++ projects without a build configuration, by discovering the sources,
+// headers and dependencies in the current directory. It is what the cxx2 command is built on.
+//
+// A typical build, equivalent to running cxx2 without arguments:
+//
+//	opts, err := cxx.ParseArgs(nil)
+//	if err != nil {
+//		return err
+//	}
+//	return cxx.NewBuilder(opts).Build()
+package cxx
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Builder builds the project in the current directory, according to its options.
+// Build does everything at once, while the other methods can be used for
+// discovering the sources and compiling and linking them step by step.
+type Builder struct {
+	Options *Options
+	cache   *CompileCache
+}
+
+// buildState is what is collected while building with a set of options, so that builds with
+// different options do not share it. It is passed down to the build functions with the options.
+type buildState struct {
+	// report collects what happens during a build, for --report=, --timings and the build history.
+	// It is nil outside of buildWithReport.
+	report *buildReport
+	// diagnostics collects the diagnostics for --sarif=. It is nil outside of buildWithSARIF.
+	diagnostics *diagnosticLog
+	// warningLog collects the warnings of the build for cxx2 baseline. It is nil otherwise.
+	warningLog *diagnosticLog
+	// baseline is the warnings in the baseline file when building in strict mode, or nil if there is no baseline
+	baseline map[string]bool
+	// memory is what the daemon keeps between builds. It is nil when not running as a daemon.
+	memory *buildMemory
+	// annotated is the diagnostics that have been printed as workflow commands, since the diagnostics
+	// in headers are reported for every source that includes them
+	annotated   map[string]bool
+	annotatedMu sync.Mutex
+}
+
+// NewBuilder returns a builder for the given options, like those from DefaultOptions or ParseArgs
+func NewBuilder(o *Options) *Builder {
+	if o.state == nil {
+		o.state = &buildState{}
+	}
+	return &Builder{Options: o}
+}
+
+// Build discovers the sources and then builds, tests, installs, runs or exports the project,
+// depending on the options, just like the cxx2 command does
+func (b *Builder) Build() error {
+	if b.Options.Baseline {
+		return recordBaseline(b.Options)
+	}
+	if b.Options.SARIF != "" {
+		return buildWithSARIF(b.Options, buildWithReport)
+	}
+	return buildWithReport(b.Options)
+}
+
+// Sources returns the C and C++ sources of the project, without the excluded ones
+func (b *Builder) Sources() ([]string, error) {
+	srcs, err := discoverSources(b.Options)
+	if err != nil {
+		return nil, err
+	}
+	return excludeSources(srcs, b.Options.Exclude), nil
+}
+
+// CompileFlags returns the flags that are used for both compiling and linking, for the current build mode
+func (b *Builder) CompileFlags() string {
+	return compileFlags(b.Options)
+}
+
+// ObjectFile returns where the object file for the given source is placed
+func (b *Builder) ObjectFile(src string) string {
+	return objectPath(b.Options, src)
+}
+
+// CompileCommand returns the command that compiles the given source into its object file
+func (b *Builder) CompileCommand(src string) string {
+	return buildCompileCmd(b.Options, src, objectPath(b.Options, src))
+}
+
+// LinkCommand returns the command that links the given object files into an executable
+func (b *Builder) LinkCommand(objs []string, out string) string {
+	return buildLinkCmd(b.Options, objs, out)
+}
+
+// Cache returns the compile cache of the build directory, which is loaded the first time
+func (b *Builder) Cache() *CompileCache {
+	if b.cache == nil {
+		b.cache, _ = loadCache(b.Options)
+	}
+	return b.cache
+}
+
+// Compile compiles the given sources in parallel, skipping those that have not changed since
+// they were last compiled, and returns the object files in the same order as the sources
+func (b *Builder) Compile(srcs []string) ([]string, error) {
+	if b.Options.Jobs <= 0 {
+		b.Options.Jobs = runtime.NumCPU()
+	}
+	if err := prepareBuildDir(b.Options); err != nil {
+		return nil, err
+	}
+	objs, err := compileAll(b.Options, b.Cache(), srcs)
+	saveCache(b.Options, b.Cache())
+	return objs, err
+}
+
+// Link links the given object files into an executable
+func (b *Builder) Link(objs []string, out string) error {
+	return linkObjects(b.Options, objs, out)
+}
@@ -0,0 +1,83 @@
+package cxx
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// buildInfoHeader is the header that is generated when the project includes it, and that is not in the project
+const buildInfoHeader = "build_info.h"
+
+// includesBuildInfo checks if the given sources, or the headers in the project that they include, include
+// build_info.h, and there is no such header in the project
+func includesBuildInfo(o *Options, srcs []string) bool {
+	for _, src := range srcs {
+		for _, f := range append([]string{src}, projectHeaders(o, src)...) {
+			for _, inc := range discoverIncludes(o, f) {
+				if inc == buildInfoHeader && resolveInclude(o, f, inc) == "" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// buildDate returns the date of the build, which is from SOURCE_DATE_EPOCH if it is set.
+// Only the date is used, so that build_info.h does not change with every build.
+func buildDate() string {
+	t := time.Now()
+	if s := os.Getenv("SOURCE_DATE_EPOCH"); s != "" {
+		if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+			t = time.Unix(secs, 0)
+		}
+	}
+	return t.UTC().Format("2006-01-02")
+}
+
+// buildInfoContents returns the contents of build_info.h, with the git describe output, the commit,
+// the date of the build and the compiler version
+func buildInfoContents(o *Options) []byte {
+	orUnknown := func(s string) string {
+		if s == "" {
+			return "unknown"
+		}
+		return s
+	}
+	compiler := commandFirstLine(compilerCommand(o, "--version"))
+	if compiler == "" {
+		compiler = o.CXX
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Generated by cxx2\n#pragma once\n\n")
+	fmt.Fprintf(&b, "#define BUILD_GIT_DESCRIBE %q\n", orUnknown(gitOutput("describe", "--tags", "--always", "--dirty")))
+	fmt.Fprintf(&b, "#define BUILD_GIT_COMMIT %q\n", orUnknown(gitOutput("rev-parse", "HEAD")))
+	fmt.Fprintf(&b, "#define BUILD_DATE %q\n", buildDate())
+	fmt.Fprintf(&b, "#define BUILD_COMPILER %q\n", compiler)
+	return b.Bytes()
+}
+
+// setupBuildInfo generates build_info.h in the buildinfo directory of the build directory, which is added to
+// the include directories, if the project includes it. The header is only written when its contents change,
+// so that the sources that include it are not compiled again for every build.
+func setupBuildInfo(o *Options) error {
+	if !includesBuildInfo(o, append(append([]string{}, o.Sources...), o.BenchSources...)) {
+		return nil
+	}
+	dir := filepath.Join(o.BuildDir, "buildinfo")
+	o.IncludeDirs = append(o.IncludeDirs, dir)
+	header := filepath.Join(dir, buildInfoHeader)
+	contents := buildInfoContents(o)
+	if old, err := os.ReadFile(header); err == nil && bytes.Equal(old, contents) {
+		return nil
+	}
+	verbosef(o, "Writing %s\n", header)
+	if err := makeDir(o, dir); err != nil {
+		return err
+	}
+	return writeFile(o, header, contents)
+}
@@ -0,0 +1,103 @@
+package cxx
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// defaultCStd is the C standard that the C sources are compiled with, unless another one is selected
+const defaultCStd = "c17"
+
+// isCSource checks if the given source is C, which is compiled with the C compiler.
+// .C is left out, since it is a C++ extension.
+func isCSource(src string) bool {
+	return filepath.Ext(src) == ".c"
+}
+
+// isCProject checks if the sources that are compiled are all C, apart from assembly and resources,
+// so that the C compiler is also used for linking, and the C++ standard library is not linked in
+func isCProject(o *Options) bool {
+	found := false
+	for _, s := range o.Sources {
+		switch {
+		case isCSource(s):
+			found = true
+		case isAsmSource(s) || isNASMSource(s) || isResourceSource(s):
+		default:
+			return false
+		}
+	}
+	return found
+}
+
+// cCompiler returns the C compiler that goes with the C++ compiler, like gcc for g++, clang-17 for clang++-17,
+// aarch64-linux-gnu-gcc for aarch64-linux-gnu-g++ and "zig cc" for "zig c++", unless one is given with
+// $CC, --cc= or cc = in the configuration file. cl.exe compiles both.
+func cCompiler(o *Options) string {
+	if o.CC != "" {
+		return o.CC
+	}
+	for _, names := range [][2]string{{"clang++", "clang"}, {"g++", "gcc"}, {"em++", "emcc"}, {"c++", "cc"}} {
+		if i := strings.LastIndex(o.CXX, names[0]); i >= 0 {
+			return o.CXX[:i] + names[1] + o.CXX[i+len(names[0]):]
+		}
+	}
+	return o.CXX
+}
+
+// linkDriver returns the compiler that links, which is the C compiler when there are only C sources
+func linkDriver(o *Options) string {
+	if isCProject(o) {
+		return cCompiler(o)
+	}
+	return o.CXX
+}
+
+// cCompileFlags returns the flags for compiling C, which are the same as for C++, apart from the warnings
+// that are only about C, and the flags that are only for C++
+func cCompileFlags(o *Options) string {
+	flags := removeFromSlice(strings.Fields(compileFlags(o)), "-fpermissive")
+	if !o.Sloppy {
+		flags = append(flags, "-Wstrict-prototypes")
+	}
+	if o.Strict {
+		flags = append(flags, "-Wmissing-prototypes")
+	}
+	return strings.Join(flags, " ")
+}
+
+// driverFlags returns the compilation flags that are also given when linking, for the C or C++ driver
+func driverFlags(o *Options) string {
+	if isCProject(o) {
+		return cCompileFlags(o)
+	}
+	return compileFlags(o)
+}
+
+// cExtraFlags returns the extra compilation flags for C sources, with $CFLAGS instead of $CXXFLAGS
+func cExtraFlags(o *Options) string {
+	flags := append([]string{}, o.ExtraCFlags...)
+	for _, f := range o.CXXFlags {
+		for i := range flags {
+			if flags[i] == f {
+				flags = append(flags[:i], flags[i+1:]...)
+				break
+			}
+		}
+	}
+	return joinExtraCFlags(append(flags, o.CFlags...))
+}
+
+// cCompileCmd returns the command for compiling the given C source into the given object file
+func cCompileCmd(o *Options, src, obj string) string {
+	sf := ""
+	if o.CStd != "" {
+		sf = "-std=" + o.CStd
+	}
+	flags, cf := fileFlags(o, src, cCompileFlags(o), cExtraFlags(o))
+	cc := cCompiler(o)
+	if o.Launcher != "" {
+		cc = o.Launcher + " " + cc
+	}
+	return joinNonEmpty([]string{cc, sf, flags, includeFlags(o), cf, "-c", src, "-o", obj})
+}
@@ -0,0 +1,222 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cmakeStd converts a -std= value like c++20, gnu++17 or c++2b to the CMAKE_CXX_STANDARD number,
+// and reports if GNU extensions are enabled
+func cmakeStd(std string) (string, bool) {
+	gnu := strings.HasPrefix(std, "gnu")
+	v := std[strings.LastIndex(std, "+")+1:]
+	switch v {
+	case "0x":
+		v = "11"
+	case "1y":
+		v = "14"
+	case "1z":
+		v = "17"
+	case "2a":
+		v = "20"
+	case "2b":
+		v = "23"
+	case "2c":
+		v = "26"
+	}
+	return v, gnu
+}
+
+// cmakeCStd converts a -std= value like c11, gnu17 or c2x to the CMAKE_C_STANDARD number,
+// and reports if GNU extensions are enabled
+func cmakeCStd(std string) (string, bool) {
+	gnu := strings.HasPrefix(std, "gnu")
+	v := strings.TrimPrefix(strings.TrimPrefix(std, "gnu"), "c")
+	switch v {
+	case "89":
+		v = "90"
+	case "18":
+		v = "17"
+	case "2x":
+		v = "23"
+	}
+	return v, gnu
+}
+
+// projectName returns a name for the project, based on the output name
+func projectName(o *Options) string {
+	if o.Lib {
+		return o.LibName
+	}
+	if o.OutputName != "" {
+		return strings.TrimSuffix(filepath.Base(o.OutputName), ".exe")
+	}
+	return libraryBaseName("")
+}
+
+// generateCMakeLists writes a CMakeLists.txt with the targets, sources, include directories,
+// compile flags and the pkg-config packages that were discovered
+func generateCMakeLists(o *Options) error {
+	name := projectName(o)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Generated by cxx2 %s, regenerate with: cxx2 cmake\n\n", Version)
+	fmt.Fprintf(&sb, "cmake_minimum_required(VERSION 3.16)\n")
+	languages := "C CXX"
+	if hasCUDASources(o.Sources) {
+		languages += " CUDA"
+	}
+	if hasHIPSources(o, o.Sources) {
+		languages += " HIP"
+	}
+	if hasSources(o.Sources, isAsmSource, isNASMSource) {
+		languages += " ASM"
+	}
+	if hasSources(o.Sources, isNASMSource) {
+		languages += " ASM_NASM"
+	}
+	fmt.Fprintf(&sb, "project(%s LANGUAGES %s)\n\n", name, languages)
+	if o.Std != "" {
+		std, gnu := cmakeStd(o.Std)
+		fmt.Fprintf(&sb, "set(CMAKE_CXX_STANDARD %s)\nset(CMAKE_CXX_STANDARD_REQUIRED ON)\n", std)
+		if gnu {
+			fmt.Fprintf(&sb, "set(CMAKE_CXX_EXTENSIONS ON)\n\n")
+		} else {
+			fmt.Fprintf(&sb, "set(CMAKE_CXX_EXTENSIONS OFF)\n\n")
+		}
+	}
+	if o.CStd != "" && hasSources(o.Sources, isCSource) {
+		std, gnu := cmakeCStd(o.CStd)
+		fmt.Fprintf(&sb, "set(CMAKE_C_STANDARD %s)\nset(CMAKE_C_STANDARD_REQUIRED ON)\n", std)
+		if gnu {
+			fmt.Fprintf(&sb, "set(CMAKE_C_EXTENSIONS ON)\n\n")
+		} else {
+			fmt.Fprintf(&sb, "set(CMAKE_C_EXTENSIONS OFF)\n\n")
+		}
+	}
+	if len(o.PkgConfigPackages) > 0 {
+		fmt.Fprintf(&sb, "find_package(PkgConfig REQUIRED)\n")
+		fmt.Fprintf(&sb, "pkg_check_modules(DEPS REQUIRED IMPORTED_TARGET %s)\n\n", strings.Join(o.PkgConfigPackages, " "))
+	}
+
+	var includes []string
+	for _, d := range o.IncludeDirs {
+		if dirExists(d) {
+			includes = append(includes, filepath.ToSlash(d))
+		}
+	}
+	options := strings.Fields(compileFlags(o))
+	var defines []string
+	for _, f := range o.ExtraCFlags {
+		if strings.HasPrefix(f, "-D") {
+			defines = append(defines, strings.TrimPrefix(f, "-D"))
+		} else if !strings.HasPrefix(f, "-I") {
+			options = append(options, f)
+		}
+	}
+	var libs []string
+	if len(o.PkgConfigPackages) > 0 {
+		libs = append(libs, "PkgConfig::DEPS")
+	}
+	libs = append(libs, o.ExtraLDFlags...)
+
+	// properties sets the include directories, flags and libraries of a target
+	properties := func(target string, extraLibs ...string) {
+		if len(includes) > 0 {
+			fmt.Fprintf(&sb, "target_include_directories(%s PRIVATE %s)\n", target, strings.Join(includes, " "))
+		}
+		if len(defines) > 0 {
+			fmt.Fprintf(&sb, "target_compile_definitions(%s PRIVATE %s)\n", target, strings.Join(defines, " "))
+		}
+		fmt.Fprintf(&sb, "target_compile_options(%s PRIVATE %s)\n", target, strings.Join(options, " "))
+		if l := append(extraLibs, libs...); len(l) > 0 {
+			fmt.Fprintf(&sb, "target_link_libraries(%s PRIVATE %s)\n", target, strings.Join(l, " "))
+		}
+		fmt.Fprintln(&sb)
+	}
+
+	var libSources, sharedSources []string
+	for _, s := range o.Sources {
+		if isTestSource(s) {
+			continue
+		}
+		libSources = append(libSources, filepath.ToSlash(s))
+		if s != o.MainSource && targetOf(o, s) == nil {
+			sharedSources = append(sharedSources, filepath.ToSlash(s))
+		}
+	}
+
+	// testLib is what the tests link with, in addition to their own source
+	var testLib []string
+	switch {
+	case o.Lib:
+		kind := "STATIC"
+		if o.Shared {
+			kind = "SHARED"
+		}
+		fmt.Fprintf(&sb, "add_library(%s %s %s)\n", name, kind, strings.Join(libSources, " "))
+		if o.Shared {
+			major, _, _ := strings.Cut(o.LibVersion, ".")
+			fmt.Fprintf(&sb, "set_target_properties(%s PROPERTIES VERSION %s SOVERSION %s)\n", name, o.LibVersion, major)
+		}
+		properties(name)
+		fmt.Fprintf(&sb, "install(TARGETS %s)\n", name)
+		if headers := libraryHeaders(); len(headers) > 0 {
+			if dirExists("include") {
+				fmt.Fprintf(&sb, "install(DIRECTORY include/ TYPE INCLUDE)\n")
+			} else {
+				var hs []string
+				for h := range headers {
+					hs = append(hs, h)
+				}
+				fmt.Fprintf(&sb, "install(FILES %s TYPE INCLUDE)\n", strings.Join(hs, " "))
+			}
+		}
+		fmt.Fprintln(&sb)
+		testLib = []string{name}
+	default:
+		var exes []string
+		if len(sharedSources) > 0 && (len(o.Targets) > 0 || len(o.TestSources) > 0) {
+			// Compile the sources without a main function once, for the executables and the tests
+			fmt.Fprintf(&sb, "add_library(%s_common OBJECT %s)\n", name, strings.Join(sharedSources, " "))
+			properties(name + "_common")
+			testLib = []string{name + "_common"}
+			sharedSources = nil
+		}
+		if o.MainSource != "" {
+			fmt.Fprintf(&sb, "add_executable(%s %s)\n", name, strings.Join(append([]string{filepath.ToSlash(o.MainSource)}, sharedSources...), " "))
+			properties(name, testLib...)
+			exes = append(exes, name)
+		}
+		for _, t := range o.Targets {
+			var ts []string
+			for _, s := range t.Sources {
+				ts = append(ts, filepath.ToSlash(s))
+			}
+			fmt.Fprintf(&sb, "add_executable(%s %s)\n", t.Name, strings.Join(ts, " "))
+			properties(t.Name, testLib...)
+			exes = append(exes, t.Name)
+		}
+		if len(exes) > 0 {
+			fmt.Fprintf(&sb, "install(TARGETS %s)\n\n", strings.Join(exes, " "))
+		}
+	}
+
+	if len(o.TestSources) > 0 {
+		fmt.Fprintf(&sb, "include(CTest)\nif(BUILD_TESTING)\n")
+		for _, s := range o.TestSources {
+			t := strings.TrimSuffix(filepath.Base(s), filepath.Ext(s))
+			fmt.Fprintf(&sb, "  add_executable(%s %s)\n", t, filepath.ToSlash(s))
+			if len(includes) > 0 {
+				fmt.Fprintf(&sb, "  target_include_directories(%s PRIVATE %s)\n", t, strings.Join(includes, " "))
+			}
+			if l := append(testLib, libs...); len(l) > 0 {
+				fmt.Fprintf(&sb, "  target_link_libraries(%s PRIVATE %s)\n", t, strings.Join(l, " "))
+			}
+			fmt.Fprintf(&sb, "  add_test(NAME %s COMMAND %s)\n", t, t)
+		}
+		fmt.Fprintf(&sb, "endif()\n")
+	}
+	return os.WriteFile("CMakeLists.txt", []byte(sb.String()), 0o644)
+}
@@ -0,0 +1,106 @@
+package cxx
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// cmakeConfigDir returns where the CMake package configuration files for the library are generated
+func cmakeConfigDir(o *Options) string {
+	return filepath.Join(o.BuildDir, "cmake")
+}
+
+// cmakeConfigFiles returns the CMake package configuration files for the library, by filename,
+// so that CMake projects can use find_package(NAME) and link with NAME::NAME once it is installed
+func cmakeConfigFiles(o *Options) map[string]string {
+	name := o.LibName
+	depsTarget := name + "_DEPS"
+
+	var config strings.Builder
+	fmt.Fprintf(&config, "# Generated by cxx2 %s\n\n", Version)
+	if len(o.PkgConfigPackages) > 0 {
+		config.WriteString("include(CMakeFindDependencyMacro)\n")
+		config.WriteString("find_dependency(PkgConfig)\n")
+		fmt.Fprintf(&config, "pkg_check_modules(%s REQUIRED IMPORTED_TARGET %s)\n\n", depsTarget, strings.Join(o.PkgConfigPackages, " "))
+	}
+	fmt.Fprintf(&config, "include(\"${CMAKE_CURRENT_LIST_DIR}/%sTargets.cmake\")\n", name)
+
+	kind := "STATIC"
+	if o.Shared {
+		kind = "SHARED"
+	}
+	var links []string
+	if len(o.PkgConfigPackages) > 0 {
+		links = append(links, "PkgConfig::"+depsTarget)
+	}
+	if o.Config != nil {
+		for _, f := range o.Config.LDFlags {
+			if l, ok := strings.CutPrefix(f, "-l"); ok {
+				links = append(links, l)
+			}
+		}
+	}
+	var targets strings.Builder
+	fmt.Fprintf(&targets, "# Generated by cxx2 %s\n\n", Version)
+	targets.WriteString("# The prefix is four levels up from PREFIX/lib/cmake/NAME/NAMETargets.cmake\n")
+	targets.WriteString("get_filename_component(_IMPORT_PREFIX \"${CMAKE_CURRENT_LIST_FILE}\" PATH)\n")
+	for i := 0; i < 3; i++ {
+		targets.WriteString("get_filename_component(_IMPORT_PREFIX \"${_IMPORT_PREFIX}\" PATH)\n")
+	}
+	fmt.Fprintf(&targets, "\nif(NOT TARGET %s::%s)\n", name, name)
+	fmt.Fprintf(&targets, "  add_library(%s::%s %s IMPORTED)\n", name, name, kind)
+	fmt.Fprintf(&targets, "  set_target_properties(%s::%s PROPERTIES\n", name, name)
+	fmt.Fprintf(&targets, "    IMPORTED_LOCATION \"${_IMPORT_PREFIX}/lib/%s\"\n", filepath.Base(o.OutputName))
+	if o.Shared {
+		if l := sharedLibraryLinks(filepath.Base(o.OutputName), o.LibVersion); len(l) > 0 {
+			fmt.Fprintf(&targets, "    IMPORTED_SONAME \"%s\"\n", l[0])
+		}
+	}
+	targets.WriteString("    INTERFACE_INCLUDE_DIRECTORIES \"${_IMPORT_PREFIX}/include\"\n")
+	if len(links) > 0 {
+		fmt.Fprintf(&targets, "    INTERFACE_LINK_LIBRARIES \"%s\"\n", strings.Join(links, ";"))
+	}
+	targets.WriteString("  )\nendif()\n\nunset(_IMPORT_PREFIX)\n")
+
+	var version strings.Builder
+	fmt.Fprintf(&version, "# Generated by cxx2 %s\n\n", Version)
+	fmt.Fprintf(&version, "set(PACKAGE_VERSION \"%s\")\n\n", o.LibVersion)
+	version.WriteString("# Compatible with requests for the same major version that are not newer\n")
+	version.WriteString("string(REGEX MATCH \"^[0-9]+\" _major \"${PACKAGE_VERSION}\")\n")
+	version.WriteString("if(PACKAGE_FIND_VERSION VERSION_GREATER PACKAGE_VERSION OR NOT PACKAGE_FIND_VERSION_MAJOR STREQUAL _major)\n")
+	version.WriteString("  set(PACKAGE_VERSION_COMPATIBLE FALSE)\nelse()\n  set(PACKAGE_VERSION_COMPATIBLE TRUE)\n")
+	version.WriteString("  if(PACKAGE_FIND_VERSION STREQUAL PACKAGE_VERSION)\n    set(PACKAGE_VERSION_EXACT TRUE)\n  endif()\nendif()\n")
+	version.WriteString("unset(_major)\n")
+
+	return map[string]string{
+		name + "Config.cmake":        config.String(),
+		name + "Targets.cmake":       targets.String(),
+		name + "ConfigVersion.cmake": version.String(),
+	}
+}
+
+// writeCMakeConfig generates the CMake package configuration files for the library in the build directory
+func writeCMakeConfig(o *Options) error {
+	dir := cmakeConfigDir(o)
+	if err := makeDir(o, dir); err != nil {
+		return err
+	}
+	for fn, contents := range cmakeConfigFiles(o) {
+		if err := writeFile(o, filepath.Join(dir, fn), []byte(contents)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePackageFiles generates the pkg-config file and the CMake package configuration for the library
+func writePackageFiles(o *Options) error {
+	if err := writePkgConfigFile(o); err != nil {
+		return fmt.Errorf("could not write %s: %w", pkgConfigFile(o), err)
+	}
+	if err := writeCMakeConfig(o); err != nil {
+		return fmt.Errorf("could not write the CMake package configuration: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package cxx
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// CompileCommand is one entry in a compile_commands.json compilation database
+type CompileCommand struct {
+	Directory string `json:"directory"`
+	Command   string `json:"command"`
+	File      string `json:"file"`
+	Output    string `json:"output"`
+}
+
+// compilationDatabase returns the compile command for every discovered source,
+// exactly as buildCompileCmd would produce it during a build
+func compilationDatabase(o *Options) []CompileCommand {
+	dir := mustPwd()
+	var out []CompileCommand
+	for _, s := range o.Sources {
+		obj := objectPath(o, s)
+		out = append(out, CompileCommand{
+			Directory: dir,
+			Command:   buildCompileCmd(o, s, obj),
+			File:      s,
+			Output:    obj,
+		})
+	}
+	return out
+}
+
+func writeCompilationDatabase(o *Options) error {
+	b, err := json.MarshalIndent(compilationDatabase(o), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile("compile_commands.json", append(b, '\n'), 0o644)
+}
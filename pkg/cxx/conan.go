@@ -0,0 +1,113 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// conanFiles are the files that make a project use Conan, in order of preference
+var conanFiles = []string{"conanfile.py", "conanfile.txt"}
+
+// conanStamp is written after a successful conan install, so that it only runs again when the conanfile changes
+const conanStamp = ".cxx2-stamp"
+
+// conanFile returns the conanfile of the project, or "" if there is none
+func conanFile() string {
+	for _, f := range conanFiles {
+		if fileExists(f) {
+			return f
+		}
+	}
+	return ""
+}
+
+// conanBuildType returns the Conan build type that matches the build mode
+func conanBuildType(o *Options) string {
+	if o.Debug {
+		return "Debug"
+	}
+	return "Release"
+}
+
+// conanOutputDir returns where conan install places the generated files for the current build type
+func conanOutputDir(o *Options) string {
+	return filepath.Join(o.BuildDir, "conan", strings.ToLower(conanBuildType(o)))
+}
+
+// conanInstall runs conan install for the conanfile, with the PkgConfigDeps generator,
+// unless the conanfile is unchanged since the last install
+func conanInstall(o *Options, conanfile string) error {
+	out := conanOutputDir(o)
+	stamp := filepath.Join(out, conanStamp)
+	if stampIsNewer(stamp, conanfile) {
+		return nil
+	}
+	if !haveCmd("conan") {
+		return fmt.Errorf("found %s, but conan is not installed", conanfile)
+	}
+	args := []string{"install", conanfile, "--output-folder=" + out, "--build=missing", "-s", "build_type=" + conanBuildType(o)}
+	if b, err := os.ReadFile(conanfile); err == nil && !strings.Contains(string(b), "PkgConfigDeps") {
+		args = append(args, "-g", "PkgConfigDeps")
+	}
+	// Conan runs on the host, also when building with Docker
+	fmt.Println("conan", strings.Join(args, " "))
+	if o.DryRun {
+		return nil
+	}
+	c := exec.Command("conan", args...)
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return err
+	}
+	return writeFile(o, stamp, nil)
+}
+
+// conanFlags returns the compilation and linker flags for the .pc files that Conan generated in the given directory
+func conanFlags(dir string) (string, error) {
+	pcs, err := filepath.Glob(filepath.Join(dir, "*.pc"))
+	if err != nil || len(pcs) == 0 {
+		return "", err
+	}
+	modules := make([]string, len(pcs))
+	for i, pc := range pcs {
+		modules[i] = strings.TrimSuffix(filepath.Base(pc), ".pc")
+	}
+	if !haveCmd("pkg-config") {
+		return "", fmt.Errorf("pkg-config not found")
+	}
+	c := exec.Command("pkg-config", append([]string{"--cflags", "--libs"}, modules...)...)
+	c.Env = append(os.Environ(), "PKG_CONFIG_PATH="+dir)
+	out, err := c.Output()
+	if err != nil {
+		return "", fmt.Errorf("pkg-config failed for the Conan dependencies: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// setupConan installs the dependencies in the conanfile of the project, if there is one,
+// and merges their include paths, defines and libraries into the flags
+func setupConan(o *Options) error {
+	conanfile := conanFile()
+	if conanfile == "" {
+		return nil
+	}
+	if err := conanInstall(o, conanfile); err != nil {
+		return err
+	}
+	flags, err := conanFlags(conanOutputDir(o))
+	if err != nil {
+		return err
+	}
+	mergePkgConfigFlags(flags, o)
+	for _, f := range strings.Fields(flags) {
+		if inc, ok := strings.CutPrefix(f, "-I"); ok && !contains(o.SystemIncludeDirs, inc) {
+			// So that the headers of the dependencies are not reported as missing
+			o.SystemIncludeDirs = append(o.SystemIncludeDirs, inc)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,446 @@
+package cxx
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// configFilenames are the project configuration files that are looked for, in order
+var configFilenames = []string{"cxx.toml", ".cxx.toml"}
+
+// Config is an optional project configuration file, in a small subset of TOML:
+//
+//	cxx = "clang++"
+//	cc = "clang"
+//	std = "c++23"
+//	output = "myprogram"
+//	cflags = ["-fno-exceptions"]
+//	ldflags = ["-lm"]
+//	include = ["third_party/include"]
+//	defines = ["USE_SDL2", "VERSION=\"1.0\""]
+//	exclude = ["old/**", "scratch.cpp"]
+//	build_dir = "build"
+//	launcher = "sccache"
+//	linker = "mold"
+//	march = "x86-64-v3"
+//	target = "aarch64-linux-gnu"
+//	sysroot = "/opt/sysroots/aarch64"
+//	container_image = "jhasse/mingw:latest"
+//	nasm_format = "elf64"
+//	pch = "src/pch.hpp"
+//	embed_shaders = true
+//	import_std = true
+//	shared = true
+//	version = "1.2.3"
+//	warnings = "gamejam"
+//
+//	[hooks]
+//	prebuild = ["./gen_assets.sh"]
+//	postbuild = "strip $CXX2_OUTPUT"
+//
+//	[warnings.gamejam]
+//	remove = ["-Wshadow", "-Wpedantic"]
+//	add = ["-Wno-unused-parameter"]
+//
+//	[files."third_party/**"]
+//	cflags = ["-w"]
+//
+//	[embed]
+//	files = ["assets/**", "LICENSE"]
+//
+//	[dependencies.fmt]
+//	git = "https://github.com/fmtlib/fmt"
+//	tag = "10.2.1"
+//
+// Precedence, from lowest to highest: built-in defaults and auto-detection,
+// the configuration file, the $CXX, $CC, $CXXFLAGS, $CFLAGS, $CPPFLAGS and $LDFLAGS environment
+// variables (unless --no-env is given), then command line arguments.
+type Config struct {
+	Filename     string
+	CXX          string
+	CC           string
+	Std          string
+	Output       string
+	BuildDir     string
+	Launcher     string
+	Linker       string
+	March        string
+	Target       string
+	Sysroot      string
+	Container    string
+	NASMFormat   string
+	PCH          string
+	CFlags       []string
+	LDFlags      []string
+	IncludeDirs  []string
+	Defines      []string
+	Exclude      []string
+	Shared       bool
+	EmbedShaders bool
+	ImportStd    bool
+	Version      string
+	Warnings     string
+	Prebuild     []string
+	Postbuild    []string
+	// Embed are the patterns for the files in the [embed] section, that are embedded into the binary
+	Embed []string
+	// WarningProfiles are the warning profiles in the [warnings.NAME] tables
+	WarningProfiles map[string]WarningProfile
+	// FileFlags are the flags for the sources that match the patterns of the [files."PATTERN"] tables
+	FileFlags []FileFlags
+	// Dependencies are the git repositories in the [dependencies.NAME] tables
+	Dependencies []GitDependency
+	// Tables holds every [section] of the file, keyed by the full dotted name.
+	// Top level keys are stored under "".
+	Tables map[string]map[string]any
+}
+
+// configPath returns the --config= argument, if given, or the first configuration file found
+func configPath(args []string) string {
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if strings.HasPrefix(arg, "--config=") {
+			return strings.TrimPrefix(arg, "--config=")
+		}
+	}
+	for _, fn := range configFilenames {
+		if fileExists(fn) {
+			return fn
+		}
+	}
+	return ""
+}
+
+// loadConfig reads and parses the given configuration file. An empty filename gives a nil Config.
+func loadConfig(filename string) (*Config, error) {
+	if filename == "" {
+		return nil, nil
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	tables, err := parseTables(f, filename)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{Filename: filename, Tables: tables}
+
+	top := cfg.Tables[""]
+	cfg.CXX = configString(top, "cxx")
+	cfg.CC = configString(top, "cc")
+	cfg.Std = configString(top, "std")
+	if cfg.Std != "" && !isStdName(cfg.Std) {
+		return nil, fmt.Errorf("%s: %s is not a C or C++ standard", filename, cfg.Std)
+	}
+	cfg.Output = configString(top, "output")
+	cfg.BuildDir = configString(top, "build_dir")
+	cfg.Launcher = configString(top, "launcher")
+	cfg.Linker = configString(top, "linker")
+	cfg.March = configString(top, "march")
+	cfg.Target = configString(top, "target")
+	cfg.Sysroot = configString(top, "sysroot")
+	cfg.Container = configString(top, "container_image")
+	cfg.NASMFormat = configString(top, "nasm_format")
+	cfg.PCH = configString(top, "pch")
+	cfg.CFlags = configStrings(top, "cflags")
+	cfg.LDFlags = configStrings(top, "ldflags")
+	cfg.IncludeDirs = configStrings(top, "include")
+	cfg.Defines = configStrings(top, "defines")
+	cfg.Exclude = configStrings(top, "exclude")
+	cfg.Shared = configBool(top, "shared")
+	cfg.EmbedShaders = configBool(top, "embed_shaders")
+	cfg.ImportStd = configBool(top, "import_std")
+	cfg.Version = configString(top, "version")
+	cfg.Warnings = configString(top, "warnings")
+	cfg.Prebuild = configStrings(cfg.Tables["hooks"], "prebuild")
+	cfg.Postbuild = configStrings(cfg.Tables["hooks"], "postbuild")
+	if embed, ok := cfg.Tables["embed"]; ok {
+		cfg.Embed = append([]string{}, configStrings(embed, "files")...)
+	}
+	cfg.WarningProfiles = warningProfiles(cfg.Tables)
+	cfg.FileFlags = fileFlagOverrides(cfg.Tables)
+	cfg.Dependencies = gitDependencies(cfg.Tables)
+	return cfg, nil
+}
+
+// apply merges the configuration file settings into the given options
+func (cfg *Config) apply(o *Options) {
+	if cfg.CXX != "" {
+		o.CXX = cfg.CXX
+	}
+	if cfg.CC != "" {
+		o.CC = cfg.CC
+	}
+	if cfg.Std != "" {
+		setStd(o, cfg.Std)
+	}
+	if cfg.Output != "" {
+		o.OutputName = cfg.Output
+	}
+	if cfg.BuildDir != "" {
+		o.BuildDir = cfg.BuildDir
+	}
+	if cfg.Launcher != "" {
+		o.Launcher = cfg.Launcher
+	}
+	if cfg.Linker != "" {
+		o.Linker = cfg.Linker
+	}
+	if cfg.March != "" {
+		o.March = cfg.March
+	}
+	if cfg.Target != "" {
+		o.Target = cfg.Target
+	}
+	if cfg.Sysroot != "" {
+		o.Sysroot = cfg.Sysroot
+	}
+	if cfg.Container != "" {
+		o.ContainerImage = cfg.Container
+	}
+	if cfg.NASMFormat != "" {
+		o.NASMFormat = cfg.NASMFormat
+	}
+	if cfg.PCH != "" {
+		o.PCH = cfg.PCH
+	}
+	for _, d := range cfg.Defines {
+		o.ExtraCFlags = append(o.ExtraCFlags, "-D"+d)
+	}
+	o.ExtraCFlags = append(o.ExtraCFlags, cfg.CFlags...)
+	o.ExtraLDFlags = append(o.ExtraLDFlags, cfg.LDFlags...)
+	o.IncludeDirs = append(o.IncludeDirs, cfg.IncludeDirs...)
+	o.Exclude = append(o.Exclude, cfg.Exclude...)
+	o.Shared = o.Shared || cfg.Shared
+	o.EmbedShaders = o.EmbedShaders || cfg.EmbedShaders
+	o.ImportStd = o.ImportStd || cfg.ImportStd
+	if cfg.Version != "" {
+		o.LibVersion = cfg.Version
+	}
+	if cfg.Warnings != "" {
+		o.Warnings = cfg.Warnings
+	}
+}
+
+// parseTables parses the TOML subset that is used for the configuration file and the header database,
+// into a map from every [section] to its keys and values. Top level keys are stored under "".
+func parseTables(r io.Reader, filename string) (map[string]map[string]any, error) {
+	tables := map[string]map[string]any{"": {}}
+	table := ""
+	sc := bufio.NewScanner(r)
+	lineno := 0
+	for sc.Scan() {
+		lineno++
+		line := strings.TrimSpace(stripComment(sc.Text()))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			table = normalizeTableName(strings.TrimSpace(line[1 : len(line)-1]))
+			if _, ok := tables[table]; !ok {
+				tables[table] = map[string]any{}
+			}
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("%s:%d: expected key = value", filename, lineno)
+		}
+		value = strings.TrimSpace(value)
+		// Arrays may span several lines
+		for strings.HasPrefix(value, "[") && !strings.HasSuffix(value, "]") && sc.Scan() {
+			lineno++
+			value += " " + strings.TrimSpace(stripComment(sc.Text()))
+		}
+		v, err := parseConfigValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", filename, lineno, err)
+		}
+		tables[table][unquote(strings.TrimSpace(key))] = v
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+func stripComment(line string) string {
+	inString := byte(0)
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case inString != 0 && c == '\\':
+			i++
+		case inString != 0 && c == inString:
+			inString = 0
+		case inString == 0 && (c == '"' || c == '\''):
+			inString = c
+		case inString == 0 && c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// normalizeTableName turns a."b/c".d into a.b/c.d
+func normalizeTableName(name string) string {
+	var parts []string
+	for len(name) > 0 {
+		name = strings.TrimSpace(name)
+		if name[0] == '"' || name[0] == '\'' {
+			end := strings.IndexByte(name[1:], name[0])
+			if end < 0 {
+				parts = append(parts, name)
+				break
+			}
+			parts = append(parts, name[1:end+1])
+			name = strings.TrimPrefix(strings.TrimSpace(name[end+2:]), ".")
+			continue
+		}
+		part, rest, _ := strings.Cut(name, ".")
+		parts = append(parts, strings.TrimSpace(part))
+		name = rest
+	}
+	return strings.Join(parts, ".")
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		if s[0] == '"' {
+			if u, err := strconv.Unquote(s); err == nil {
+				return u
+			}
+		}
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+func parseConfigValue(value string) (any, error) {
+	switch {
+	case value == "true":
+		return true, nil
+	case value == "false":
+		return false, nil
+	case strings.HasPrefix(value, "["):
+		if !strings.HasSuffix(value, "]") {
+			return nil, fmt.Errorf("unterminated array: %s", value)
+		}
+		var out []string
+		for _, item := range splitArray(value[1 : len(value)-1]) {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, unquote(item))
+			}
+		}
+		return out, nil
+	case strings.HasPrefix(value, "\""), strings.HasPrefix(value, "'"):
+		return unquote(value), nil
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n, nil
+	}
+	return nil, fmt.Errorf("unsupported value: %s", value)
+}
+
+// splitArray splits the inside of an array on commas that are not within quotes
+func splitArray(s string) []string {
+	var out []string
+	inString := byte(0)
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case inString != 0 && c == '\\':
+			i++
+		case inString != 0 && c == inString:
+			inString = 0
+		case inString == 0 && (c == '"' || c == '\''):
+			inString = c
+		case inString == 0 && c == ',':
+			out = append(out, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(out, s[start:])
+}
+
+func configString(t map[string]any, key string) string {
+	if s, ok := t[key].(string); ok {
+		return s
+	}
+	return ""
+}
+
+func configStrings(t map[string]any, key string) []string {
+	switch v := t[key].(type) {
+	case []string:
+		return v
+	case string:
+		return []string{v}
+	}
+	return nil
+}
+
+func configBool(t map[string]any, key string) bool {
+	b, _ := t[key].(bool)
+	return b
+}
+
+// globMatch reports if the slash separated path matches the pattern.
+// In addition to the filepath.Match syntax, "**" matches any number of directories,
+// and a pattern without a slash is matched against the base name of the path.
+func globMatch(pattern, path string) bool {
+	path = filepath.ToSlash(path)
+	pattern = filepath.ToSlash(pattern)
+	if !strings.Contains(pattern, "/") {
+		ok, _ := filepath.Match(pattern, filepath.Base(path))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(path, "/"))
+}
+
+func matchSegments(pattern, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(path); i++ {
+				if matchSegments(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(path) == 0 {
+			return false
+		}
+		if ok, _ := filepath.Match(pattern[0], path[0]); !ok {
+			return false
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0
+}
+
+// excludeSources removes the sources that match any of the given glob patterns
+func excludeSources(srcs, patterns []string) []string {
+	if len(patterns) == 0 {
+		return srcs
+	}
+	var out []string
+NEXT:
+	for _, s := range srcs {
+		for _, p := range patterns {
+			if globMatch(p, s) {
+				continue NEXT
+			}
+		}
+		out = append(out, s)
+	}
+	return out
+}
@@ -0,0 +1,73 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// defaultMinGWImage is the image that is used for --win64-docker when no --container-image= is given
+const defaultMinGWImage = "jhasse/mingw:latest"
+
+// containerEngine returns docker, or podman as a drop-in replacement if docker is not installed.
+// If neither is installed, docker is returned, for the error messages.
+func containerEngine() string {
+	if !haveCmd("docker") && haveCmd("podman") {
+		return "podman"
+	}
+	return "docker"
+}
+
+// haveContainerEngine checks if docker or podman is installed
+func haveContainerEngine() bool {
+	return haveCmd(containerEngine())
+}
+
+// containerArgs returns the arguments for running the given command in the given image,
+// with the current directory mounted as the working directory
+func containerArgs(image string, command []string) []string {
+	a := []string{"run", "-v", fmt.Sprintf("%s:/home", mustPwd()), "-w", "/home", "--rm"}
+	if os.Getenv("SOURCE_DATE_EPOCH") != "" {
+		// Passed on for reproducible builds
+		a = append(a, "-e", "SOURCE_DATE_EPOCH")
+	}
+	if containerEngine() == "podman" {
+		// Map the user to the same uid inside of the container, so that rootless podman can write to
+		// the mounted directory and the files are owned by the user. The directory is not relabeled for SELinux.
+		a = append(a, "--userns=keep-id", "--security-opt", "label=disable")
+	}
+	a = append(a, image)
+	return append(a, command...)
+}
+
+// mingwImage returns the image for --win64-docker
+func mingwImage(o *Options) string {
+	if o.ContainerImage != "" {
+		return o.ContainerImage
+	}
+	return defaultMinGWImage
+}
+
+// buildImage builds an image with the given tag from the given Dockerfile
+func buildImage(tag, dockerfile string) error {
+	dir, err := os.MkdirTemp("", "cxx2-image")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte(dockerfile), 0o644); err != nil {
+		return err
+	}
+	engine := containerEngine()
+	fmt.Printf("%s build -t %s\n", engine, tag)
+	c := exec.Command(engine, "build", "-q", "-t", tag, dir)
+	c.Stderr = os.Stderr
+	return c.Run()
+}
+
+// containerCommand returns the command line for running the given command line in the container that the build uses
+func containerCommand(o *Options, line string) string {
+	return containerEngine() + " " + strings.Join(dockerArgs(o, strings.Fields(line)), " ")
+}
@@ -0,0 +1,195 @@
+package cxx
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func isClang(o *Options) bool {
+	return strings.Contains(filepath.Base(o.CXX), "clang")
+}
+
+// compilerVersionSuffix returns the version suffix of the compiler, like "-17" for clang++-17
+func compilerVersionSuffix(o *Options) string {
+	b := filepath.Base(o.CXX)
+	for _, name := range []string{"clang++", "g++", "clang", "gcc"} {
+		if i := strings.LastIndex(b, name); i >= 0 {
+			return b[i+len(name):]
+		}
+	}
+	return ""
+}
+
+// coverageFlags returns the flags for building instrumented objects and executables
+func coverageFlags(o *Options) []string {
+	if isClang(o) {
+		return []string{"-fprofile-instr-generate", "-fcoverage-mapping", "-O0", "-g"}
+	}
+	return []string{"--coverage", "-O0", "-g"}
+}
+
+func profileDir(o *Options) string {
+	return filepath.Join(o.BuildDir, "profiles")
+}
+
+func coverageHTMLDir(o *Options) string {
+	return filepath.Join(o.BuildDir, "html")
+}
+
+// prepareCoverageRun removes the counters from earlier runs, and tells
+// clang instrumented executables where to write their raw profiles
+func prepareCoverageRun(o *Options) {
+	if isClang(o) {
+		removeDir(o, profileDir(o))
+		makeDir(o, profileDir(o))
+		if abs, err := filepath.Abs(profileDir(o)); err == nil {
+			os.Setenv("LLVM_PROFILE_FILE", filepath.Join(abs, "%p.profraw"))
+		}
+		return
+	}
+	filepath.WalkDir(o.BuildDir, func(p string, d fs.DirEntry, e error) error {
+		if e == nil && !d.IsDir() && strings.HasSuffix(p, ".gcda") {
+			removeFile(o, p)
+		}
+		return nil
+	})
+}
+
+// coverageSources returns the sources that coverage is reported for
+func coverageSources(o *Options) []string {
+	var out []string
+	for _, s := range o.Sources {
+		if !isTestSource(s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// coverageReport prints a coverage summary with gcov or llvm-cov, depending on the compiler,
+// and writes an HTML report if --html is given
+func coverageReport(o *Options) error {
+	if o.Win64Docker || o.Target != "" {
+		return fmt.Errorf("coverage reports are not supported when cross compiling")
+	}
+	if isClang(o) {
+		return llvmCoverageReport(o)
+	}
+	return gcovReport(o)
+}
+
+var (
+	gcovFileRx  = regexp.MustCompile(`^File '(.+)'$`)
+	gcovLinesRx = regexp.MustCompile(`^Lines executed:([0-9.]+)% of (\d+)$`)
+)
+
+func gcovReport(o *Options) error {
+	gcov := "gcov" + compilerVersionSuffix(o)
+	if !haveCmd(gcov) {
+		return fmt.Errorf("%s not found", gcov)
+	}
+	fmt.Println("\nCoverage:")
+	fmt.Printf("  %-40s %8s %9s\n", "File", "Lines", "Covered")
+	var totalLines, totalCovered int
+	for _, s := range coverageSources(o) {
+		out, err := exec.Command(gcov, "-n", "-o", objectPath(o, s), s).Output()
+		if err != nil {
+			fmt.Printf("  %-40s %8s %9s\n", s, "-", "not run")
+			continue
+		}
+		file := ""
+		sc := bufio.NewScanner(bytes.NewReader(out))
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if m := gcovFileRx.FindStringSubmatch(line); m != nil {
+				file = filepath.Clean(m[1])
+			} else if m := gcovLinesRx.FindStringSubmatch(line); m != nil && file == filepath.Clean(s) {
+				percent, _ := strconv.ParseFloat(m[1], 64)
+				lines, _ := strconv.Atoi(m[2])
+				totalLines += lines
+				totalCovered += int(percent*float64(lines)/100 + 0.5)
+				fmt.Printf("  %-40s %8d %8.2f%%\n", s, lines, percent)
+			}
+		}
+	}
+	if totalLines > 0 {
+		fmt.Printf("  %-40s %8d %8.2f%%\n", "Total", totalLines, 100*float64(totalCovered)/float64(totalLines))
+	}
+	if !o.CoverageHTML {
+		return nil
+	}
+	dir := coverageHTMLDir(o)
+	if haveCmd("gcovr") || haveCmd("lcov") {
+		if err := makeDir(o, dir); err != nil {
+			return err
+		}
+	}
+	switch {
+	case haveCmd("gcovr"):
+		if err := runCommand(fmt.Sprintf("gcovr --gcov-executable %s -r . --object-directory %s --html-details -o %s", gcov, o.BuildDir, filepath.Join(dir, "index.html")), o); err != nil {
+			return err
+		}
+	case haveCmd("lcov") && haveCmd("genhtml"):
+		info := filepath.Join(dir, "coverage.info")
+		if err := runCommand(fmt.Sprintf("lcov --gcov-tool %s -c -d %s -o %s", gcov, o.BuildDir, info), o); err != nil {
+			return err
+		}
+		if err := runCommand(fmt.Sprintf("genhtml %s -o %s", info, dir), o); err != nil {
+			return err
+		}
+	default:
+		fmt.Println("Install gcovr, or lcov and genhtml, for an HTML coverage report.")
+		return nil
+	}
+	fmt.Println("HTML coverage report:", filepath.Join(dir, "index.html"))
+	return nil
+}
+
+func llvmCoverageReport(o *Options) error {
+	suffix := compilerVersionSuffix(o)
+	profdata, cov := "llvm-profdata"+suffix, "llvm-cov"+suffix
+	if !haveCmd(profdata) || !haveCmd(cov) {
+		return fmt.Errorf("%s and %s are needed for coverage reports with clang", profdata, cov)
+	}
+	raw, _ := filepath.Glob(filepath.Join(profileDir(o), "*.profraw"))
+	if len(raw) == 0 {
+		return fmt.Errorf("no profiles were written to %s", profileDir(o))
+	}
+	merged := filepath.Join(o.BuildDir, "coverage.profdata")
+	if err := runCommand(fmt.Sprintf("%s merge -sparse %s -o %s", profdata, strings.Join(raw, " "), merged), o); err != nil {
+		return err
+	}
+	var exes []string
+	for _, s := range o.TestSources {
+		exes = append(exes, testExecutable(o, s))
+	}
+	if len(exes) == 0 {
+		exes = append(exes, o.OutputName)
+	}
+	objects := exes[0]
+	for _, exe := range exes[1:] {
+		objects += " -object " + exe
+	}
+	srcs := strings.Join(coverageSources(o), " ")
+	fmt.Println("\nCoverage:")
+	if err := runCommand(fmt.Sprintf("%s report %s -instr-profile=%s %s", cov, objects, merged, srcs), o); err != nil {
+		return err
+	}
+	if !o.CoverageHTML {
+		return nil
+	}
+	dir := coverageHTMLDir(o)
+	if err := runCommand(fmt.Sprintf("%s show %s -instr-profile=%s -format=html -output-dir=%s %s", cov, objects, merged, dir, srcs), o); err != nil {
+		return err
+	}
+	fmt.Println("HTML coverage report:", filepath.Join(dir, "index.html"))
+	return nil
+}
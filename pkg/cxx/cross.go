@@ -0,0 +1,157 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// crossPresets maps from the --arm64 and --armhf presets to the target triples of the cross toolchains
+// that the distros package, in the order they are looked for
+var crossPresets = map[string][]string{
+	"arm64": {"aarch64-linux-gnu", "aarch64-unknown-linux-gnu"},
+	"armhf": {"arm-linux-gnueabihf", "armv7l-linux-gnueabihf", "armv7a-unknown-linux-gnueabihf"},
+}
+
+// presetTarget returns the triple of the first cross toolchain for the preset that is installed,
+// or the first triple if none are, or if clang or zig is used, which can target them all
+func presetTarget(o *Options) string {
+	triples := crossPresets[o.CrossPreset]
+	if !isClang(o) && !isZig(o) {
+		for _, t := range triples {
+			if haveCmd(t + "-" + filepath.Base(o.CXX)) {
+				return t
+			}
+		}
+	}
+	return triples[0]
+}
+
+// setupCross prepares cross-compiling for the --target triple, like aarch64-linux-gnu.
+// GCC is replaced with the cross compiler for the triple, like aarch64-linux-gnu-g++,
+// while clang is given --target= and zig -target by crossFlags. pkg-config is pointed to the sysroot, if any.
+func setupCross(o *Options) error {
+	if o.CrossPreset != "" {
+		if o.Target != "" {
+			return fmt.Errorf("--%s can not be combined with --target=", o.CrossPreset)
+		}
+		o.Target = presetTarget(o)
+	}
+	if o.Target == "" {
+		return nil
+	}
+	if o.Win64Docker {
+		return fmt.Errorf("--target= can not be combined with --win64-docker")
+	}
+	if o.Coverage || o.PGO == "gen" {
+		return fmt.Errorf("coverage and pgo-gen need to run the program, which is not possible when cross compiling")
+	}
+	if !isClang(o) && !isZig(o) && !strings.HasPrefix(filepath.Base(o.CXX), o.Target+"-") {
+		o.CXX = o.Target + "-" + filepath.Base(o.CXX)
+		if o.CC != "" && !strings.HasPrefix(filepath.Base(o.CC), o.Target+"-") {
+			o.CC = o.Target + "-" + filepath.Base(o.CC)
+		}
+	}
+	if !haveCmd(compilerExecutable(o)) {
+		return fmt.Errorf("the cross compiler %s was not found, install it with: %s", o.CXX, installSuggestion(o.DetectedDistro, o.CXX))
+	}
+	var libDirs []string
+	if o.Sysroot != "" {
+		if !dirExists(o.Sysroot) {
+			return fmt.Errorf("the sysroot %s does not exist", o.Sysroot)
+		}
+		os.Setenv("PKG_CONFIG_SYSROOT_DIR", o.Sysroot)
+		for _, d := range []string{"usr/lib/" + o.Target + "/pkgconfig", "usr/lib/pkgconfig", "usr/share/pkgconfig"} {
+			libDirs = append(libDirs, filepath.Join(o.Sysroot, d))
+		}
+	} else {
+		// Multiarch libraries for the target, or the libraries in the sysroot of the cross toolchain
+		libDirs = []string{filepath.Join("/usr/lib", o.Target, "pkgconfig"), filepath.Join("/usr", o.Target, "lib", "pkgconfig")}
+		if root := toolchainSysroot(o); root != "" {
+			libDirs = append(libDirs, filepath.Join(root, "usr", "lib", "pkgconfig"), filepath.Join(root, "usr", "lib64", "pkgconfig"))
+		}
+		libDirs = append(libDirs, "/usr/share/pkgconfig")
+	}
+	// Only look for .pc files for the target, and not for the host
+	os.Setenv("PKG_CONFIG_LIBDIR", strings.Join(libDirs, string(os.PathListSeparator)))
+	return nil
+}
+
+// crossFlags returns the compiler and linker flags for cross-compiling
+func crossFlags(o *Options) []string {
+	if isZig(o) {
+		return zigFlags(o)
+	}
+	var flags []string
+	if o.Target != "" && isClang(o) {
+		flags = append(flags, "--target="+o.Target)
+	}
+	if o.Sysroot != "" {
+		flags = append(flags, "--sysroot="+o.Sysroot)
+	}
+	return flags
+}
+
+// toolchainSysroot returns the sysroot that the cross compiler was configured with, like
+// /usr/aarch64-linux-gnu/sys-root on Fedora, or "" if it uses the root of the host, like on Debian
+func toolchainSysroot(o *Options) string {
+	out, err := compilerCommand(o, "-print-sysroot").Output()
+	if err != nil {
+		return ""
+	}
+	if root := strings.TrimSpace(string(out)); root != "" && root != "/" && dirExists(root) {
+		return root
+	}
+	return ""
+}
+
+// crossSystemIncludeDirs returns the directories with the system headers for the target,
+// which are found in the sysroot, or else where the distro installs cross toolchains and multiarch headers
+func crossSystemIncludeDirs(o *Options) []string {
+	root := o.Sysroot
+	if root == "" {
+		root = toolchainSysroot(o)
+	}
+	if root == "" {
+		root = "/"
+	}
+	d := []string{
+		filepath.Join(root, "usr", "include"),
+		filepath.Join(root, "usr", "local", "include"),
+		filepath.Join(root, "usr", "include", o.Target),
+	}
+	if o.Sysroot == "" {
+		d = append(d, filepath.Join("/usr", o.Target, "include"))
+	}
+	return d
+}
+
+// crossOutputName adds the name of the --arm64 or --armhf preset to the name of the executable,
+// so that it is not mixed up with a build for the host
+func crossOutputName(o *Options, name string) string {
+	if o.CrossPreset == "" {
+		return name
+	}
+	return name + "-" + o.CrossPreset
+}
+
+// targetArch returns the architecture that is built for, named like GOARCH
+func targetArch(o *Options) string {
+	if o.Target == "" {
+		return runtime.GOARCH
+	}
+	arch, _, _ := strings.Cut(o.Target, "-")
+	switch {
+	case arch == "aarch64" || arch == "arm64":
+		return "arm64"
+	case strings.HasPrefix(arch, "arm"):
+		return "arm"
+	case arch == "x86_64":
+		return "amd64"
+	case len(arch) == 4 && arch[0] == 'i' && strings.HasSuffix(arch, "86"):
+		return "386"
+	}
+	return arch
+}
@@ -0,0 +1,101 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// isCUDASource checks if the source is CUDA, which is compiled with nvcc
+func isCUDASource(s string) bool {
+	return strings.ToLower(filepath.Ext(s)) == ".cu"
+}
+
+// hasCUDASources checks if any of the sources are CUDA
+func hasCUDASources(srcs []string) bool {
+	for _, s := range srcs {
+		if isCUDASource(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// cudaRoot returns where the CUDA toolkit is installed, from $CUDA_PATH or $CUDA_HOME,
+// or where NVIDIA or the distro places it, or "" if it was not found
+func cudaRoot() string {
+	for _, dir := range []string{os.Getenv("CUDA_PATH"), os.Getenv("CUDA_HOME"), "/usr/local/cuda", "/opt/cuda"} {
+		if dir != "" && fileExists(filepath.Join(dir, "bin", "nvcc")) {
+			return dir
+		}
+	}
+	return ""
+}
+
+// nvccCompiler returns nvcc from the CUDA toolkit, or from PATH, or "" if it is not installed
+func nvccCompiler() string {
+	if root := cudaRoot(); root != "" {
+		return filepath.Join(root, "bin", "nvcc")
+	}
+	if haveCmd("nvcc") {
+		return "nvcc"
+	}
+	return ""
+}
+
+// setupCUDA checks that nvcc is there when there are .cu sources, and adds the include directory
+// and the runtime library of the CUDA toolkit, if it is not installed into /usr like on Debian
+func setupCUDA(o *Options) error {
+	if !hasCUDASources(o.Sources) {
+		return nil
+	}
+	if o.Win64Docker || o.Target != "" || o.Wasm || isZig(o) {
+		return fmt.Errorf("CUDA sources can only be built for the host, with nvcc")
+	}
+	if nvccCompiler() == "" {
+		return fmt.Errorf("nvcc was not found, install CUDA with: %s", installSuggestion(o.DetectedDistro, "nvcc"))
+	}
+	if root := cudaRoot(); root != "" {
+		inc := filepath.Join(root, "include")
+		o.SystemIncludeDirs = append(o.SystemIncludeDirs, inc)
+		o.ExtraCFlags = append(o.ExtraCFlags, "-I"+inc)
+		for _, lib := range []string{"lib64", "lib"} {
+			if dir := filepath.Join(root, lib); dirExists(dir) {
+				o.ExtraLDFlags = append(o.ExtraLDFlags, "-L"+dir, "-Wl,-rpath,"+dir)
+				break
+			}
+		}
+	}
+	o.ExtraLDFlags = append(o.ExtraLDFlags, "-lcudart")
+	return nil
+}
+
+// cudaStd returns the --std flag for nvcc, which only knows about the c++ standards and not the gnu++ ones
+func cudaStd(std string) string {
+	if std == "" {
+		return ""
+	}
+	return "--std=c++" + strings.TrimPrefix(strings.TrimPrefix(std, "gnu++"), "c++")
+}
+
+// cudaCompileCmd returns the nvcc command for compiling the given CUDA source into the given object file.
+// The same compiler is used for the host code as for the rest of the sources, and the flags
+// that nvcc does not know about are passed on to it with -Xcompiler.
+func cudaCompileCmd(o *Options, src, obj string) string {
+	nvcc := nvccCompiler()
+	if o.Launcher != "" {
+		nvcc = o.Launcher + " " + nvcc
+	}
+	host := strings.Fields(compileFlags(o))
+	var direct []string
+	for _, f := range o.ExtraCFlags {
+		if strings.HasPrefix(f, "-I") || strings.HasPrefix(f, "-D") {
+			direct = append(direct, f)
+		} else {
+			host = append(host, f)
+		}
+	}
+	parts := []string{nvcc, cudaStd(o.Std), "-ccbin", compilerExecutable(o), "-Xcompiler", strings.Join(host, ","), includeFlags(o), strings.Join(direct, " "), "-c", src, "-o", obj}
+	return joinNonEmpty(parts)
+}
@@ -0,0 +1,2152 @@
+package cxx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xyproto/distrodetector"
+)
+
+// Version is the version of cxx2
+const Version = "2.0.7"
+
+// Options are the settings for a build, from the defaults, the configuration file and the command line
+type Options struct {
+	CXX               string
+	CC                string
+	Std               string
+	CStd              string
+	Win64Docker       bool
+	Static            bool
+	Wasm              bool
+	Wasi              bool
+	Universal         bool
+	MSVC              bool
+	Zig               bool
+	MPI               bool
+	MPIProcs          int
+	Android           bool
+	AndroidABI        string
+	AndroidAPI        int
+	StaticDocker      bool
+	Debug             bool
+	Strict            bool
+	Sloppy            bool
+	Warnings          string
+	Opt               bool
+	Clang             bool
+	Run               bool
+	Test              bool
+	Clean             bool
+	Pro               bool
+	CompDB            bool
+	Lint              bool
+	Fix               bool
+	Format            bool
+	FormatCheck       bool
+	Analyze           bool
+	IWYU              bool
+	Valgrind          bool
+	Debugger          string
+	Profile           bool
+	Bench             bool
+	BenchFilter       string
+	TestFilter        string
+	TestPaths         []string
+	TestTimeout       time.Duration
+	TAP               bool
+	Retries           int
+	Report            string
+	SARIF             string
+	GitHubAnnotations bool
+	Verbose           bool
+	Quiet             bool
+	Timings           bool
+	Explain           bool
+	ExplainSources    []string
+	Why               bool
+	DryRun            bool
+	Force             bool
+	Baseline          bool
+	Graph             bool
+	GraphFilter       string
+	Mermaid           bool
+	Show              bool
+	Small             bool
+	UPX               bool
+	SplitDebug        bool
+	Reproducible      bool
+	March             string
+	ProgramArgs       []string
+	Ninja             bool
+	Makefile          bool
+	Coverage          bool
+	PGO               string
+	LTO               bool
+	Launcher          string
+	Linker            string
+	CacheStats        bool
+	Stats             bool
+	Jobs              int
+	Distributed       bool
+	Daemon            bool
+	Init              bool
+	Doctor            bool
+	InstallDeps       bool
+	Fetch             bool
+	AssumeYes         bool
+	InitTemplate      string
+	CoverageHTML      bool
+	Meson             bool
+	CMake             bool
+	Lib               bool
+	Shared            bool
+	LibVersion        string
+	LibName           string
+	Install           bool
+	Uninstall         bool
+	Prefix            string
+	DestDir           string
+	Version           bool
+	MainSource        string
+	OutputName        string
+	DetectedDistro    string
+	Target            string
+	CrossPreset       string
+	Sysroot           string
+	ContainerImage    string
+	NASMFormat        string
+	EmbedShaders      bool
+	PCH               string
+	PCHFlags          string
+	Modules           map[string]*ModuleUnit
+	ImportStd         bool
+	HeaderUnits       map[string]string
+	Remote            string
+	Sources           []string
+	TestSources       []string
+	BenchSources      []string
+	IncludeDirs       []string
+	SystemIncludeDirs []string
+	ExtraCFlags       []string
+	CFlags            []string
+	CXXFlags          []string
+	ExtraLDFlags      []string
+	PkgConfigPackages []string
+	PkgConfigFlags    []string
+	Exclude           []string
+	Targets           []*Target
+	BuildDir          string
+	Config            *Config
+	state             *buildState
+}
+
+// CompileCache holds the modification times of the sources when they were last compiled
+type CompileCache struct {
+	Timestamps map[string]int64  `json:"timestamps"`
+	Commands   map[string]string `json:"commands,omitempty"` // the command that each source was last compiled with
+	mu         sync.Mutex
+}
+
+var stdIncludesSkipList = []string{
+	"algorithm", "array", "barrier", "bit", "bitset", "cassert", "ccomplex",
+	"cctype", "cerrno", "cfenv", "cfloat", "chrono", "cinttypes", "ciso646",
+	"climits", "clocale", "cmath", "codecvt", "complex", "condition_variable",
+	"coroutine", "cstdio", "cstdlib", "cstring", "ctime", "cwchar", "cwctype",
+	"deque", "exception", "execution", "filesystem", "format", "forward_list",
+	"fstream", "functional", "future", "initializer_list", "iomanip", "ios",
+	"iosfwd", "iostream", "istream", "iterator", "latch", "limits", "list",
+	"locale", "map", "memory", "mutex", "new", "numbers", "numeric", "optional",
+	"ostream", "queue", "random", "ranges", "ratio", "regex", "scoped_allocator",
+	"semaphore", "set", "shared_mutex", "source_location", "span", "sstream",
+	"stack", "stdexcept", "steambuf", "stop_token", "streambuf", "string",
+	"string_view", "strstream", "syncstream", "system_error", "tgmath",
+	"thread", "tuple", "type_traits", "typeindex", "typeinfo", "unordered_map",
+	"unordered_set", "utility", "valarray", "variant", "vector", "version",
+	"atomic",
+}
+
+// build does everything that the given options ask for, from discovering the sources
+// to building, testing, installing and running
+func build(opts *Options) error {
+	if opts.Version {
+		fmt.Printf("cxx2 version %s\n", Version)
+		return nil
+	}
+	if opts.Uninstall {
+		if err := uninstallTargets(opts); err != nil {
+			return fmt.Errorf("uninstall error: %w", err)
+		}
+		return nil
+	}
+	opts.DetectedDistro = detectDistro(opts)
+	// Before the subdirectory for the build mode is added, this is where the history of the builds is kept
+	buildDir := opts.BuildDir
+	if opts.Init {
+		return initProject(opts)
+	}
+	adjustCompiler(opts)
+	if err := setupWasi(opts); err != nil {
+		return fmt.Errorf("wasi error: %w", err)
+	}
+	if err := setupAndroid(opts); err != nil {
+		return fmt.Errorf("android error: %w", err)
+	}
+	if err := setupZig(opts); err != nil {
+		return fmt.Errorf("zig error: %w", err)
+	}
+	if err := setupCross(opts); err != nil {
+		return fmt.Errorf("cross compilation error: %w", err)
+	}
+	if err := setupUniversal(opts); err != nil {
+		return fmt.Errorf("universal build error: %w", err)
+	}
+	if err := setupMSVC(opts); err != nil {
+		return fmt.Errorf("msvc error: %w", err)
+	}
+	setupMinGW(opts)
+	if opts.Reproducible {
+		setupReproducible(opts)
+	}
+	setupMarch(opts)
+	if err := setupWasm(opts); err != nil {
+		return fmt.Errorf("wasm error: %w", err)
+	}
+	opts.Launcher = resolveLauncher(opts)
+	linker, err := resolveLinker(opts)
+	if err != nil {
+		return fmt.Errorf("linker error: %w", err)
+	}
+	opts.Linker = linker
+	if opts.Distributed {
+		setupDistributed(opts)
+	}
+	if opts.Jobs <= 0 {
+		opts.Jobs = runtime.NumCPU()
+	}
+
+	if opts.Doctor {
+		return runDoctor(opts)
+	}
+
+	if opts.Format {
+		return runFormat(opts)
+	}
+
+	if opts.CacheStats {
+		if err := showCacheStats(opts); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	if opts.Stats {
+		return showStats(opts)
+	}
+
+	if err := checkStd(opts); err != nil {
+		return fmt.Errorf("standard error: %w", err)
+	}
+
+	srcs, err := discoverSources(opts)
+	if err != nil {
+		return err
+	}
+	srcs = excludeSources(srcs, opts.Exclude)
+	// Benchmarks have their own main functions and are only built by "bench"
+	var benchSources, others []string
+	for _, s := range srcs {
+		if isBenchSource(s) {
+			benchSources = append(benchSources, s)
+		} else {
+			others = append(others, s)
+		}
+	}
+	srcs = others
+	if opts.Bench {
+		opts.BenchSources = benchSources
+	}
+	if len(srcs) == 0 && !opts.Clean {
+		fmt.Println("No sources found.")
+		return nil
+	}
+
+	var normalSources, testSources []string
+	for _, s := range srcs {
+		if isTestSource(s) {
+			testSources = append(testSources, s)
+		} else {
+			normalSources = append(normalSources, s)
+		}
+	}
+	opts.Sources = srcs
+	opts.TestSources = testSources
+	opts.Targets = discoverTargets(normalSources, opts.Win64Docker)
+	if len(opts.Targets) > 0 {
+		// Only a top level source with a main function is built as the primary executable
+		normalSources = sharedSources(opts, normalSources)
+		for _, s := range normalSources {
+			if hasMainFunction(s) {
+				opts.MainSource = s
+				break
+			}
+		}
+	} else {
+		opts.MainSource = findMainSource(srcs)
+	}
+
+	// Without a main function, build a library instead of an executable
+	if opts.Shared || (opts.MainSource == "" && len(normalSources) > 0 && len(opts.Targets) == 0) {
+		opts.Lib = true
+	}
+
+	if opts.Lib {
+		opts.LibName = libraryBaseName(opts.OutputName)
+	}
+	if opts.Shared && opts.Android {
+		opts.OutputName = androidLibraryName(opts.LibName)
+	} else if opts.Shared {
+		opts.OutputName = sharedLibraryName(opts.LibName, opts.LibVersion, opts.Win64Docker)
+	} else if opts.Lib && isMSVC(opts) {
+		opts.OutputName = opts.LibName + ".lib"
+	} else if opts.Lib {
+		opts.OutputName = staticLibraryName(opts.LibName)
+	} else if opts.OutputName != "" {
+		opts.OutputName = wasmOutputName(opts, ensureExeSuffix(opts.OutputName, opts.Win64Docker))
+	} else if opts.MainSource != "" {
+		opts.OutputName = wasmOutputName(opts, crossOutputName(opts, guessOutputNameFromMain(opts.MainSource, opts.Win64Docker)))
+	}
+	for _, t := range opts.Targets {
+		t.Output = wasmOutputName(opts, t.Output)
+	}
+
+	if opts.Clean {
+		removeArtifacts(opts)
+		return nil
+	}
+
+	if opts.Target != "" {
+		// Objects for other architectures are kept apart from the ones for the host
+		opts.BuildDir = filepath.Join(opts.BuildDir, opts.Target)
+	} else if opts.Wasm {
+		opts.BuildDir = filepath.Join(opts.BuildDir, "wasm")
+	}
+	if opts.Debugger != "" {
+		// Objects that were built without debug info are not used for debugging
+		opts.BuildDir = filepath.Join(opts.BuildDir, "debug")
+	} else if opts.Profile {
+		// Kept apart, since they are built with frame pointers
+		opts.BuildDir = filepath.Join(opts.BuildDir, "profile")
+	} else if opts.Bench {
+		// Benchmarks are always built with optimizations
+		opts.BuildDir = filepath.Join(opts.BuildDir, "bench")
+	} else if opts.Small {
+		// Built with other optimizations and with every function in its own section
+		opts.BuildDir = filepath.Join(opts.BuildDir, "small")
+	}
+	if opts.Static {
+		if err := setupStatic(opts); err != nil {
+			return fmt.Errorf("static build error: %w", err)
+		}
+		// Static executables may be built with another C library, so the objects are kept apart
+		opts.BuildDir = filepath.Join(opts.BuildDir, "static")
+	}
+	if opts.Coverage {
+		// Instrumented objects are kept apart from the regular ones
+		opts.BuildDir = filepath.Join(opts.BuildDir, "coverage")
+	}
+	if opts.LTO {
+		if ltoSupported(opts) {
+			// LTO objects contain intermediate code, so they are kept apart from the regular ones
+			opts.BuildDir = filepath.Join(opts.BuildDir, "lto")
+		} else {
+			fmt.Printf("%s does not support link-time optimization, building without it\n", opts.CXX)
+			opts.LTO = false
+		}
+	}
+	if opts.PGO != "" {
+		if err := preparePGOBuild(opts); err != nil {
+			return fmt.Errorf("PGO error: %w", err)
+		}
+	}
+	if opts.Force {
+		forceRebuild(opts)
+	}
+	opts.state.baseline = loadBaseline(opts)
+
+	if !exporting(opts) {
+		ran, err := runHooks(opts, "prebuild")
+		if err != nil {
+			return err
+		}
+		if ran {
+			normalSources = append(normalSources, addGeneratedSources(opts)...)
+		}
+	}
+
+	if opts.Wasi {
+		opts.SystemIncludeDirs = wasiSystemIncludeDirs(opts)
+	} else if opts.Android {
+		opts.SystemIncludeDirs = androidSystemIncludeDirs(opts)
+	} else if opts.Target != "" {
+		opts.SystemIncludeDirs = crossSystemIncludeDirs(opts)
+	} else if isMSVC(opts) {
+		opts.SystemIncludeDirs = msvcSystemIncludeDirs()
+	} else if onWindows(opts) {
+		opts.SystemIncludeDirs = mingwSystemIncludeDirs(opts)
+	} else if opts.Wasm {
+		opts.SystemIncludeDirs = wasmSystemIncludeDirs(opts)
+	} else {
+		opts.SystemIncludeDirs = discoverSystemIncludeDirs()
+	}
+	opts.IncludeDirs = append(opts.IncludeDirs, discoverLocalIncludeDirs()...)
+	if onMacOS(opts) {
+		setupMacOS(opts)
+	} else if onBSD(opts) {
+		setupBSD(opts)
+	}
+	if err := setupVcpkg(opts); err != nil {
+		return fmt.Errorf("vcpkg error: %w", err)
+	}
+	if err := setupConan(opts); err != nil {
+		return fmt.Errorf("conan error: %w", err)
+	}
+	if err := setupCUDA(opts); err != nil {
+		return fmt.Errorf("cuda error: %w", err)
+	}
+	if err := setupHIP(opts); err != nil {
+		return fmt.Errorf("hip error: %w", err)
+	}
+	if err := setupAsm(opts); err != nil {
+		return fmt.Errorf("assembly error: %w", err)
+	}
+	setupOpenMP(opts)
+	if err := setupMPI(opts); err != nil {
+		return fmt.Errorf("mpi error: %w", err)
+	}
+	qtSources, err := setupQt(opts)
+	if err != nil {
+		return fmt.Errorf("qt error: %w", err)
+	}
+	normalSources = append(normalSources, qtSources...)
+	grammarSources, err := setupGrammars(opts)
+	if err != nil {
+		return fmt.Errorf("grammar error: %w", err)
+	}
+	normalSources = append(normalSources, grammarSources...)
+	if err := setupShaders(opts); err != nil {
+		return fmt.Errorf("shader error: %w", err)
+	}
+	if err := setupEmbed(opts); err != nil {
+		return fmt.Errorf("embed error: %w", err)
+	}
+	if err := setupBuildInfo(opts); err != nil {
+		return fmt.Errorf("build info error: %w", err)
+	}
+	resources, err := setupResources(opts)
+	if err != nil {
+		return fmt.Errorf("resource error: %w", err)
+	}
+	normalSources = append(normalSources, resources...)
+	if err := setupModules(opts); err != nil {
+		return fmt.Errorf("module error: %w", err)
+	}
+	setupTestFrameworks(opts)
+	lock, err := readLock()
+	if err != nil {
+		return fmt.Errorf("lockfile error: %w", err)
+	}
+	if err := setupGitDependencies(opts, lock); err != nil {
+		return fmt.Errorf("dependency error: %w", err)
+	}
+	if err := lock.verifyFetched(); err != nil {
+		return fmt.Errorf("lockfile error: %w", err)
+	}
+
+	incls := gatherAllIncludes(opts, append(opts.Sources, opts.BenchSources...))
+	missing := missingHeaders(opts, incls)
+	if len(missing) > 0 && opts.InstallDeps {
+		installed, err := installDependencies(opts, missing)
+		if err != nil {
+			return fmt.Errorf("install error: %w", err)
+		}
+		if installed {
+			missing = missingHeaders(opts, missing)
+		}
+	}
+	if len(missing) > 0 && opts.Fetch {
+		if missing, err = fetchHeaders(opts, missing, lock); err != nil {
+			return fmt.Errorf("fetch error: %w", err)
+		}
+	}
+	if err := lock.save(opts); err != nil {
+		return fmt.Errorf("lockfile error: %w", err)
+	}
+	if len(missing) > 0 {
+		if err := pkgDiscovery(opts, missing); err != nil {
+			return err
+		}
+	}
+
+	if opts.Pro {
+		if err := generateProFile(opts, normalSources); err != nil {
+			fmt.Println("Could not generate .pro:", err)
+		}
+		return nil
+	}
+
+	if opts.CompDB {
+		if err := writeCompilationDatabase(opts); err != nil {
+			return fmt.Errorf("could not write compile_commands.json: %w", err)
+		}
+		fmt.Println("Wrote compile_commands.json")
+		return nil
+	}
+
+	if opts.Lint {
+		return runLint(opts)
+	}
+
+	if opts.Analyze {
+		return runAnalyze(opts)
+	}
+
+	if opts.IWYU {
+		return runIWYU(opts)
+	}
+
+	if opts.Ninja {
+		if err := generateNinjaFile(opts); err != nil {
+			return fmt.Errorf("could not write build.ninja: %w", err)
+		}
+		fmt.Println("Wrote build.ninja")
+		return nil
+	}
+
+	if opts.Graph {
+		return printGraph(opts)
+	}
+
+	if opts.Show {
+		return showConfiguration(opts)
+	}
+
+	if opts.Makefile {
+		if err := generateMakefile(opts); err != nil {
+			return fmt.Errorf("could not write Makefile: %w", err)
+		}
+		fmt.Println("Wrote Makefile")
+		return nil
+	}
+
+	if opts.CMake {
+		if err := generateCMakeLists(opts); err != nil {
+			return fmt.Errorf("could not write CMakeLists.txt: %w", err)
+		}
+		fmt.Println("Wrote CMakeLists.txt")
+		return nil
+	}
+
+	if opts.Meson {
+		if err := generateMesonBuild(opts, incls); err != nil {
+			return fmt.Errorf("could not write meson.build: %w", err)
+		}
+		fmt.Println("Wrote meson.build")
+		return nil
+	}
+
+	if err := prepareBuildDir(opts); err != nil {
+		return err
+	}
+	cc, _ := loadCache(opts)
+
+	if opts.Universal {
+		if err := buildUniversal(opts, normalSources, testSources); err != nil {
+			return fmt.Errorf("build error: %w", err)
+		}
+	} else {
+		if err := buildGitDependencies(opts, cc); err != nil {
+			return fmt.Errorf("dependency error: %w", err)
+		}
+		if opts.Config != nil && len(opts.Config.Dependencies) > 0 {
+			saveCache(opts, cc)
+		}
+		if err := buildPCH(opts); err != nil {
+			return fmt.Errorf("precompiled header error: %w", err)
+		}
+		if err := buildHeaderUnits(opts); err != nil {
+			return fmt.Errorf("header unit error: %w", err)
+		}
+		if opts.Explain {
+			// After the steps above, since the compile commands depend on them
+			return explainRebuilds(opts, cc)
+		}
+		if err := buildOutputs(opts, cc, normalSources, testSources); err != nil {
+			return fmt.Errorf("build error: %w", err)
+		}
+	}
+	if opts.Lib {
+		if err := writePackageFiles(opts); err != nil {
+			return err
+		}
+	}
+
+	if opts.Static {
+		if err := verifyStaticOutputs(opts); err != nil {
+			return fmt.Errorf("static build error: %w", err)
+		}
+	}
+
+	if opts.SplitDebug {
+		if err := splitDebugInfo(opts); err != nil {
+			return fmt.Errorf("split debug info error: %w", err)
+		}
+	}
+	if opts.Small {
+		if err := stripOutputs(opts, buildDir); err != nil {
+			return fmt.Errorf("strip error: %w", err)
+		}
+	}
+	if opts.UPX {
+		compressOutputs(opts)
+	}
+
+	if _, err := runHooks(opts, "postbuild"); err != nil {
+		return err
+	}
+
+	if opts.Coverage {
+		prepareCoverageRun(opts)
+	}
+
+	if opts.Test && len(testSources) > 0 {
+		if err := buildAndRunTests(opts, cc); err != nil {
+			return fmt.Errorf("test error: %w", err)
+		}
+	}
+
+	if opts.Bench {
+		if len(opts.BenchSources) == 0 {
+			fmt.Println("No benchmarks found, name them like sort_bench.cpp.")
+		} else if err := buildAndRunBenchmarks(opts, cc); err != nil {
+			return fmt.Errorf("benchmark error: %w", err)
+		}
+	}
+
+	if opts.Coverage {
+		if len(testSources) == 0 && opts.MainSource != "" {
+			// Without tests, measure the coverage of running the program
+			if err := runProgram(opts, opts.OutputName); err != nil {
+				return err
+			}
+		}
+		if err := coverageReport(opts); err != nil {
+			return fmt.Errorf("coverage error: %w", err)
+		}
+	}
+
+	if opts.PGO == "gen" {
+		if len(testSources) == 0 && opts.MainSource != "" {
+			// Without tests, collect the profiles by running the program
+			if err := runProgram(opts, opts.OutputName); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("Profiles were written to %s, build with pgo-use to make use of them\n", pgoProfileDir(opts))
+	}
+
+	if opts.Install {
+		if err := installTargets(opts); err != nil {
+			return fmt.Errorf("install error: %w", err)
+		}
+	}
+
+	if opts.Debugger != "" {
+		if exe := programToRun(opts); exe != "" {
+			return runDebugger(opts, exe)
+		}
+	} else if opts.Run {
+		if exe := programToRun(opts); exe != "" {
+			if err := runProgram(opts, exe); err != nil {
+				return err
+			}
+		}
+	}
+	done := "Build complete"
+	if opts.DryRun {
+		done = "Dry run complete"
+	}
+	fmt.Printf("%s on %s\n", colorize(opts, ansiBold+ansiGreen, done), opts.DetectedDistro)
+	return nil
+}
+
+// buildOutputs builds the library, the executables of the targets or the executable, and saves the cache
+func buildOutputs(o *Options, cc *CompileCache, normalSources, testSources []string) error {
+	if o.Shared {
+		if err := buildSharedLibrary(o, cc); err != nil {
+			return err
+		}
+	} else if o.Lib {
+		if err := buildStaticLibrary(o, cc); err != nil {
+			return err
+		}
+	} else if len(o.Targets) > 0 {
+		if err := buildTargets(o, cc); err != nil {
+			return err
+		}
+	} else if len(normalSources) == 1 && len(testSources) == 0 && !o.Test && !o.Coverage && o.PGO == "" && !isMSVC(o) && !isCUDASource(normalSources[0]) && !isHIPSource(o, normalSources[0]) && !isAsmSource(normalSources[0]) && !isResourceSource(normalSources[0]) && len(o.Modules) == 0 && len(o.HeaderUnits) == 0 {
+		// If there's exactly 1 normal source, no test sources, do single-step build (no partial detection).
+		return singleStepBuild(o, normalSources[0])
+	} else if err := compileAndLink(o, cc); err != nil {
+		return err
+	}
+	saveCache(o, cc)
+	return nil
+}
+
+// programToRun returns the executable that "run" should start, or "" if there is none
+func programToRun(opts *Options) string {
+	if opts.MainSource == "" && len(opts.Targets) > 0 {
+		fmt.Println("Several executables were built, but none of them is the main one:", strings.Join(targetOutputs(opts), " "))
+		return ""
+	}
+	if opts.OutputName == "" {
+		return ""
+	}
+	fmt.Println("Running:", opts.OutputName)
+	if opts.Lib {
+		fmt.Println("A library can't be run.")
+		return ""
+	}
+	if opts.Win64Docker && wineCommand() == "" {
+		fmt.Println("Cross-compiled .exe can't be run automatically, install wine to run it.")
+		return ""
+	}
+	if opts.Android {
+		printAdbUsage(opts.OutputName)
+		return ""
+	}
+	if opts.Target != "" && !opts.Wasi {
+		fmt.Printf("An executable that is cross-compiled for %s can't be run automatically.\n", opts.Target)
+		return ""
+	}
+	return opts.OutputName
+}
+
+// DefaultOptions returns the options that are used when nothing else is configured,
+// with PREFIX and DESTDIR taken from the environment
+func DefaultOptions() *Options {
+	o := &Options{CXX: defaultCompiler(), Std: "c++20", CStd: defaultCStd, LibVersion: "1.0.0", BuildDir: defaultBuildDir, Prefix: "/usr/local", DestDir: os.Getenv("DESTDIR"), state: &buildState{}}
+	if prefix := os.Getenv("PREFIX"); prefix != "" {
+		o.Prefix = prefix
+	}
+	return o
+}
+
+// ParseArgs returns the options for the given command line arguments, applied on top of
+// the defaults, the project configuration file and the $CXX, $CC, $CXXFLAGS, $CFLAGS, $CPPFLAGS and
+// $LDFLAGS environment variables, unless --no-env is given
+func ParseArgs(args []string) (*Options, error) {
+	o := DefaultOptions()
+	cfg, err := loadConfig(configPath(args))
+	if err != nil {
+		return nil, err
+	}
+	if cfg != nil {
+		cfg.apply(o)
+		o.Config = cfg
+	}
+	if !ignoreEnvironment(args) {
+		applyEnvironment(o)
+	}
+	for i, arg := range args {
+		if arg == "--" {
+			// The rest of the arguments are for the program
+			o.ProgramArgs = args[i+1:]
+			break
+		}
+		switch arg {
+		case "build":
+			// Building is what happens by default
+		case "daemon":
+			o.Daemon = true
+		case "init":
+			o.Init = true
+		case "doctor":
+			o.Doctor = true
+		case "run":
+			o.Run = true
+		case "test":
+			o.Test = true
+		case "clean":
+			o.Clean = true
+		case "pro":
+			o.Pro = true
+		case "compdb":
+			o.CompDB = true
+		case "lint":
+			o.Lint = true
+		case "debugger":
+			o.Debugger = "auto"
+			o.Debug = true
+		case "bench":
+			o.Bench = true
+			o.Opt = true
+		case "--verbose", "-v":
+			o.Verbose = true
+		case "explain":
+			o.Explain = true
+		case "--force", "-B":
+			o.Force = true
+		case "--no-env":
+			// Handled by ignoreEnvironment
+		case "baseline":
+			o.Baseline = true
+		case "--dry-run":
+			o.DryRun = true
+		case "--why":
+			o.Why = true
+		case "--timings":
+			o.Timings = true
+		case "--quiet", "-q":
+			o.Quiet = true
+		case "--github-annotations":
+			o.GitHubAnnotations = true
+		case "--tap":
+			o.TAP = true
+		case "--profile":
+			o.Profile = true
+		case "--valgrind":
+			o.Valgrind = true
+		case "iwyu":
+			o.IWYU = true
+		case "analyze":
+			o.Analyze = true
+		case "fmt":
+			o.Format = true
+		case "--check":
+			o.FormatCheck = true
+		case "--fix":
+			o.Fix = true
+		case "ninja":
+			o.Ninja = true
+		case "graph":
+			o.Graph = true
+		case "show":
+			o.Show = true
+		case "--mermaid":
+			o.Mermaid = true
+		case "make":
+			o.Makefile = true
+		case "cmake":
+			o.CMake = true
+		case "meson":
+			o.Meson = true
+		case "coverage":
+			o.Coverage = true
+			o.Test = true
+		case "--html":
+			o.CoverageHTML = true
+		case "pgo-gen":
+			o.PGO = "gen"
+			o.Opt = true
+			o.Test = true
+		case "lto":
+			o.LTO = true
+		case "cache-stats":
+			o.CacheStats = true
+		case "stats":
+			o.Stats = true
+		case "--distributed":
+			o.Distributed = true
+		case "--fetch":
+			o.Fetch = true
+		case "--install-deps":
+			o.InstallDeps = true
+		case "--yes", "-y":
+			o.AssumeYes = true
+		case "pgo-use":
+			o.PGO = "use"
+			o.Opt = true
+		case "lib":
+			o.Lib = true
+		case "shared":
+			o.Shared = true
+		case "install":
+			o.Install = true
+		case "uninstall":
+			o.Uninstall = true
+		case "--version", "version":
+			o.Version = true
+		case "import-std", "--import-std":
+			o.ImportStd = true
+		case "--embed-shaders":
+			o.EmbedShaders = true
+		case "debug":
+			o.Debug = true
+		case "strict":
+			o.Strict = true
+		case "sloppy":
+			o.Sloppy = true
+		case "opt":
+			o.Opt = true
+		case "small":
+			o.Small = true
+		case "--upx":
+			o.UPX = true
+		case "--split-debug":
+			o.SplitDebug = true
+		case "--reproducible":
+			o.Reproducible = true
+		case "clang":
+			o.Clang = true
+		case "static":
+			o.Static = true
+		case "--wasm":
+			o.Wasm = true
+		case "--wasi":
+			o.Wasi = true
+		case "--universal":
+			o.Universal = true
+		case "msvc", "--msvc":
+			o.MSVC = true
+		case "zig", "--zig":
+			o.Zig = true
+		case "mpi", "--mpi":
+			o.MPI = true
+		case "--android":
+			o.Android = true
+		case "--arm64":
+			o.CrossPreset = "arm64"
+		case "--armhf":
+			o.CrossPreset = "armhf"
+		case "--win64-docker":
+			o.Win64Docker = true
+			o.CXX = "x86_64-w64-mingw32-g++"
+			o.CC = ""
+		default:
+			if strings.HasPrefix(arg, "--cxx=") {
+				o.CXX = strings.TrimPrefix(arg, "--cxx=")
+			} else if strings.HasPrefix(arg, "cxx=") {
+				o.CXX = strings.TrimPrefix(arg, "cxx=")
+			} else if strings.HasPrefix(arg, "--cc=") {
+				o.CC = strings.TrimPrefix(arg, "--cc=")
+			} else if strings.HasPrefix(arg, "--jobs=") {
+				o.Jobs, _ = strconv.Atoi(strings.TrimPrefix(arg, "--jobs="))
+			} else if strings.HasPrefix(arg, "-j") {
+				o.Jobs, _ = strconv.Atoi(strings.TrimPrefix(arg, "-j"))
+			} else if strings.HasPrefix(arg, "--target=") {
+				o.Target = strings.TrimPrefix(arg, "--target=")
+			} else if strings.HasPrefix(arg, "--sysroot=") {
+				o.Sysroot = strings.TrimPrefix(arg, "--sysroot=")
+			} else if strings.HasPrefix(arg, "--abi=") {
+				o.AndroidABI = strings.TrimPrefix(arg, "--abi=")
+			} else if strings.HasPrefix(arg, "--api=") {
+				o.AndroidAPI, _ = strconv.Atoi(strings.TrimPrefix(arg, "--api="))
+			} else if strings.HasPrefix(arg, "--np=") {
+				o.MPI = true
+				o.MPIProcs, _ = strconv.Atoi(strings.TrimPrefix(arg, "--np="))
+			} else if strings.HasPrefix(arg, "--remote=") {
+				o.Remote = strings.TrimPrefix(arg, "--remote=")
+			} else if strings.HasPrefix(arg, "--container-image=") {
+				o.ContainerImage = strings.TrimPrefix(arg, "--container-image=")
+			} else if strings.HasPrefix(arg, "--debugger=") {
+				o.Debugger = strings.TrimPrefix(arg, "--debugger=")
+				o.Debug = true
+			} else if strings.HasPrefix(arg, "--timeout=") {
+				if o.TestTimeout, err = parseTimeout(strings.TrimPrefix(arg, "--timeout=")); err != nil {
+					return nil, err
+				}
+			} else if strings.HasPrefix(arg, "--retries=") {
+				o.Retries, _ = strconv.Atoi(strings.TrimPrefix(arg, "--retries="))
+			} else if strings.HasPrefix(arg, "--report=") {
+				o.Report = strings.TrimPrefix(arg, "--report=")
+				if !validReport(o.Report) {
+					return nil, fmt.Errorf("--report= takes json, for writing the report to stdout, or a .json file")
+				}
+			} else if strings.HasPrefix(arg, "--sarif=") {
+				o.SARIF = strings.TrimPrefix(arg, "--sarif=")
+			} else if strings.HasPrefix(arg, "--filter=") {
+				o.TestFilter = strings.TrimPrefix(arg, "--filter=")
+			} else if strings.HasPrefix(arg, "--pch=") {
+				o.PCH = strings.TrimPrefix(arg, "--pch=")
+			} else if strings.HasPrefix(arg, "--nasm-format=") {
+				o.NASMFormat = strings.TrimPrefix(arg, "--nasm-format=")
+			} else if strings.HasPrefix(arg, "std=") || strings.HasPrefix(arg, "--std=") {
+				if err := setStd(o, arg[strings.Index(arg, "=")+1:]); err != nil {
+					return nil, err
+				}
+			} else if isStdName(arg) {
+				setStd(o, arg)
+			} else if strings.HasPrefix(arg, "--warnings=") {
+				o.Warnings = strings.TrimPrefix(arg, "--warnings=")
+			} else if strings.HasPrefix(arg, "--march=") {
+				o.March = strings.TrimPrefix(arg, "--march=")
+			} else if strings.HasPrefix(arg, "--linker=") {
+				o.Linker = strings.TrimPrefix(arg, "--linker=")
+			} else if strings.HasPrefix(arg, "--launcher=") {
+				o.Launcher = strings.TrimPrefix(arg, "--launcher=")
+			} else if strings.HasPrefix(arg, "--build-dir=") {
+				o.BuildDir = strings.TrimPrefix(arg, "--build-dir=")
+			} else if strings.HasPrefix(arg, "--prefix=") {
+				o.Prefix = strings.TrimPrefix(arg, "--prefix=")
+			} else if strings.HasPrefix(arg, "--destdir=") {
+				o.DestDir = strings.TrimPrefix(arg, "--destdir=")
+			} else if o.Init && o.InitTemplate == "" && !strings.HasPrefix(arg, "-") {
+				o.InitTemplate = arg
+			} else if o.Graph && o.GraphFilter == "" && !strings.HasPrefix(arg, "-") {
+				o.GraphFilter = filepath.Clean(arg)
+			} else if o.Explain && !strings.HasPrefix(arg, "-") {
+				o.ExplainSources = append(o.ExplainSources, filepath.Clean(arg))
+			} else if o.Test && isTestSource(arg) {
+				o.TestPaths = append(o.TestPaths, filepath.Clean(arg))
+			} else if o.Bench && o.BenchFilter == "" && !strings.HasPrefix(arg, "-") {
+				o.BenchFilter = arg
+			}
+		}
+	}
+	if err := resolveWarnings(o); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func detectDistro(o *Options) string {
+	if o.state.memory != nil {
+		return o.state.memory.distro()
+	}
+	return distrodetector.New().String()
+}
+
+func adjustCompiler(o *Options) {
+	if o.Clang && !o.Win64Docker {
+		o.CXX = "clang++"
+		if o.CC != "" {
+			o.CC = "clang"
+		}
+	}
+}
+
+func discoverSources(o *Options) ([]string, error) {
+	if o.state.memory != nil {
+		return o.state.memory.sources(o.BuildDir)
+	}
+	srcs, _, err := scanSources(o.BuildDir)
+	return srcs, err
+}
+
+// scanSources walks the project and returns the sources, together with the directories that were visited
+func scanSources(buildDir string) ([]string, []string, error) {
+	var out, dirs []string
+	buildDir = filepath.Clean(buildDir)
+	err := filepath.WalkDir(".", func(path string, d fs.DirEntry, e error) error {
+		if e != nil || d.IsDir() {
+			if d != nil && d.IsDir() && path != "." && (strings.HasPrefix(d.Name(), ".") || path == buildDir || isManagedDependency(path)) {
+				return filepath.SkipDir
+			}
+			if e == nil {
+				dirs = append(dirs, path)
+			}
+			return nil
+		}
+		l := strings.ToLower(path)
+		if strings.HasSuffix(l, ".c") || strings.HasSuffix(l, ".cc") ||
+			strings.HasSuffix(l, ".cpp") || strings.HasSuffix(l, ".cxx") || strings.HasSuffix(l, ".cu") || strings.HasSuffix(l, ".hip") ||
+			strings.HasSuffix(l, ".s") || strings.HasSuffix(l, ".asm") ||
+			strings.HasSuffix(l, ".cppm") || strings.HasSuffix(l, ".ixx") || strings.HasSuffix(l, ".mpp") {
+			out = append(out, path)
+		}
+		return nil
+	})
+	return out, dirs, err
+}
+
+// walkProject calls visit for every file in the project, skipping the same directories as scanSources
+func walkProject(buildDir string, visit func(path string)) error {
+	buildDir = filepath.Clean(buildDir)
+	return filepath.WalkDir(".", func(path string, d fs.DirEntry, e error) error {
+		if e != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != "." && (strings.HasPrefix(d.Name(), ".") || path == buildDir || isManagedDependency(path)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		visit(path)
+		return nil
+	})
+}
+
+func isTestSource(s string) bool {
+	l := strings.ToLower(filepath.Base(s))
+	if strings.HasSuffix(l, "_test.cpp") || strings.HasSuffix(l, "_test.cc") ||
+		strings.HasSuffix(l, "_test.cxx") || strings.HasSuffix(l, "_test.c") || strings.HasSuffix(l, "_test.cu") || strings.HasSuffix(l, "_test.hip") {
+		return true
+	}
+	switch l {
+	case "test.cpp", "test.cc", "test.cxx", "test.c", "test.cu", "test.hip":
+		return true
+	}
+	return false
+}
+
+func findMainSource(srcs []string) string {
+	for _, s := range srcs {
+		l := strings.ToLower(filepath.Base(s))
+		if l == "main.cpp" || l == "main.cc" || l == "main.cxx" || l == "main.c" || l == "main.cu" || l == "main.hip" {
+			return s
+		}
+	}
+	var nt []string
+	for _, s := range srcs {
+		if !isTestSource(s) {
+			nt = append(nt, s)
+		}
+	}
+	// Also for a single source, which is built as a library if it has no main function
+	for _, s := range nt {
+		if hasMainFunction(s) {
+			return s
+		}
+	}
+	return ""
+}
+
+func hasMainFunction(src string) bool {
+	b, e := os.ReadFile(src)
+	return e == nil && strings.Contains(string(b), " main(")
+}
+
+func guessOutputNameFromMain(mainSrc string, docker bool) string {
+	dir, _ := os.Getwd()
+	b := filepath.Base(dir)
+	if b == "src" {
+		b = strings.TrimSuffix(filepath.Base(mainSrc), filepath.Ext(mainSrc))
+		if b == "" {
+			b = "main"
+		}
+	}
+	if docker && !strings.HasSuffix(b, ".exe") {
+		b += ".exe"
+	} else if runtime.GOOS == "windows" && !strings.HasSuffix(b, ".exe") {
+		b += ".exe"
+	}
+	return b
+}
+
+func removeArtifacts(o *Options) {
+	removeBuildDir(o)
+	filepath.WalkDir(".", func(p string, d fs.DirEntry, e error) error {
+		if e != nil || d.IsDir() {
+			return nil
+		}
+		l := strings.ToLower(d.Name())
+		if strings.HasSuffix(l, ".o") || strings.HasSuffix(l, ".obj") {
+			fmt.Printf("Removing %s\n", p)
+			removeFile(o, p)
+		}
+		return nil
+	})
+	if o.Shared {
+		for _, l := range sharedLibraryLinks(o.OutputName, o.LibVersion) {
+			if _, e := os.Lstat(l); e == nil {
+				fmt.Printf("Removing %s\n", l)
+				removeFile(o, l)
+			}
+		}
+	}
+	if o.OutputName != "" {
+		for _, fn := range append([]string{o.OutputName}, wasmCompanions(o.OutputName)...) {
+			if fileExists(fn) {
+				fmt.Printf("Removing %s\n", fn)
+				removeFile(o, fn)
+			}
+		}
+	}
+	for _, t := range o.Targets {
+		if fileExists(t.Output) {
+			fmt.Printf("Removing %s\n", t.Output)
+			removeFile(o, t.Output)
+		}
+	}
+	for _, out := range append(nonEmpty(o.OutputName), targetOutputs(o)...) {
+		// Written by --split-debug
+		for _, debug := range []string{out + ".debug", out + ".dSYM"} {
+			if _, e := os.Stat(debug); e == nil {
+				fmt.Printf("Removing %s\n", debug)
+				removeDir(o, debug)
+			}
+		}
+	}
+	if len(o.Targets) > 0 {
+		// Only removed if empty
+		removeFile(o, targetBinDir)
+	}
+	for _, s := range o.TestSources {
+		if exe := testExecutable(o, s); fileExists(exe) {
+			fmt.Printf("Removing %s\n", exe)
+			removeFile(o, exe)
+		}
+	}
+	// Written by earlier versions of cxx2
+	if fileExists(".cxxcache") {
+		fmt.Println("Removing .cxxcache")
+		removeFile(o, ".cxxcache")
+	}
+}
+
+func gatherAllIncludes(o *Options, files []string) []string {
+	s := map[string]bool{}
+	for _, f := range files {
+		for _, inc := range discoverIncludes(o, f) {
+			s[inc] = true
+		}
+	}
+	var out []string
+	for inc := range s {
+		out = append(out, inc)
+	}
+	return out
+}
+
+func discoverIncludes(o *Options, file string) []string {
+	if o.state.memory != nil {
+		return o.state.memory.includes(file)
+	}
+	return scanIncludes(file)
+}
+
+func scanIncludes(file string) []string {
+	b, e := os.ReadFile(file)
+	if e != nil {
+		return nil
+	}
+	var out []string
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	rx := regexp.MustCompile(`^\s*#\s*include\s*["<]([^">]+)[">]`)
+	for sc.Scan() {
+		line := sc.Text()
+		if m := rx.FindStringSubmatch(line); len(m) == 2 {
+			out = append(out, m[1])
+		}
+	}
+	return out
+}
+
+func fileExists(p string) bool {
+	i, e := os.Stat(p)
+	return e == nil && i.Mode().IsRegular()
+}
+
+func dirExists(p string) bool {
+	i, e := os.Stat(p)
+	return e == nil && i.IsDir()
+}
+
+func isStdInclude(header string) bool {
+	h := strings.ToLower(strings.TrimSuffix(header, filepath.Ext(header)))
+	// Both <cstdio> and <stdio.h> are covered by "cstdio" in the skip list
+	for _, s := range stdIncludesSkipList {
+		if h == s || "c"+h == s {
+			return true
+		}
+	}
+	return false
+}
+
+func checkMissingHeaders(includes []string, o *Options) []string {
+	var out []string
+LOOP:
+	for _, inc := range includes {
+		if isStdInclude(inc) {
+			continue
+		}
+		for _, d := range o.IncludeDirs {
+			if fileExists(filepath.Join(d, inc)) {
+				verbosef(o, "Found %s in %s\n", inc, d)
+				continue LOOP
+			}
+		}
+		for _, d := range o.SystemIncludeDirs {
+			if fileExists(filepath.Join(d, inc)) {
+				verbosef(o, "Found %s in %s\n", inc, d)
+				continue LOOP
+			}
+		}
+		verbosef(o, "%s was not found in the include directories\n", inc)
+		out = append(out, inc)
+	}
+	return out
+}
+
+func pkgDiscovery(o *Options, missing []string) error {
+	fmt.Println("Missing headers:")
+	for _, h := range missing {
+		fmt.Println("  ", h)
+		if _, cmd := mapHeaderToPkg(h, o.DetectedDistro); cmd != "" {
+			fmt.Printf("    Possibly install with: %s\n", cmd)
+		}
+		if _, ok := headerOnlyLibraries[h]; ok && !o.Fetch {
+			fmt.Println("    Possibly fetch with: cxx2 --fetch")
+		}
+	}
+	if !o.Sloppy {
+		return fmt.Errorf("cannot proceed unless sloppy mode is used or you fix missing headers")
+	}
+	fmt.Println("Continuing in sloppy mode, ignoring missing headers.")
+	return nil
+}
+
+// missingHeaders adds the flags for the libraries that provide the given includes,
+// and returns the includes that are not found
+func missingHeaders(o *Options, includes []string) []string {
+	provided := pkgConfigDependencies(o, includes)
+	if onMacOS(o) {
+		for h := range macDependencies(o, includes) {
+			provided[h] = true
+		}
+	}
+	var missing []string
+	for _, h := range checkMissingHeaders(includes, o) {
+		if !provided[h] {
+			missing = append(missing, h)
+		}
+	}
+	return missing
+}
+
+// pkgConfigDependencies adds the flags from pkg-config for the libraries that provide the included headers,
+// according to the header database, and returns the headers that are provided by an installed library
+func pkgConfigDependencies(o *Options, includes []string) map[string]bool {
+	provided := map[string]bool{}
+	found := map[string]bool{}
+	for _, h := range includes {
+		pc := pkgConfigName(h)
+		if pc == "" {
+			continue
+		}
+		ok, checked := found[pc]
+		if !checked {
+			flags, err := gatherPkgConfigFlags(pc, o.DetectedDistro)
+			ok = err == nil
+			found[pc] = ok
+			if ok {
+				verbosef(o, "Using pkg-config package %s for %s: %s\n", pc, h, flags)
+			} else {
+				verbosef(o, "The pkg-config package %s for %s was not found\n", pc, h)
+			}
+			if ok {
+				mergePkgConfigFlags(flags, o)
+				if !contains(o.PkgConfigPackages, pc) {
+					o.PkgConfigPackages = append(o.PkgConfigPackages, pc)
+				}
+			}
+		}
+		provided[h] = ok
+	}
+	return provided
+}
+
+func discoverSystemIncludeDirs() []string {
+	d := []string{"/usr/include", "/usr/local/include"}
+	if fileExists("/usr/include/x86_64-linux-gnu") {
+		d = append(d, "/usr/include/x86_64-linux-gnu")
+	}
+	return d
+}
+
+func discoverLocalIncludeDirs() []string {
+	d := []string{"include", ".", "common", thirdPartyDir}
+	if dirExists("../include") {
+		d = append(d, "../include")
+	}
+	if dirExists("../common") {
+		d = append(d, "../common")
+	}
+	return d
+}
+
+func loadCache(o *Options) (*CompileCache, error) {
+	if o.state.memory != nil {
+		return o.state.memory.cache(o), nil
+	}
+	return readCache(o), nil
+}
+
+func readCache(o *Options) *CompileCache {
+	cc := &CompileCache{Timestamps: map[string]int64{}}
+	b, e := os.ReadFile(cachePath(o))
+	// With --force, the cache has been removed, except for dry runs, where it is ignored instead
+	if e == nil && !(o.Force && o.DryRun) {
+		_ = json.Unmarshal(b, cc)
+	}
+	if cc.Commands == nil {
+		cc.Commands = map[string]string{}
+	}
+	return cc
+}
+
+func saveCache(o *Options, cc *CompileCache) {
+	b, _ := json.MarshalIndent(cc, "", "  ")
+	_ = writeFile(o, cachePath(o), b)
+}
+
+// singleStepBuild: just one normal source, no tests -> compile and link in one g++ step
+func singleStepBuild(o *Options, source string) error {
+	on := ensureExeSuffix(o.OutputName, o.Win64Docker)
+	cxx, std, flags, cf := o.CXX, o.Std, compileFlags(o), joinExtraCFlags(o.ExtraCFlags)
+	if isCSource(source) {
+		cxx, std, flags, cf = cCompiler(o), o.CStd, cCompileFlags(o), cExtraFlags(o)
+	}
+	sf := ""
+	if std != "" {
+		sf = "-std=" + std
+	}
+	flags, cf = fileFlags(o, source, flags, cf)
+	inc := includeFlags(o)
+	if usesPCH(o, source) {
+		inc = joinNonEmpty([]string{o.PCHFlags, inc})
+	}
+	linkFlags := joinExtraLDFlags(append(linkerFlags(o), o.ExtraLDFlags...))
+	line := fmt.Sprintf(`%s %s %s %s %s %s -o %s`,
+		cxx, sf, flags, inc, cf, source, on)
+	if linkFlags != "" {
+		line += " " + linkFlags
+	}
+	run := runCommandTo
+	if fancyOutput(o) {
+		status(o, "Building", on)
+		line = joinNonEmpty([]string{line, diagnosticsColorFlag(o, source)})
+		run = execCommandLine
+	}
+	var output bytes.Buffer
+	start := time.Now()
+	if e := run(line, o, io.MultiWriter(os.Stderr, &output)); e != nil {
+		o.state.report.compiled(source, "", "failed", time.Since(start), output.String())
+		recordDiagnostics(o, compilerToolName(o), output.String())
+		return e
+	}
+	recordDiagnostics(o, compilerToolName(o), output.String())
+	if e := checkBaseline(o, output.String()); e != nil {
+		o.state.report.compiled(source, "", "failed", time.Since(start), output.String())
+		removeFile(o, on)
+		return e
+	}
+	o.state.report.compiled(source, "", "compiled", time.Since(start), output.String())
+	o.state.report.linked(on, 0, true)
+	o.OutputName = on
+	return nil
+}
+
+func compileAndLink(o *Options, cc *CompileCache) error {
+	var srcs []string
+	for _, s := range o.Sources {
+		if !isTestSource(s) {
+			srcs = append(srcs, s)
+		}
+	}
+	objs, e := compileAll(o, cc, srcs)
+	if e != nil {
+		return e
+	}
+	if len(objs) == 0 {
+		// Only tests, like for a header-only library
+		return nil
+	}
+	on := ensureExeSuffix(o.OutputName, o.Win64Docker)
+	if e := linkObjects(o, objs, on); e != nil {
+		return e
+	}
+	o.OutputName = on
+	return nil
+}
+
+func ensureExeSuffix(base string, docker bool) string {
+	if docker && !strings.HasSuffix(base, ".exe") {
+		return base + ".exe"
+	}
+	if runtime.GOOS == "windows" && !strings.HasSuffix(base, ".exe") {
+		return base + ".exe"
+	}
+	return base
+}
+
+func compileOne(o *Options, cc *CompileCache, src string, progress *compileProgress) (string, error) {
+	obj := objectPath(o, src)
+	reason := rebuildReason(o, src, obj, cc)
+	if reason == "" && moduleInterfaceChanged(o, src, obj) {
+		reason = "an imported module has changed"
+	}
+	if reason != "" {
+		whyf(o, "Compiling %s, since %s\n", src, reason)
+		if err := makeDir(o, filepath.Dir(obj)); err != nil {
+			return obj, err
+		}
+		line := buildCompileCmd(o, src, obj)
+		command := line
+		run := runCommandTo
+		if fancyOutput(o) {
+			if !o.Quiet {
+				fmt.Println(colorize(o, ansiCyan, progress.next()), "Compiling", src)
+			}
+			line = joinNonEmpty([]string{line, diagnosticsColorFlag(o, src)})
+			run = execCommandLine
+		}
+		var output bytes.Buffer
+		start := time.Now()
+		if err := run(line, o, io.MultiWriter(os.Stderr, &output)); err != nil {
+			o.state.report.compiled(src, obj, "failed", time.Since(start), output.String())
+			recordDiagnostics(o, compilerToolName(o), output.String())
+			return obj, err
+		}
+		recordDiagnostics(o, compilerToolName(o), output.String())
+		if err := checkBaseline(o, output.String()); err != nil {
+			// Removed, so that the source is compiled again and the warnings are shown until they are fixed
+			o.state.report.compiled(src, obj, "failed", time.Since(start), output.String())
+			removeFile(o, obj)
+			return obj, err
+		}
+		o.state.report.compiled(src, obj, "compiled", time.Since(start), output.String())
+		updateTimestamp(src, cc)
+		cc.mu.Lock()
+		cc.Commands[src] = command
+		cc.mu.Unlock()
+	} else {
+		whyf(o, "%s is up to date\n", src)
+		o.state.report.compiled(src, obj, "cached", 0, "")
+	}
+	return obj, nil
+}
+
+// compileAll compiles the given sources, with up to o.Jobs compilations running in parallel,
+// and returns the object files in the same order as the sources. With modules, the sources
+// that provide modules are compiled before the sources that import them.
+func compileAll(o *Options, cc *CompileCache, srcs []string) ([]string, error) {
+	levels, err := moduleLevels(o, srcs)
+	if err != nil {
+		return nil, err
+	}
+	progress := &compileProgress{}
+	for _, s := range srcs {
+		if needsRebuild(o, s, objectPath(o, s), cc) {
+			progress.total++
+		}
+	}
+	objOf := map[string]string{}
+	for _, level := range levels {
+		objs, err := compileParallel(o, cc, level, progress)
+		if err != nil {
+			return nil, err
+		}
+		for i, s := range level {
+			objOf[s] = objs[i]
+		}
+	}
+	objs := make([]string, len(srcs))
+	for i, s := range srcs {
+		objs[i] = objOf[s]
+	}
+	return objs, nil
+}
+
+// compileParallel compiles the given sources, with up to o.Jobs compilations running in parallel,
+// and returns the object files in the same order as the sources
+func compileParallel(o *Options, cc *CompileCache, srcs []string, progress *compileProgress) ([]string, error) {
+	objs := make([]string, len(srcs))
+	errs := make([]error, len(srcs))
+	sem := make(chan struct{}, max(o.Jobs, 1))
+	var wg sync.WaitGroup
+	var failed atomic.Bool
+	for i, s := range srcs {
+		sem <- struct{}{}
+		if failed.Load() {
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			objs[i], errs[i] = compileOne(o, cc, s, progress)
+			if errs[i] != nil {
+				failed.Store(true)
+			}
+		}()
+	}
+	wg.Wait()
+	for _, e := range errs {
+		if e != nil {
+			return nil, e
+		}
+	}
+	return objs, nil
+}
+
+func buildCompileCmd(o *Options, src, obj string) string {
+	if isCUDASource(src) {
+		return cudaCompileCmd(o, src, obj)
+	}
+	if isNASMSource(src) {
+		return nasmCompileCmd(o, src, obj)
+	}
+	if isResourceSource(src) {
+		return resourceCompileCmd(o, src, obj)
+	}
+	if isMSVC(o) {
+		return msvcCompileCmd(o, src, obj)
+	}
+	if isCSource(src) {
+		return cCompileCmd(o, src, obj)
+	}
+	sf := ""
+	if o.Std != "" {
+		sf = "-std=" + o.Std
+	}
+	flags, cf := fileFlags(o, src, compileFlags(o), joinExtraCFlags(o.ExtraCFlags))
+	inc := includeFlags(o)
+	if usesPCH(o, src) {
+		inc = joinNonEmpty([]string{o.PCHFlags, inc})
+	}
+	cxx := o.CXX
+	if isHIPSource(o, src) {
+		// hipcc is clang, so it takes the same flags
+		cxx = hipccCompiler()
+	}
+	if o.Launcher != "" {
+		cxx = o.Launcher + " " + cxx
+	}
+	return joinNonEmpty([]string{cxx, sf, flags, inc, cf, moduleFlags(o, src), "-c", src, "-o", obj})
+}
+
+// includeFlags returns -I flags for the local include directories that exist
+func includeFlags(o *Options) string {
+	var out []string
+	for _, d := range o.IncludeDirs {
+		if dirExists(d) {
+			out = append(out, "-I"+d)
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+// defineFlags returns the -D flags from the extra compile flags, for tools that only take those
+func defineFlags(o *Options) string {
+	var defines []string
+	for _, f := range o.ExtraCFlags {
+		if strings.HasPrefix(f, "-D") {
+			defines = append(defines, f)
+		}
+	}
+	return joinExtraCFlags(defines)
+}
+
+func compileFlags(o *Options) string {
+	if isMSVC(o) {
+		return msvcCompileFlags(o)
+	}
+	baseFlags := []string{
+		"-pipe",
+		"-fPIC",
+		"-fno-plt",
+		"-fstack-protector-strong",
+		"-Wall",
+		"-Wshadow",
+		"-Wpedantic",
+		"-Wno-parentheses",
+		"-Wfatal-errors",
+		"-Wvla",
+		"-Wignored-qualifiers",
+	}
+	if o.Debug {
+		baseFlags = removeFromSlice(baseFlags, "-O2")
+		baseFlags = append(baseFlags, "-O0", "-g")
+	} else if o.Small {
+		baseFlags = append(baseFlags, smallFlags(o)...)
+	} else if o.Opt {
+		baseFlags = append(baseFlags, "-O2")
+	}
+	if o.Profile {
+		// For call graphs, and for the function names and lines in the report
+		baseFlags = append(baseFlags, "-fno-omit-frame-pointer")
+		if !o.Debug {
+			baseFlags = append(baseFlags, "-g")
+		}
+	}
+	if o.SplitDebug && !contains(baseFlags, "-g") {
+		// Split out into separate files after linking
+		baseFlags = append(baseFlags, "-g")
+	}
+	if o.Reproducible {
+		baseFlags = append(baseFlags, reproducibleFlags(o)...)
+	}
+	baseFlags = append(baseFlags, marchFlags(o)...)
+	if o.Strict {
+		baseFlags = append(baseFlags, "-Wextra", "-Wconversion")
+	}
+	if o.Sloppy {
+		baseFlags = append(baseFlags, "-w", "-fpermissive")
+	}
+	baseFlags = applyWarningProfile(o, baseFlags)
+	if o.Coverage {
+		baseFlags = append(baseFlags, coverageFlags(o)...)
+	}
+	if o.PGO != "" {
+		baseFlags = append(baseFlags, pgoFlags(o)...)
+	}
+	if o.LTO {
+		baseFlags = append(baseFlags, ltoFlags(o)...)
+	}
+	if o.Wasm || o.Wasi {
+		// There is no PLT in WebAssembly
+		baseFlags = removeFromSlice(baseFlags, "-fno-plt")
+	}
+	if o.Wasi {
+		// wasi-libc has no stack protector support
+		baseFlags = removeFromSlice(baseFlags, "-fstack-protector-strong")
+	}
+	if isZig(o) {
+		// The libcs that zig comes with are built without the PLT and stack protector support that these need
+		baseFlags = removeFromSlice(baseFlags, "-fno-plt")
+		baseFlags = removeFromSlice(baseFlags, "-fstack-protector-strong")
+	}
+	baseFlags = append(baseFlags, crossFlags(o)...)
+	return strings.Join(baseFlags, " ")
+}
+
+func removeFromSlice(sl []string, val string) []string {
+	var out []string
+	for _, s := range sl {
+		if s != val {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func joinExtraCFlags(flags []string) string {
+	if len(flags) == 0 {
+		return ""
+	}
+	return strings.Join(flags, " ")
+}
+
+func linkObjects(o *Options, objs []string, out string) error {
+	start := time.Now()
+	line := buildLinkCmd(o, objs, out)
+	if fancyOutput(o) {
+		status(o, "Linking", out)
+		if err := execCommandLine(line, o, os.Stderr); err != nil {
+			return err
+		}
+	} else if err := runCommand(line, o); err != nil {
+		return err
+	}
+	o.state.report.linked(out, time.Since(start), false)
+	return nil
+}
+
+func buildLinkCmd(o *Options, objs []string, out string) string {
+	objs = linkOrder(o, objs)
+	if isMSVC(o) {
+		return msvcLinkCmd(o, objs, out, false)
+	}
+	flags := driverFlags(o)
+	linkFlags := joinExtraLDFlags(append(linkerFlags(o), o.ExtraLDFlags...))
+	line := fmt.Sprintf(`%s %s %s -o %s`,
+		linkDriver(o), flags, strings.Join(objs, " "), out)
+	if linkFlags != "" {
+		line += " " + linkFlags
+	}
+	return line
+}
+
+func joinExtraLDFlags(ldflags []string) string {
+	if len(ldflags) == 0 {
+		return ""
+	}
+	return strings.Join(ldflags, " ")
+}
+
+func needsRebuild(o *Options, src, obj string, cc *CompileCache) bool {
+	return rebuildReason(o, src, obj, cc) != ""
+}
+
+// rebuildReason returns why the given source needs to be compiled, or "" if the object file is up to date.
+// Besides the source, the compile command and the headers in the project that it includes are checked.
+func rebuildReason(o *Options, src, obj string, cc *CompileCache) string {
+	if !fileExists(obj) {
+		return "there is no object file"
+	}
+	si, e := os.Stat(src)
+	if e != nil {
+		return "the source can not be read"
+	}
+	oi, e := os.Stat(obj)
+	if e != nil || oi.ModTime().Before(si.ModTime()) {
+		return "the source is newer than the object file"
+	}
+	cc.mu.Lock()
+	old, known := cc.Timestamps[src]
+	command, recorded := cc.Commands[src]
+	cc.mu.Unlock()
+	if !known {
+		return "the source is not in the compile cache"
+	}
+	if old != si.ModTime().Unix() {
+		return "the source has changed since it was compiled"
+	}
+	if !recorded {
+		return "the compile command was not recorded"
+	}
+	if command != buildCompileCmd(o, src, obj) {
+		return "the compile flags have changed"
+	}
+	for _, h := range projectHeaders(o, src) {
+		if hi, e := os.Stat(h); e == nil && hi.ModTime().After(oi.ModTime()) {
+			return "the header " + h + " has changed"
+		}
+	}
+	return ""
+}
+
+func updateTimestamp(src string, cc *CompileCache) {
+	if i, e := os.Stat(src); e == nil {
+		cc.mu.Lock()
+		cc.Timestamps[src] = i.ModTime().Unix()
+		cc.mu.Unlock()
+	}
+}
+
+func buildAndRunTests(o *Options, cc *CompileCache) error {
+	tests, err := selectTests(o)
+	if err != nil {
+		return err
+	}
+	if len(tests) == 0 {
+		fmt.Println("No tests match", o.TestFilter)
+		return nil
+	}
+	var normalSrcs []string
+	for _, s := range o.Sources {
+		// Each test has its own main function, so leave out the sources that define the program entry points
+		if !isTestSource(s) && s != o.MainSource && targetOf(o, s) == nil {
+			normalSrcs = append(normalSrcs, s)
+		}
+	}
+	// Tests that use a single header test framework and have no main function get a generated one
+	var mainSrcs []string
+	testMains := map[string]string{}
+	for _, s := range tests {
+		m, contents := testMain(o, s)
+		if m == "" {
+			continue
+		}
+		if err := writeTestMain(o, m, contents); err != nil {
+			return err
+		}
+		if !contains(mainSrcs, m) {
+			mainSrcs = append(mainSrcs, m)
+		}
+		testMains[s] = m
+	}
+	n, t := len(normalSrcs), len(tests)
+	objs, e := compileAll(o, cc, append(append(normalSrcs, tests...), mainSrcs...))
+	if e != nil {
+		return e
+	}
+	normalObjs, testObjs := objs[:n], objs[n:n+t]
+	mainObjs := map[string]string{}
+	for i, m := range mainSrcs {
+		mainObjs[m] = objs[n+t+i]
+	}
+	var runs []testRun
+	for i, s := range tests {
+		obj := testObjs[i]
+		exe := testExecutable(o, s)
+		if err := makeDir(o, filepath.Dir(exe)); err != nil {
+			return err
+		}
+		in := append([]string{obj}, normalObjs...)
+		framework, _ := testFramework(o, s)
+		if m, ok := testMains[s]; ok {
+			in = append(in, mainObjs[m])
+		} else if framework == frameworkGoogleTest && !hasMainFunction(s) {
+			in = append(in, gtestMainLibraries(o)...)
+		}
+		if err := linkObjects(o, in, exe); err != nil {
+			return err
+		}
+		if o.Win64Docker && wineCommand() == "" {
+			fmt.Println("Cannot run Windows .exe test without wine:", exe)
+			continue
+		}
+		if o.Target != "" && !o.Wasi {
+			fmt.Printf("Cannot run a test that is cross-compiled for %s: %s\n", o.Target, exe)
+			continue
+		}
+		args := o.ProgramArgs
+		if framework != "" && o.TestFilter != "" && !testNameMatches(s, o.TestFilter) {
+			// Only the matching cases are run
+			args = append(testFilterArgs(framework, o.TestFilter), args...)
+		}
+		runs = append(runs, testRun{exe: exe, framework: framework, args: args})
+	}
+	return runTests(o, runs)
+}
+
+func generateProFile(o *Options, normalSrc []string) error {
+	n := strings.TrimSuffix(o.OutputName, ".exe")
+	pf := n + ".pro"
+	f, e := os.Create(pf)
+	if e != nil {
+		return e
+	}
+	defer f.Close()
+
+	var all []string
+	all = append(all, normalSrc...)
+	if o.MainSource != "" && !contains(all, o.MainSource) {
+		all = append(all, o.MainSource)
+	}
+	fmt.Fprintf(f, "TEMPLATE = app\nCONFIG += c++20\nCONFIG -= console\nCONFIG -= app_bundle\nCONFIG -= qt\n\n")
+	fmt.Fprintf(f, "SOURCES += \\\n")
+	for i, s := range all {
+		if i < len(all)-1 {
+			fmt.Fprintf(f, "  %s \\\n", s)
+		} else {
+			fmt.Fprintf(f, "  %s\n\n", s)
+		}
+	}
+	fmt.Fprintf(f, "INCLUDEPATH += . include ../include ../common\n\n")
+	if o.CXX != "" {
+		fmt.Fprintf(f, "QMAKE_CXX = %s\n", o.CXX)
+	}
+	cf := strings.Fields(compileFlags(o))
+	extraC := joinExtraCFlags(o.ExtraCFlags)
+	if extraC != "" {
+		cf = append(cf, extraC)
+	}
+	if len(cf) > 0 {
+		fmt.Fprintf(f, "QMAKE_CXXFLAGS += %s\n", strings.Join(cf, " "))
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func runCommand(line string, o *Options) error {
+	return runCommandTo(line, o, os.Stderr)
+}
+
+// runCommandTo runs the given command line, like runCommand, but writes its standard error to the given writer
+func runCommandTo(line string, o *Options, stderr io.Writer) error {
+	echoCommand(o, line)
+	return execCommandLine(line, o, stderr)
+}
+
+// execCommandLine runs the given command line without printing it, in a container if needed
+func execCommandLine(line string, o *Options, stderr io.Writer) error {
+	if o.Win64Docker || o.StaticDocker {
+		p := strings.Fields(line)
+		if len(p) == 0 {
+			return nil
+		}
+		engine, a := containerEngine(), dockerArgs(o, p)
+		if o.DryRun {
+			fmt.Println(engine + " " + strings.Join(a, " "))
+			return nil
+		}
+		if !haveContainerEngine() {
+			return fmt.Errorf("docker or podman is needed for building in a container")
+		}
+		echoCommand(o, engine+" "+strings.Join(a, " "))
+		c := exec.Command(engine, a...)
+		c.Stdout = os.Stdout
+		c.Stderr = stderr
+		err := c.Run()
+		o.state.report.ran(c.ProcessState)
+		return err
+	}
+	p := strings.Fields(line)
+	if len(p) == 0 {
+		return nil
+	}
+	if o.DryRun {
+		fmt.Println(line)
+		return nil
+	}
+	c := exec.Command(p[0], p[1:]...)
+	c.Stdout = os.Stdout
+	c.Stderr = stderr
+	err := c.Run()
+	o.state.report.ran(c.ProcessState)
+	return err
+}
+
+// runProgram runs the given executable, with the output going to stdout and stderr.
+// With --np=, it is run as that many MPI processes. With --valgrind, it is run under
+// valgrind memcheck, and memory errors and leaks make it fail.
+func runProgram(o *Options, exe string) error {
+	return runProgramTo(o, exe, o.ProgramArgs, os.Stdout, os.Stderr, 0)
+}
+
+// runProgramTo runs the given executable with the given arguments, like runProgram, but writes its output
+// to the given writers and kills it if it runs for longer than the given timeout, if it is not 0
+func runProgramTo(o *Options, exe string, args []string, stdout, stderr io.Writer, timeout time.Duration) error {
+	cmd, err := programCommand(exe)
+	if err != nil {
+		return err
+	}
+	if o.Valgrind {
+		if cmd, err = valgrindCommand(o, cmd); err != nil {
+			return err
+		}
+	}
+	if o.Profile {
+		if cmd, err = profileCommand(o, cmd, exe); err != nil {
+			return err
+		}
+	}
+	if o.MPIProcs > 0 {
+		if cmd, err = mpiCommand(cmd, o.MPIProcs); err != nil {
+			return err
+		}
+	}
+	cmd.Args = append(cmd.Args, args...)
+	if o.DryRun {
+		fmt.Fprintln(stdout, strings.Join(cmd.Args, " "))
+		return nil
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	runErr := runWithTimeout(cmd, timeout)
+	if o.Valgrind {
+		if err := checkValgrindLogs(o, exe); err != nil {
+			return err
+		}
+	}
+	if o.Profile && runErr == nil {
+		return reportProfile(o, exe)
+	}
+	return runErr
+}
+
+// dockerArgs returns the container arguments for running the given command in the MinGW container,
+// or in the Alpine container for static executables, with the current directory mounted as the working directory
+func dockerArgs(o *Options, command []string) []string {
+	if o.StaticDocker {
+		return containerArgs(staticImage, command)
+	}
+	return containerArgs(mingwImage(o), command)
+}
+
+func mustPwd() string {
+	w, e := os.Getwd()
+	if e != nil {
+		panic(e)
+	}
+	return w
+}
+
+func gatherPkgConfigFlags(pkg, distro string) (string, error) {
+	if !haveCmd("pkg-config") {
+		return "", fmt.Errorf("pkg-config not found")
+	}
+	cmdStr := "pkg-config --cflags --libs " + pkg
+	out, err := runShellCommand(cmdStr)
+	if err != nil || out == "" {
+		return "", fmt.Errorf("no pkg-config info for %s", pkg)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func runShellCommand(cmd string) (string, error) {
+	parts := strings.Fields(cmd)
+	if len(parts) == 0 {
+		return "", fmt.Errorf("empty command")
+	}
+	c := exec.Command(parts[0], parts[1:]...)
+	b, err := c.CombinedOutput()
+	return string(b), err
+}
+
+func haveCmd(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+func mergePkgConfigFlags(flags string, o *Options) {
+	fs := strings.Fields(flags)
+	for _, f := range fs {
+		if strings.HasPrefix(f, "-I") || strings.HasPrefix(f, "-D") || strings.HasPrefix(f, "-F") ||
+			strings.HasPrefix(f, "-framework") || (strings.HasPrefix(f, "-W") && !strings.HasPrefix(f, "-Wl,")) {
+			o.ExtraCFlags = append(o.ExtraCFlags, f)
+		} else if strings.HasPrefix(f, "-l") || strings.HasPrefix(f, "-L") ||
+			strings.HasPrefix(f, "-Wl,") || strings.HasPrefix(f, "-framework") {
+			o.ExtraLDFlags = append(o.ExtraLDFlags, f)
+		} else {
+			continue
+		}
+		o.PkgConfigFlags = append(o.PkgConfigFlags, f)
+	}
+}
+
+// pkgConfigName returns the pkg-config module name for the library that provides the given header, if known
+func pkgConfigName(h string) string {
+	return configString(lookupHeader(h), "pkgconfig")
+}
+
+// headerPackage returns the package manager of the distro, and the package that provides the given header, if known
+func headerPackage(h, distro string) (packageManager, string) {
+	pm, _ := distroPackageManager(distro)
+	pm = macPortsInstead(pm)
+	entry := lookupHeader(h)
+	pkg := configString(entry, pm.Command)
+	if pm.Command == "msys2" && pkg != "" {
+		pkg = msys2Package(pkg)
+	}
+	if format, ok := pkgConfigProvides[pm.Command]; ok && pkg == "" {
+		if pc := configString(entry, "pkgconfig"); pc != "" {
+			pkg = fmt.Sprintf(format, pc)
+		}
+	}
+	return pm, pkg
+}
+
+// mapHeaderToPkg returns the distro package that provides the given header, and the command that installs it
+func mapHeaderToPkg(h, distro string) (string, string) {
+	pm, pkg := headerPackage(h, distro)
+	if pkg == "" {
+		return "", ""
+	}
+	if strings.ContainsAny(pkg, "()") {
+		// Quote pkgconfig(...) for the shell
+		return pkg, pm.Install + " '" + pkg + "'"
+	}
+	return pkg, pm.Install + " " + pkg
+}
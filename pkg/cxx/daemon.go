@@ -0,0 +1,306 @@
+package cxx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/xyproto/distrodetector"
+)
+
+// daemonCommands are the arguments that may be passed on to a running daemon.
+// Anything else is handled by a regular build in the client process.
+var daemonCommands = []string{"build", "run", "test", "debug", "opt", "strict", "sloppy", "clang"}
+
+func daemonSocket(o *Options) string {
+	return filepath.Join(o.BuildDir, "daemon.sock")
+}
+
+// daemonListening checks if a daemon is serving builds from the build directory
+func daemonListening(o *Options) bool {
+	conn, err := net.Dial("unix", daemonSocket(o))
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// daemonRequest is sent by the client, with the command line arguments to build with
+type daemonRequest struct {
+	Args []string `json:"args"`
+}
+
+// daemonReply is streamed back to the client. Output is sent while building,
+// and the last reply has Done set, together with the exit code and the program to run, if any.
+type daemonReply struct {
+	Output string `json:"output,omitempty"`
+	Done   bool   `json:"done,omitempty"`
+	Exit   int    `json:"exit,omitempty"`
+	Run    string `json:"run,omitempty"`
+}
+
+// buildMemory holds the source list, the includes of every source and the compile caches in memory,
+// so that incremental builds only need to look at what has changed on disk
+type buildMemory struct {
+	mu         sync.Mutex
+	distroName string
+	srcs       []string
+	dirs       map[string]time.Time
+	srcsDir    string
+	incls      map[string]memoIncludes
+	caches     map[string]memoCache
+}
+
+type memoIncludes struct {
+	modTime  time.Time
+	includes []string
+}
+
+// memoCache is a compile cache, together with the modification time of the cache.json it was read from
+type memoCache struct {
+	modTime time.Time
+	cache   *CompileCache
+}
+
+func newBuildMemory() *buildMemory {
+	return &buildMemory{incls: map[string]memoIncludes{}, caches: map[string]memoCache{}}
+}
+
+func (m *buildMemory) distro() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.distroName == "" {
+		m.distroName = distrodetector.New().String()
+	}
+	return m.distroName
+}
+
+// sources returns the remembered source list, and only walks the project again
+// if a directory was added, removed or had files added or removed since the last time
+func (m *buildMemory) sources(buildDir string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.dirs != nil && m.srcsDir == buildDir && !m.dirsChanged() {
+		return append([]string(nil), m.srcs...), nil
+	}
+	srcs, dirs, err := scanSources(buildDir)
+	if err != nil {
+		return nil, err
+	}
+	m.srcs, m.srcsDir = srcs, buildDir
+	m.dirs = map[string]time.Time{}
+	for _, d := range dirs {
+		if fi, err := os.Stat(d); err == nil {
+			m.dirs[d] = fi.ModTime()
+		}
+	}
+	return append([]string(nil), srcs...), nil
+}
+
+func (m *buildMemory) dirsChanged() bool {
+	for d, t := range m.dirs {
+		fi, err := os.Stat(d)
+		if err != nil || !fi.ModTime().Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// includes returns the headers included by the given file, which is only read again if it was modified
+func (m *buildMemory) includes(file string) []string {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if mi, ok := m.incls[file]; ok && mi.modTime.Equal(fi.ModTime()) {
+		return mi.includes
+	}
+	incls := scanIncludes(file)
+	m.incls[file] = memoIncludes{modTime: fi.ModTime(), includes: incls}
+	return incls
+}
+
+// cache returns the compile cache for the build directory of the given options, which is read from disk
+// the first time, and again whenever cache.json has changed or is gone, like after a build that was not
+// done by the daemon, or after the build directory was cleaned
+func (m *buildMemory) cache(o *Options) *CompileCache {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p := cachePath(o)
+	var modTime time.Time
+	if fi, err := os.Stat(p); err == nil {
+		modTime = fi.ModTime()
+	}
+	if mc, ok := m.caches[p]; ok && mc.modTime.Equal(modTime) {
+		return mc.cache
+	}
+	cc := readCache(o)
+	m.caches[p] = memoCache{modTime: modTime, cache: cc}
+	return cc
+}
+
+// RunDaemon serves builds for the current directory over a unix socket in the build directory,
+// one at a time, until it is interrupted
+func RunDaemon(o *Options) error {
+	if err := prepareBuildDir(o); err != nil {
+		return err
+	}
+	sock := daemonSocket(o)
+	if daemonListening(o) {
+		return fmt.Errorf("a daemon is already listening on %s", sock)
+	}
+	// Left behind by a daemon that did not exit cleanly
+	os.Remove(sock)
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		return err
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		l.Close()
+	}()
+	memory := newBuildMemory()
+	fmt.Printf("Serving builds for %s on %s, stop with ctrl-c\n", mustPwd(), sock)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				fmt.Println("Daemon stopped")
+				return nil
+			}
+			return err
+		}
+		serveBuild(conn, memory)
+	}
+}
+
+// serveBuild builds with the arguments from the client, using what the daemon keeps in memory,
+// and streams the output back to it
+func serveBuild(conn net.Conn, memory *buildMemory) {
+	defer conn.Close()
+	var req daemonRequest
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+	fmt.Printf("Building: %v\n", req.Args)
+	// Builds set variables like PKG_CONFIG_LIBDIR when cross compiling, which must not be used by the next build
+	defer restoreEnvironment(os.Environ())
+	enc := json.NewEncoder(conn)
+	reply := daemonReply{Done: true}
+	captureOutput(enc, func() {
+		opts, err := ParseArgs(req.Args)
+		if err == nil {
+			opts.state.memory = memory
+			// The client runs the program itself, so that it gets the terminal
+			run := opts.Run
+			opts.Run = false
+			if err = buildWithReport(opts); err == nil && run {
+				reply.Run = programToRun(opts)
+			}
+		}
+		if err != nil {
+			fmt.Println(err)
+			reply.Exit = ExitCode(err)
+		}
+	})
+	enc.Encode(reply)
+}
+
+// restoreEnvironment sets the environment back to the given variables, from os.Environ
+func restoreEnvironment(env []string) {
+	os.Clearenv()
+	for _, kv := range env {
+		if k, v, ok := strings.Cut(kv, "="); ok && k != "" {
+			os.Setenv(k, v)
+		}
+	}
+}
+
+// captureOutput calls f with stdout, stderr and the log output, also of the commands it runs,
+// sent to the client
+func captureOutput(enc *json.Encoder, f func()) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		f()
+		return
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = w, w
+	log.SetOutput(w)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 4096)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				enc.Encode(daemonReply{Output: string(buf[:n])})
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	f()
+	os.Stdout, os.Stderr = stdout, stderr
+	log.SetOutput(stderr)
+	w.Close()
+	<-done
+	r.Close()
+}
+
+// BuildWithDaemon lets a running daemon do the build, if there is one and the arguments allow it.
+// It returns the exit code, and false if the build should be done by this process instead.
+func BuildWithDaemon(o *Options, args []string) (int, bool) {
+	for _, arg := range args {
+		if !contains(daemonCommands, arg) {
+			return 0, false
+		}
+	}
+	conn, err := net.Dial("unix", daemonSocket(o))
+	if err != nil {
+		return 0, false
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(daemonRequest{Args: args}); err != nil {
+		return 0, false
+	}
+	dec := json.NewDecoder(conn)
+	for {
+		var reply daemonReply
+		if err := dec.Decode(&reply); err != nil {
+			if err == io.EOF {
+				err = fmt.Errorf("the daemon closed the connection")
+			}
+			fmt.Fprintln(os.Stderr, "Daemon error:", err)
+			return 1, true
+		}
+		if !reply.Done {
+			os.Stdout.WriteString(reply.Output)
+			continue
+		}
+		if reply.Exit == 0 && reply.Run != "" {
+			if err := runProgram(o, reply.Run); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return ExitCode(err), true
+			}
+		}
+		return reply.Exit, true
+	}
+}
@@ -0,0 +1,51 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// findDebugger returns the debugger to use, which is the configured one, or gdb, or lldb on macOS
+// or when gdb is not installed
+func findDebugger(o *Options) (string, error) {
+	if o.Debugger != "" && o.Debugger != "auto" {
+		if !haveCmd(o.Debugger) {
+			return "", fmt.Errorf("the debugger %s was not found", o.Debugger)
+		}
+		return o.Debugger, nil
+	}
+	candidates := []string{"gdb", "lldb"}
+	if runtime.GOOS == "darwin" || isClang(o) {
+		candidates = []string{"lldb", "gdb"}
+	}
+	for _, d := range candidates {
+		if haveCmd(d) {
+			return d, nil
+		}
+	}
+	return "", fmt.Errorf("gdb or lldb is needed for debugging, install it with: %s", installSuggestion(o.DetectedDistro, "gdb"))
+}
+
+// debuggerCommand returns the command for starting the given debugger on the executable, with the program arguments
+func debuggerCommand(debugger, exe string, args []string) *exec.Cmd {
+	if debugger == "lldb" || debugger == "lldb.exe" {
+		return exec.Command(debugger, append([]string{"--", runnable(exe)}, args...)...)
+	}
+	return exec.Command(debugger, append([]string{"--args", runnable(exe)}, args...)...)
+}
+
+// runDebugger starts the executable in the debugger, which gets the terminal
+func runDebugger(o *Options, exe string) error {
+	debugger, err := findDebugger(o)
+	if err != nil {
+		return err
+	}
+	cmd := debuggerCommand(debugger, exe, o.ProgramArgs)
+	fmt.Println(joinNonEmpty(cmd.Args))
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,86 @@
+package cxx
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// assumeYesFlags are the flags that make a package manager install without asking, for the ones that would ask
+var assumeYesFlags = map[string]string{
+	"pacman":       "--noconfirm",
+	"msys2":        "--noconfirm",
+	"apt":          "-y",
+	"dnf":          "-y",
+	"zypper":       "-y",
+	"xbps-install": "-y",
+	"pkg":          "-y",
+	"pkgin":        "-y",
+}
+
+// installCommand returns the command that installs the given packages, with sudo if root is needed
+func installCommand(pm packageManager, pkgs []string, assumeYes bool) []string {
+	args := strings.Fields(pm.Install)
+	if flag, ok := assumeYesFlags[pm.Command]; ok && assumeYes {
+		args = append(args, flag)
+	}
+	args = append(args, pkgs...)
+	// Homebrew refuses to run as root, and MSYS2 has no sudo
+	if pm.Command != "brew" && pm.Command != "msys2" && args[0] != "sudo" && os.Geteuid() != 0 {
+		args = append([]string{"sudo"}, args...)
+	}
+	return args
+}
+
+// confirm asks a yes or no question on the terminal, where no is the default
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		fmt.Println()
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// installDependencies installs the packages that provide the missing headers with the package manager
+// of the distro, after asking unless --yes is given, and returns true if the packages were installed
+func installDependencies(o *Options, missing []string) (bool, error) {
+	var pm packageManager
+	var pkgs []string
+	for _, h := range missing {
+		p, pkg := headerPackage(h, o.DetectedDistro)
+		if pkg != "" && !contains(pkgs, pkg) {
+			pm = p
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	if len(pkgs) == 0 {
+		fmt.Println("No packages are known to provide the missing headers.")
+		return false, nil
+	}
+	if !haveCmd(pm.program()) {
+		return false, fmt.Errorf("package manager %s was not found", pm.program())
+	}
+	args := installCommand(pm, pkgs, o.AssumeYes)
+	line := strings.Join(args, " ")
+	if o.DryRun {
+		fmt.Println(line)
+		return false, nil
+	}
+	if !o.AssumeYes && !confirm("Install the missing dependencies with: "+line+"?") {
+		return false, nil
+	}
+	fmt.Println(line)
+	c := exec.Command(args[0], args[1:]...)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return false, fmt.Errorf("%s: %w", line, err)
+	}
+	return true, nil
+}
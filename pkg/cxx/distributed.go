@@ -0,0 +1,67 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// distributedCompilers are the distributed compilation tools that --distributed looks for, in order of preference
+var distributedCompilers = []string{"distcc", "icecc"}
+
+// setupDistributed wraps the compiler invocations with distcc or icecc. When ccache is used,
+// it hands over to the distributed compiler through CCACHE_PREFIX, so that caching still works.
+// If no job count was given, it is raised to the capacity that the cluster reports.
+func setupDistributed(o *Options) {
+	if o.Win64Docker {
+		fmt.Println("Distributed compilation is not available when cross compiling with Docker.")
+		return
+	}
+	tool := ""
+	for _, t := range distributedCompilers {
+		if haveCmd(t) {
+			tool = t
+			break
+		}
+	}
+	if tool == "" {
+		fmt.Println("Neither distcc nor icecc was found, compiling locally.")
+		return
+	}
+	if filepath.Base(o.Launcher) == "ccache" {
+		os.Setenv("CCACHE_PREFIX", tool)
+	} else {
+		o.Launcher = tool
+	}
+	if o.Jobs <= 0 {
+		o.Jobs = clusterJobs(tool)
+	}
+}
+
+// clusterJobs returns the number of parallel jobs that the compile cluster can take,
+// as reported by "distcc -j" or "icecc --jobs", or 0 if unknown
+func clusterJobs(tool string) int {
+	var flag string
+	switch tool {
+	case "distcc":
+		flag = "-j"
+	case "icecc":
+		flag = "--jobs"
+	}
+	out, err := exec.Command(tool, flag).Output()
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return 0
+	}
+	n, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,331 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// toolPackages maps from a tool to the package that provides it, for the package managers
+// where it is not just the name of the tool
+var toolPackages = map[string]map[string]string{
+	"g++": {
+		"pacman":       "gcc",
+		"dnf":          "gcc-c++",
+		"zypper":       "gcc-c++",
+		"xbps-install": "gcc",
+		"emerge":       "sys-devel/gcc",
+		"brew":         "gcc",
+		"pkg":          "gcc",
+		"pkg_add":      "g++",
+		"pkgin":        "gcc13",
+		"msys2":        "gcc",
+	},
+	"clang++": {
+		"emerge": "llvm-core/clang",
+		"brew":   "llvm",
+		"pkg":    "llvm",
+		"pkgin":  "clang",
+	},
+	"aarch64-linux-gnu-g++": {
+		"apt":          "g++-aarch64-linux-gnu",
+		"pacman":       "aarch64-linux-gnu-gcc",
+		"dnf":          "gcc-c++-aarch64-linux-gnu",
+		"xbps-install": "cross-aarch64-linux-gnu",
+	},
+	"arm-linux-gnueabihf-g++": {
+		"apt":          "g++-arm-linux-gnueabihf",
+		"xbps-install": "cross-armv7l-linux-gnueabihf",
+	},
+	"pkg-config": {
+		"pacman":  "pkgconf",
+		"dnf":     "pkgconf-pkg-config",
+		"apk":     "pkgconf",
+		"emerge":  "dev-util/pkgconf",
+		"pkg":     "pkgconf",
+		"pkg_add": "pkgconf",
+		"pkgin":   "pkgconf",
+		"msys2":   "pkgconf",
+	},
+	"docker": {
+		"apt":    "docker.io",
+		"emerge": "app-containers/docker",
+	},
+	"nvcc": {
+		"apt":    "nvidia-cuda-toolkit",
+		"pacman": "cuda",
+		"dnf":    "cuda-toolkit",
+		"zypper": "cuda-toolkit",
+		"emerge": "dev-util/nvidia-cuda-toolkit",
+	},
+	"hipcc": {
+		"pacman": "hip-runtime-amd",
+		"emerge": "dev-util/hipcc",
+	},
+	"mpicxx": {
+		"apt":    "libopenmpi-dev",
+		"pacman": "openmpi",
+		"dnf":    "openmpi-devel",
+		"emerge": "sys-cluster/openmpi",
+		"brew":   "open-mpi",
+	},
+	"qt": {
+		"apt":    "qt6-base-dev qt6-base-dev-tools",
+		"pacman": "qt6-base",
+		"dnf":    "qt6-qtbase-devel",
+		"zypper": "qt6-base-devel",
+		"apk":    "qt6-qtbase-dev",
+		"emerge": "dev-qt/qtbase",
+		"brew":   "qt",
+		"pkg":    "qt6-base",
+		"msys2":  "qt6-base",
+	},
+	"bison": {
+		"emerge": "sys-devel/bison",
+	},
+	"flex": {
+		"emerge": "sys-devel/flex",
+	},
+	"nasm": {
+		"emerge": "dev-lang/nasm",
+	},
+	"glslc": {
+		"pacman": "shaderc",
+		"zypper": "shaderc",
+		"apk":    "shaderc",
+		"emerge": "media-libs/shaderc",
+		"brew":   "shaderc",
+		"pkg":    "shaderc",
+		"msys2":  "shaderc",
+	},
+	"clang-tidy": {
+		"pacman": "clang",
+		"dnf":    "clang-tools-extra",
+		"zypper": "clang-tools",
+		"apk":    "clang-extra-tools",
+		"emerge": "llvm-core/clang",
+		"brew":   "llvm",
+		"pkg":    "llvm",
+		"pkgin":  "clang-tools-extra",
+		"msys2":  "clang-tools-extra",
+	},
+	"clang-format": {
+		"pacman": "clang",
+		"dnf":    "clang-tools-extra",
+		"zypper": "clang-tools",
+		"apk":    "clang-extra-tools",
+		"emerge": "llvm-core/clang",
+		"brew":   "clang-format",
+		"pkg":    "llvm",
+		"pkgin":  "clang-tools-extra",
+		"msys2":  "clang-tools-extra",
+	},
+	"cppcheck": {
+		"emerge": "dev-util/cppcheck",
+	},
+	"include-what-you-use": {
+		"apt":    "iwyu",
+		"dnf":    "iwyu",
+		"zypper": "include-what-you-use",
+		"emerge": "dev-util/include-what-you-use",
+		"brew":   "include-what-you-use",
+		"pkg":    "include-what-you-use",
+		"msys2":  "include-what-you-use",
+	},
+	"valgrind": {
+		"emerge": "dev-debug/valgrind",
+	},
+	"gdb": {
+		"emerge": "dev-debug/gdb",
+	},
+	"perf": {
+		"apt":    "linux-perf",
+		"emerge": "dev-util/perf",
+	},
+	"upx": {
+		"apt":    "upx-ucl",
+		"emerge": "app-arch/upx",
+	},
+	"zig": {
+		"emerge": "dev-lang/zig",
+	},
+	"ccache": {
+		"emerge": "dev-util/ccache",
+	},
+	"libc-dev": {
+		"apt":          "libc6-dev",
+		"pacman":       "glibc",
+		"dnf":          "glibc-devel",
+		"zypper":       "glibc-devel",
+		"apk":          "musl-dev",
+		"xbps-install": "glibc-devel",
+		"emerge":       "sys-libs/glibc",
+	},
+}
+
+// installSuggestion returns the command for installing the package that provides the given tool
+func installSuggestion(distro, tool string) string {
+	pm, _ := distroPackageManager(distro)
+	pkg := tool
+	if tool == "clang++" {
+		pkg = "clang"
+	}
+	if p, ok := toolPackages[tool][pm.Command]; ok {
+		pkg = p
+	}
+	if pm.Command == "msys2" {
+		pkg = msys2Package(pkg)
+	}
+	return pm.Install + " " + pkg
+}
+
+// doctor keeps track of the results of the environment checks
+type doctor struct {
+	problems int
+}
+
+func (d *doctor) ok(msg string) {
+	fmt.Printf("  ok    %s\n", msg)
+}
+
+func (d *doctor) warn(msg, fix string) {
+	fmt.Printf("  warn  %s\n", msg)
+	if fix != "" {
+		fmt.Printf("        %s\n", fix)
+	}
+}
+
+func (d *doctor) fail(msg, fix string) {
+	d.problems++
+	fmt.Printf("  FAIL  %s\n", msg)
+	if fix != "" {
+		fmt.Printf("        %s\n", fix)
+	}
+}
+
+// firstLine returns the first line of the output of the given command, or "" if it fails
+func firstLine(name string, args ...string) string {
+	return commandFirstLine(exec.Command(name, args...))
+}
+
+// commandFirstLine returns the first line of the output of the given command, or "" if it fails
+func commandFirstLine(cmd *exec.Cmd) string {
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return line
+}
+
+// runDoctor checks that what is needed for building is installed and working, and prints what
+// can be done about anything that is missing. Only problems that stop every build count as failures.
+func runDoctor(o *Options) error {
+	d := &doctor{}
+	fmt.Printf("Checking the build environment on %s\n", o.DetectedDistro)
+
+	pm, known := distroPackageManager(o.DetectedDistro)
+	switch {
+	case !known:
+		d.warn("unknown distro, the package suggestions may not apply", "")
+	case haveCmd(pm.program()):
+		d.ok("package manager: " + pm.Command)
+	default:
+		d.warn("package manager "+pm.program()+" was not found", "")
+	}
+
+	if haveCmd(compilerExecutable(o)) {
+		d.ok("compiler: " + commandFirstLine(compilerCommand(o, "--version")))
+		probe := compilerCommand(o, "-x", "c++", "-std="+o.Std, "-", "-o", os.DevNull)
+		probe.Stdin = strings.NewReader("int main() { return 0; }\n")
+		if out, err := probe.CombinedOutput(); err != nil {
+			msg, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+			d.fail(fmt.Sprintf("%s can not build a -std=%s program: %s", o.CXX, o.Std, msg), "Upgrade the compiler, or use an older standard with std = \"c++17\" in cxx.toml")
+		} else {
+			d.ok(fmt.Sprintf("%s builds -std=%s programs", o.CXX, o.Std))
+		}
+	} else {
+		d.fail("compiler "+o.CXX+" was not found", "Install it with: "+installSuggestion(o.DetectedDistro, compilerExecutable(o)))
+	}
+	other := "clang++"
+	if isClang(o) {
+		other = "g++"
+	}
+	if haveCmd(other) {
+		d.ok("alternative compiler: " + firstLine(other, "--version"))
+	} else {
+		d.warn(other+" was not found, it is only needed for building with it", "Install it with: "+installSuggestion(o.DetectedDistro, other))
+	}
+
+	if haveCmd("pkg-config") {
+		d.ok("pkg-config: " + firstLine("pkg-config", "--version"))
+	} else {
+		d.fail("pkg-config was not found, so flags for libraries can not be found", "Install it with: "+installSuggestion(o.DetectedDistro, "pkg-config"))
+	}
+
+	if o.Launcher != "" {
+		d.ok("compiler cache: " + firstLine(o.Launcher, "--version"))
+	} else {
+		d.warn("no compiler cache was found, rebuilds after cleaning will be slower", "Install it with: "+installSuggestion(o.DetectedDistro, "ccache"))
+	}
+
+	switch engine := containerEngine(); {
+	case !haveContainerEngine():
+		d.warn("docker or podman was not found, it is only needed for --win64-docker", "Install it with: "+installSuggestion(o.DetectedDistro, "docker"))
+	case exec.Command(engine, "info").Run() != nil:
+		if engine == "podman" {
+			d.warn("podman is installed, but it does not work", "Check the output of: podman info")
+		} else {
+			d.warn("docker is installed, but the daemon can not be reached", "Start it with: systemctl start docker, and make sure that you are in the docker group")
+		}
+	default:
+		d.ok(engine)
+	}
+
+	found := 0
+	for _, dir := range discoverSystemIncludeDirs() {
+		if dirExists(dir) {
+			found++
+			d.ok("include directory: " + dir)
+		}
+	}
+	if found == 0 {
+		d.fail("no system include directories were found", "Install the C library development files with: "+installSuggestion(o.DetectedDistro, "libc-dev"))
+	}
+	for _, dir := range o.IncludeDirs {
+		if !dirExists(dir) {
+			d.warn("configured include directory "+dir+" does not exist", "Fix the include setting in cxx.toml")
+		}
+	}
+
+	if err := checkWritable(o.BuildDir); err != nil {
+		d.fail(fmt.Sprintf("the build directory %s is not writable: %v", o.BuildDir, err), "Fix the permissions, or use --build-dir= or build_dir in cxx.toml")
+	} else {
+		d.ok("build directory " + o.BuildDir + " is writable")
+	}
+
+	if d.problems > 0 {
+		return fmt.Errorf("%d problem(s) found", d.problems)
+	}
+	fmt.Println("No problems found")
+	return nil
+}
+
+// checkWritable checks that files can be created in the given directory, creating it if needed
+func checkWritable(dir string) error {
+	existed := dirExists(dir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, "doctor")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	os.Remove(f.Name())
+	if !existed {
+		os.Remove(dir)
+	}
+	return nil
+}
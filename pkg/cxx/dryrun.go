@@ -0,0 +1,36 @@
+package cxx
+
+import (
+	"os"
+)
+
+// makeDir creates the given directory and its parents, unless this is a dry run, where the commands of the
+// build are printed instead of run, and nothing in the build directory or among the outputs is created or changed
+func makeDir(o *Options, dir string) error {
+	if o.DryRun {
+		return nil
+	}
+	return os.MkdirAll(dir, 0o755)
+}
+
+// writeFile writes the given contents to the given file, unless this is a dry run
+func writeFile(o *Options, path string, data []byte) error {
+	if o.DryRun {
+		return nil
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// removeFile removes the given file, unless this is a dry run
+func removeFile(o *Options, path string) {
+	if !o.DryRun {
+		os.Remove(path)
+	}
+}
+
+// removeDir removes the given directory and everything in it, unless this is a dry run
+func removeDir(o *Options, dir string) {
+	if !o.DryRun {
+		os.RemoveAll(dir)
+	}
+}
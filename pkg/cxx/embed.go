@@ -0,0 +1,180 @@
+package cxx
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultAssetsDir is embedded when there is no [embed] section in the configuration file
+const defaultAssetsDir = "assets"
+
+// embedPatterns returns the patterns for the files that should be embedded, from the files in
+// the [embed] section, or everything in assets/ if there is no such section
+func embedPatterns(o *Options) []string {
+	if o.Config != nil && o.Config.Embed != nil {
+		return o.Config.Embed
+	}
+	if dirExists(defaultAssetsDir) {
+		return []string{defaultAssetsDir + "/**"}
+	}
+	return nil
+}
+
+// compilerHasEmbed checks if the compiler supports #embed for the configured standard
+func compilerHasEmbed(o *Options) bool {
+	if isMSVC(o) || o.Win64Docker || !haveCmd(compilerExecutable(o)) {
+		return false
+	}
+	args := []string{"-x", "c++", "-E", "-"}
+	if o.Std != "" {
+		args = append(args, "-std="+o.Std)
+	}
+	cmd := compilerCommand(o, args...)
+	cmd.Stdin = strings.NewReader("#if defined(__has_embed)\ncxx2_has_embed\n#endif\n")
+	out, err := cmd.Output()
+	return err == nil && strings.Contains(string(out), "cxx2_has_embed")
+}
+
+// embedSymbol returns the name of the array that a file is embedded as, like assets_logo_png for assets/logo.png
+func embedSymbol(file string) string {
+	var sb strings.Builder
+	for i, r := range filepath.ToSlash(file) {
+		switch {
+		case r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '_':
+			sb.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				sb.WriteByte('_')
+			}
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String()
+}
+
+// embedHeaderLine returns the first line of the generated header, which also tells if #embed is used
+func embedHeaderLine(file string, useEmbed bool) string {
+	if useEmbed {
+		return "// Generated by cxx2 from " + filepath.ToSlash(file) + ", with #embed"
+	}
+	return "// Generated by cxx2 from " + filepath.ToSlash(file)
+}
+
+// headerStartsWith checks if the first line of the given header is the given line
+func headerStartsWith(header, line string) bool {
+	f, err := os.Open(header)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	return sc.Scan() && sc.Text() == line
+}
+
+// writeEmbedHeader writes a header that defines the contents of the given file as an array of
+// unsigned char, followed by its size. With #embed, the compiler reads the file instead.
+func writeEmbedHeader(o *Options, file, header string, useEmbed bool) error {
+	if o.DryRun {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(header), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(header)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	symbol := embedSymbol(file)
+	fmt.Fprintf(w, "%s\n#pragma once\n", embedHeaderLine(file, useEmbed))
+	if useEmbed {
+		// #embed is an extension before C++26, so -Wpedantic would warn about it
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "#pragma GCC system_header\n\n#include <cstddef>\n\ninline constexpr unsigned char %s[] = {\n#embed %q\n};\n", symbol, filepath.ToSlash(abs))
+	} else {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "\n#include <cstddef>\n\ninline constexpr unsigned char %s[] = {", symbol)
+		for i, c := range b {
+			if i%16 == 0 {
+				w.WriteString("\n   ")
+			}
+			fmt.Fprintf(w, " 0x%02x,", c)
+		}
+		if len(b) == 0 {
+			// Arrays can not be empty
+			w.WriteString("\n    0x00,")
+		}
+		w.WriteString("\n};\n")
+	}
+	fmt.Fprintf(w, "inline constexpr std::size_t %s_size = %d;\n", symbol, fileSize(file))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// fileSize returns the size of the given file, or 0 if it can not be read
+func fileSize(file string) int64 {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
+
+// setupEmbed generates a header for every file that should be embedded into the binary, in the embed
+// directory of the build directory, which is added to the include directories. assets/logo.png is
+// included with #include "assets/logo.png.h", which defines assets_logo_png and assets_logo_png_size.
+// Files are tracked in the compile cache, so that the headers are only generated again when they change.
+func setupEmbed(o *Options) error {
+	patterns := embedPatterns(o)
+	if len(patterns) == 0 {
+		return nil
+	}
+	var files []string
+	if err := walkProject(o.BuildDir, func(path string) {
+		for _, p := range patterns {
+			if globMatch(p, path) {
+				files = append(files, path)
+				return
+			}
+		}
+	}); err != nil || len(files) == 0 {
+		return err
+	}
+	outDir := filepath.Join(o.BuildDir, "embed")
+	if err := prepareBuildDir(o); err != nil {
+		return err
+	}
+	o.IncludeDirs = append(o.IncludeDirs, outDir)
+	cc, err := loadCache(o)
+	if err != nil {
+		return err
+	}
+	useEmbed := compilerHasEmbed(o)
+	for _, file := range files {
+		header := filepath.Join(outDir, file+".h")
+		if !inputChanged(cc, file, header) && headerStartsWith(header, embedHeaderLine(file, useEmbed)) {
+			continue
+		}
+		fmt.Println("Embedding", file)
+		if err := writeEmbedHeader(o, file, header, useEmbed); err != nil {
+			return err
+		}
+		updateTimestamp(file, cc)
+	}
+	saveCache(o, cc)
+	return nil
+}
@@ -0,0 +1,37 @@
+package cxx
+
+import (
+	"os"
+	"strings"
+)
+
+// ignoreEnvironment checks if --no-env is given, for not using $CXX, $CC, $CXXFLAGS, $CFLAGS, $CPPFLAGS and $LDFLAGS
+func ignoreEnvironment(args []string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if arg == "--no-env" {
+			return true
+		}
+	}
+	return false
+}
+
+// applyEnvironment merges $CXX, $CC, $CXXFLAGS, $CFLAGS, $CPPFLAGS and $LDFLAGS into the options, like packagers
+// expect. They come after the configuration file, so that $CXX and $CC replace the compilers in it, and the flags
+// are added after the flags in it. $CXXFLAGS is only used for the C++ sources, and $CFLAGS only for the C sources.
+// The command line arguments are applied after this.
+func applyEnvironment(o *Options) {
+	if cxx := strings.TrimSpace(os.Getenv("CXX")); cxx != "" {
+		o.CXX = cxx
+	}
+	if cc := strings.TrimSpace(os.Getenv("CC")); cc != "" {
+		o.CC = cc
+	}
+	o.ExtraCFlags = append(o.ExtraCFlags, strings.Fields(os.Getenv("CPPFLAGS"))...)
+	o.CXXFlags = strings.Fields(os.Getenv("CXXFLAGS"))
+	o.ExtraCFlags = append(o.ExtraCFlags, o.CXXFlags...)
+	o.CFlags = append(o.CFlags, strings.Fields(os.Getenv("CFLAGS"))...)
+	o.ExtraLDFlags = append(o.ExtraLDFlags, strings.Fields(os.Getenv("LDFLAGS"))...)
+}
@@ -0,0 +1,105 @@
+package cxx
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// resolveInclude returns the header in the project that the given include in the given file refers to,
+// looking next to the file first and then in the local include directories, or "" if it is not in the project
+func resolveInclude(o *Options, file, inc string) string {
+	dirs := append([]string{filepath.Dir(file)}, o.IncludeDirs...)
+	for _, d := range dirs {
+		// Headers from pkg-config and the system are not expected to change while working on the project
+		if filepath.IsAbs(d) {
+			continue
+		}
+		if p := filepath.Join(d, inc); fileExists(p) {
+			return p
+		}
+	}
+	return ""
+}
+
+// projectHeaders returns the headers in the project that the given source includes, directly or through
+// other headers
+func projectHeaders(o *Options, src string) []string {
+	var headers []string
+	seen := map[string]bool{}
+	queue := []string{src}
+	for len(queue) > 0 {
+		file := queue[0]
+		queue = queue[1:]
+		for _, inc := range discoverIncludes(o, file) {
+			if isStdInclude(inc) {
+				continue
+			}
+			h := resolveInclude(o, file, inc)
+			if h == "" || seen[h] {
+				continue
+			}
+			seen[h] = true
+			headers = append(headers, h)
+			queue = append(queue, h)
+		}
+	}
+	return headers
+}
+
+// flagChanges returns the flags that were removed from and added to the given old compile command
+func flagChanges(old, current string) (removed, added []string) {
+	oldFlags, currentFlags := map[string]bool{}, map[string]bool{}
+	for _, f := range strings.Fields(old) {
+		oldFlags[f] = true
+	}
+	for _, f := range strings.Fields(current) {
+		currentFlags[f] = true
+		if !oldFlags[f] {
+			added = append(added, f)
+		}
+	}
+	for _, f := range strings.Fields(old) {
+		if !currentFlags[f] {
+			removed = append(removed, f)
+		}
+	}
+	return removed, added
+}
+
+// explainRebuilds prints why each of the given sources, or all of them, would or would not be compiled
+// by the next build, without compiling them
+func explainRebuilds(o *Options, cc *CompileCache) error {
+	srcs := o.ExplainSources
+	if len(srcs) == 0 {
+		srcs = append(append([]string{}, o.Sources...), o.BenchSources...)
+	}
+	for _, s := range srcs {
+		if !contains(o.Sources, s) && !contains(o.BenchSources, s) {
+			return fmt.Errorf("%s is not one of the sources", s)
+		}
+		obj := objectPath(o, s)
+		reason := rebuildReason(o, s, obj, cc)
+		if reason == "" && moduleInterfaceChanged(o, s, obj) {
+			reason = "an imported module has changed"
+		}
+		if reason == "" {
+			fmt.Printf("%s is up to date, in %s\n", s, obj)
+			continue
+		}
+		fmt.Printf("%s will be compiled, since %s\n", s, reason)
+		cc.mu.Lock()
+		old := cc.Commands[s]
+		cc.mu.Unlock()
+		if old != "" && reason == "the compile flags have changed" {
+			removed, added := flagChanges(old, buildCompileCmd(o, s, obj))
+			if len(removed) > 0 {
+				fmt.Println("  removed:", strings.Join(removed, " "))
+			}
+			if len(added) > 0 {
+				fmt.Println("  added:", strings.Join(added, " "))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package cxx
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// thirdPartyDir is where fetched header-only libraries are placed, relative to the project
+const thirdPartyDir = "third_party"
+
+// stbURL is where the stb headers are downloaded from. stb has no releases, so a commit is pinned instead,
+// which keeps the checksums in cxx2.lock valid when the main branch changes.
+const stbURL = "https://raw.githubusercontent.com/nothings/stb/f7f20f39fe4f206c6f19e26ebfef7b261ee59ee4/"
+
+// headerOnlyLibraries maps from an include to where a pinned release of the single header can be downloaded
+var headerOnlyLibraries = map[string]string{
+	"nlohmann/json.hpp":     "https://github.com/nlohmann/json/releases/download/v3.11.3/json.hpp",
+	"nlohmann/json_fwd.hpp": "https://github.com/nlohmann/json/releases/download/v3.11.3/json_fwd.hpp",
+	"catch2/catch.hpp":      "https://github.com/catchorg/Catch2/releases/download/v2.13.10/catch.hpp",
+	"catch.hpp":             "https://github.com/catchorg/Catch2/releases/download/v2.13.10/catch.hpp",
+	"doctest.h":             "https://raw.githubusercontent.com/doctest/doctest/v2.4.11/doctest/doctest.h",
+	"doctest/doctest.h":     "https://raw.githubusercontent.com/doctest/doctest/v2.4.11/doctest/doctest.h",
+	"cxxopts.hpp":           "https://raw.githubusercontent.com/jarro2783/cxxopts/v3.2.0/include/cxxopts.hpp",
+	"CLI11.hpp":             "https://github.com/CLIUtils/CLI11/releases/download/v2.4.2/CLI11.hpp",
+	"httplib.h":             "https://raw.githubusercontent.com/yhirose/cpp-httplib/v0.15.3/httplib.h",
+	"toml.hpp":              "https://raw.githubusercontent.com/marzer/tomlplusplus/v3.4.0/toml.hpp",
+	"argparse/argparse.hpp": "https://raw.githubusercontent.com/p-ranav/argparse/v3.0/include/argparse/argparse.hpp",
+	"nanobench.h":           "https://raw.githubusercontent.com/martinus/nanobench/v4.3.11/src/include/nanobench.h",
+	"stb_image.h":           stbURL + "stb_image.h",
+	"stb_image_write.h":     stbURL + "stb_image_write.h",
+	"stb_image_resize2.h":   stbURL + "stb_image_resize2.h",
+	"stb_truetype.h":        stbURL + "stb_truetype.h",
+	"stb_ds.h":              stbURL + "stb_ds.h",
+}
+
+// downloadFile downloads the given URL to the given path
+func downloadFile(url, path string) error {
+	client := &http.Client{Timeout: 2 * time.Minute}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".part"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// fetchHeaders downloads the known header-only libraries among the missing headers into third_party/,
+// verifies or records their checksums in the lockfile and returns the headers that are still missing
+func fetchHeaders(o *Options, missing []string, lock *Lock) ([]string, error) {
+	var still []string
+	for _, h := range missing {
+		url, ok := headerOnlyLibraries[h]
+		if !ok {
+			still = append(still, h)
+			continue
+		}
+		dst := filepath.Join(thirdPartyDir, h)
+		fmt.Printf("Fetching %s from %s\n", h, url)
+		if o.DryRun {
+			continue
+		}
+		if err := downloadFile(url, dst); err != nil {
+			return nil, err
+		}
+		if err := lock.checkFetched(h, url); err != nil {
+			os.Remove(dst)
+			return nil, err
+		}
+	}
+	if !contains(o.IncludeDirs, thirdPartyDir) {
+		o.IncludeDirs = append(o.IncludeDirs, thirdPartyDir)
+	}
+	return still, nil
+}
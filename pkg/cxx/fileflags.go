@@ -0,0 +1,72 @@
+package cxx
+
+import (
+	"sort"
+	"strings"
+)
+
+// FileFlags are the flags for the sources that match a pattern, from a [files."PATTERN"] table:
+//
+//	[files."third_party/**"]
+//	cflags = ["-w"]
+//
+//	[files."simd/*.cpp"]
+//	cflags = ["-mavx2"]
+//	remove = ["-Wshadow"]
+//
+// The patterns are matched like the exclude patterns. The flags in remove are taken out of the flags that
+// the source would otherwise be compiled with, then the flags in cflags are added.
+type FileFlags struct {
+	Pattern string
+	CFlags  []string
+	Remove  []string
+}
+
+// fileFlagOverrides returns the flags in the [files."PATTERN"] tables, sorted by the pattern,
+// so that they are applied in the same order for every build
+func fileFlagOverrides(tables map[string]map[string]any) []FileFlags {
+	var overrides []FileFlags
+	for name, t := range tables {
+		if pattern, ok := strings.CutPrefix(name, "files."); ok {
+			overrides = append(overrides, FileFlags{Pattern: pattern, CFlags: configStrings(t, "cflags"), Remove: configStrings(t, "remove")})
+		}
+	}
+	sort.Slice(overrides, func(i, j int) bool { return overrides[i].Pattern < overrides[j].Pattern })
+	return overrides
+}
+
+// fileFlagOverride returns the flags that are added for the given source and the flags that are removed,
+// from every [files."PATTERN"] table that matches it
+func fileFlagOverride(o *Options, src string) ([]string, []string) {
+	if o.Config == nil {
+		return nil, nil
+	}
+	var add, remove []string
+	for _, ff := range o.Config.FileFlags {
+		if globMatch(ff.Pattern, src) {
+			add = append(add, ff.CFlags...)
+			remove = append(remove, ff.Remove...)
+		}
+	}
+	return add, remove
+}
+
+// fileFlags applies the overrides for the given source to the compilation flags and the extra flags.
+// The flags are returned as they are when no pattern matches, so that the compile commands do not change.
+func fileFlags(o *Options, src, flags, extra string) (string, string) {
+	if o.Config == nil {
+		return flags, extra
+	}
+	for _, ff := range o.Config.FileFlags {
+		if !globMatch(ff.Pattern, src) {
+			continue
+		}
+		fs, es := strings.Fields(flags), strings.Fields(extra)
+		for _, flag := range ff.Remove {
+			fs = removeFromSlice(fs, flag)
+			es = removeFromSlice(es, flag)
+		}
+		flags, extra = strings.Join(fs, " "), strings.Join(append(es, ff.CFlags...), " ")
+	}
+	return flags, extra
+}
@@ -0,0 +1,122 @@
+package cxx
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// defaultClangFormat is written to .clang-format by "fmt", when the project does not have one
+const defaultClangFormat = `BasedOnStyle: LLVM
+IndentWidth: 4
+ColumnLimit: 120
+AllowShortFunctionsOnASingleLine: Inline
+SortIncludes: true
+`
+
+// clangFormat returns the clang-format that matches the selected compiler, like clang-format-17 for clang++-17
+func clangFormat(o *Options) string {
+	if isClang(o) {
+		if v := compilerVersionSuffix(o); v != "" && haveCmd("clang-format"+v) {
+			return "clang-format" + v
+		}
+	}
+	return "clang-format"
+}
+
+// hasClangFormatFile checks if there is a .clang-format or _clang-format file in the current directory
+// or in one of the parent directories, which is where clang-format looks for it
+func hasClangFormatFile() bool {
+	dir := mustPwd()
+	for {
+		for _, name := range []string{".clang-format", "_clang-format"} {
+			if fileExists(filepath.Join(dir, name)) {
+				return true
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+// formatFiles returns the sources and headers in the project that should be formatted
+func formatFiles(o *Options) ([]string, error) {
+	var files []string
+	err := walkProject(o.BuildDir, func(path string) {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".c", ".cc", ".cpp", ".cxx", ".c++", ".cppm", ".ixx", ".mpp", ".cu", ".hip":
+			files = append(files, path)
+		default:
+			if isHeader(path) {
+				files = append(files, path)
+			}
+		}
+	})
+	return excludeSources(files, o.Exclude), err
+}
+
+// runFormat formats the sources and headers with clang-format and lists the files that were changed.
+// A .clang-format file with the default style is written first, if there is none. With --check,
+// no files are changed, and the files that are not formatted are listed and make it fail instead.
+func runFormat(o *Options) error {
+	tool := clangFormat(o)
+	if !haveCmd(tool) {
+		return fmt.Errorf("%s was not found, install it with: %s", tool, installSuggestion(o.DetectedDistro, "clang-format"))
+	}
+	style := "--style=file"
+	if !hasClangFormatFile() {
+		if o.FormatCheck {
+			style = "--style={" + strings.Join(strings.Split(strings.TrimSpace(defaultClangFormat), "\n"), ", ") + "}"
+		} else {
+			if err := os.WriteFile(".clang-format", []byte(defaultClangFormat), 0o644); err != nil {
+				return err
+			}
+			fmt.Println("Wrote .clang-format")
+		}
+	}
+	files, err := formatFiles(o)
+	if err != nil {
+		return err
+	}
+	var changed []string
+	for _, f := range files {
+		orig, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		var stderr bytes.Buffer
+		cmd := exec.Command(tool, style, f)
+		cmd.Stderr = &stderr
+		formatted, err := cmd.Output()
+		if err != nil {
+			return fmt.Errorf("%s %s failed: %v %s", tool, f, err, strings.TrimSpace(stderr.String()))
+		}
+		if bytes.Equal(orig, formatted) {
+			continue
+		}
+		changed = append(changed, f)
+		if o.FormatCheck {
+			fmt.Println("Not formatted:", f)
+			continue
+		}
+		if err := os.WriteFile(f, formatted, 0o644); err != nil {
+			return err
+		}
+		fmt.Println("Formatted:", f)
+	}
+	switch {
+	case len(changed) == 0:
+		fmt.Printf("All %d file(s) are formatted\n", len(files))
+	case o.FormatCheck:
+		return fmt.Errorf("%d of %d file(s) are not formatted, run: cxx2 fmt", len(changed), len(files))
+	default:
+		fmt.Printf("Formatted %d of %d file(s)\n", len(changed), len(files))
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// depsDir is where git dependencies are cloned, relative to the project
+const depsDir = "deps"
+
+// GitDependency is a dependency that is declared in the configuration file as a git repository:
+//
+//	[dependencies.fmt]
+//	git = "https://github.com/fmtlib/fmt"
+//	tag = "10.2.1"
+//	include = ["include"]
+//	sources = ["src/format.cc", "src/os.cc"]
+//
+// The include directories default to include/, if it exists, or else the top directory of the repository.
+// The sources default to the sources in src/, or else in the top directory, that are not tests and have no main function.
+type GitDependency struct {
+	Name        string
+	URL         string
+	Tag         string
+	IncludeDirs []string
+	Sources     []string
+}
+
+// gitDependencies returns the dependencies in the [dependencies.NAME] tables, sorted by name
+func gitDependencies(tables map[string]map[string]any) []GitDependency {
+	var deps []GitDependency
+	for name, t := range tables {
+		name, ok := strings.CutPrefix(name, "dependencies.")
+		if !ok || configString(t, "git") == "" {
+			continue
+		}
+		deps = append(deps, GitDependency{
+			Name:        name,
+			URL:         configString(t, "git"),
+			Tag:         configString(t, "tag"),
+			IncludeDirs: configStrings(t, "include"),
+			Sources:     configStrings(t, "sources"),
+		})
+	}
+	sort.Slice(deps, func(i, j int) bool { return deps[i].Name < deps[j].Name })
+	return deps
+}
+
+// dir returns where the dependency is cloned
+func (d GitDependency) dir() string {
+	return filepath.Join(depsDir, d.Name)
+}
+
+// isManagedDependency checks if the given directory is a git checkout directly below deps/,
+// which are built as dependencies instead of as a part of the project
+func isManagedDependency(path string) bool {
+	return filepath.Dir(path) == depsDir && isGitCheckout(path)
+}
+
+// isGitCheckout checks if the given directory is a git clone or an initialized submodule,
+// where .git is a directory or a file
+func isGitCheckout(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// runGit runs git with the given arguments on the host, also when building with Docker
+func runGit(o *Options, args ...string) error {
+	fmt.Println("git", strings.Join(args, " "))
+	if o.DryRun {
+		return nil
+	}
+	c := exec.Command("git", args...)
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	return c.Run()
+}
+
+// gitOutput returns the trimmed output of git with the given arguments
+func gitOutput(args ...string) string {
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// isSubmodule checks if the given directory is a submodule of the project
+func isSubmodule(dir string) bool {
+	b, err := os.ReadFile(".gitmodules")
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(line), "="); ok && strings.TrimSpace(k) == "path" &&
+			filepath.Clean(strings.TrimSpace(v)) == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// syncGitDependency clones the dependency, or initializes it if it is a submodule, and checks out
+// the locked commit, if any, or else the configured tag if another revision is checked out
+func syncGitDependency(o *Options, d GitDependency, commit string) error {
+	dir := d.dir()
+	if !isGitCheckout(dir) {
+		var err error
+		if isSubmodule(dir) {
+			err = runGit(o, "submodule", "update", "--init", "--recursive", dir)
+		} else {
+			args := []string{"clone", "--recurse-submodules", "--depth", "1"}
+			if d.Tag != "" {
+				args = append(args, "--branch", d.Tag)
+			}
+			err = runGit(o, append(args, d.URL, dir)...)
+		}
+		if err != nil || commit == "" {
+			return err
+		}
+	}
+	if commit != "" {
+		return checkoutCommit(o, dir, commit)
+	}
+	if d.Tag == "" {
+		return nil
+	}
+	head := gitOutput("-C", dir, "rev-parse", "HEAD")
+	if head != "" && head == gitOutput("-C", dir, "rev-parse", "--verify", "--quiet", d.Tag+"^{commit}") {
+		return nil
+	}
+	if err := runGit(o, "-C", dir, "fetch", "--depth", "1", "origin", "tag", d.Tag, "--no-tags"); err != nil {
+		return err
+	}
+	return runGit(o, "-C", dir, "checkout", "--recurse-submodules", d.Tag)
+}
+
+// checkoutCommit checks out the given commit in the given clone, fetching it if needed
+func checkoutCommit(o *Options, dir, commit string) error {
+	if gitOutput("-C", dir, "rev-parse", "HEAD") == commit {
+		return nil
+	}
+	if gitOutput("-C", dir, "rev-parse", "--verify", "--quiet", commit+"^{commit}") == "" {
+		if err := runGit(o, "-C", dir, "fetch", "--depth", "1", "origin", commit); err != nil {
+			return err
+		}
+	}
+	if err := runGit(o, "-C", dir, "checkout", "--recurse-submodules", commit); err != nil {
+		return err
+	}
+	if head := gitOutput("-C", dir, "rev-parse", "HEAD"); head != commit {
+		return fmt.Errorf("%s is at %s, but %s is locked to %s", dir, head, lockFilename, commit)
+	}
+	return nil
+}
+
+// includeDirs returns the include directories of the dependency
+func (d GitDependency) includeDirs() []string {
+	if len(d.IncludeDirs) > 0 {
+		dirs := make([]string, len(d.IncludeDirs))
+		for i, inc := range d.IncludeDirs {
+			dirs[i] = filepath.Join(d.dir(), inc)
+		}
+		return dirs
+	}
+	if inc := filepath.Join(d.dir(), "include"); dirExists(inc) {
+		return []string{inc}
+	}
+	return []string{d.dir()}
+}
+
+// sources returns the sources of the dependency that are built into a static library
+func (d GitDependency) sources() []string {
+	var srcs []string
+	if len(d.Sources) > 0 {
+		for _, pattern := range d.Sources {
+			matches, _ := filepath.Glob(filepath.Join(d.dir(), pattern))
+			srcs = append(srcs, matches...)
+		}
+		return srcs
+	}
+	dir := d.dir()
+	if src := filepath.Join(dir, "src"); dirExists(src) {
+		dir = src
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	for _, e := range entries {
+		p := filepath.Join(dir, e.Name())
+		switch strings.ToLower(filepath.Ext(p)) {
+		case ".c", ".cc", ".cpp", ".cxx":
+			if !e.IsDir() && !isTestSource(p) && !hasMainFunction(p) {
+				srcs = append(srcs, p)
+			}
+		}
+	}
+	return srcs
+}
+
+// dependencyLibrary returns where the static library of the dependency is placed
+func dependencyLibrary(o *Options, d GitDependency) string {
+	if isMSVC(o) {
+		return filepath.Join(o.BuildDir, "deps", d.Name+".lib")
+	}
+	return filepath.Join(o.BuildDir, "deps", staticLibraryName(d.Name))
+}
+
+// setupGitDependencies clones or updates the git dependencies from the configuration file,
+// locks them to the commits that are checked out and adds their include directories
+func setupGitDependencies(o *Options, lock *Lock) error {
+	if o.Config == nil {
+		return nil
+	}
+	for _, d := range o.Config.Dependencies {
+		if err := syncGitDependency(o, d, lock.lockedCommit(d)); err != nil {
+			return fmt.Errorf("%s: %w", d.Name, err)
+		}
+		if head := gitOutput("-C", d.dir(), "rev-parse", "HEAD"); head != "" {
+			lock.lockGit(d, head)
+		}
+		for _, inc := range d.includeDirs() {
+			if !contains(o.IncludeDirs, inc) {
+				o.IncludeDirs = append(o.IncludeDirs, inc)
+			}
+		}
+	}
+	return nil
+}
+
+// buildGitDependencies builds the git dependencies that have sources as static libraries,
+// and links the project with them
+func buildGitDependencies(o *Options, cc *CompileCache) error {
+	if o.Config == nil {
+		return nil
+	}
+	var libs []string
+	for _, d := range o.Config.Dependencies {
+		srcs := d.sources()
+		if len(srcs) == 0 {
+			// Header-only
+			continue
+		}
+		objs, err := compileAll(o, cc, srcs)
+		if err != nil {
+			return fmt.Errorf("%s: %w", d.Name, err)
+		}
+		lib := dependencyLibrary(o, d)
+		if err := makeDir(o, filepath.Dir(lib)); err != nil {
+			return err
+		}
+		removeFile(o, lib)
+		if err := runCommand(archiveCmd(o, lib, objs), o); err != nil {
+			return fmt.Errorf("%s: %w", d.Name, err)
+		}
+		libs = append(libs, lib)
+	}
+	// Dependencies are linked after the objects of the project, but before the other libraries
+	o.ExtraLDFlags = append(libs, o.ExtraLDFlags...)
+	return nil
+}
@@ -0,0 +1,83 @@
+package cxx
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// isGrammar checks if the file is a bison grammar (.y or .yy) or a flex lexer (.l or .ll)
+func isGrammar(p string) bool {
+	switch filepath.Ext(p) {
+	case ".y", ".yy", ".l", ".ll":
+		return true
+	}
+	return false
+}
+
+// grammarOutputs returns the C++ source and header that bison generates for a grammar, like
+// parser.tab.cpp and parser.tab.hpp for parser.y, or the C++ source that flex generates for a lexer,
+// like lexer.yy.cpp for lexer.l
+func grammarOutputs(genDir, file string) (string, string) {
+	base := filepath.Join(genDir, strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)))
+	switch filepath.Ext(file) {
+	case ".y", ".yy":
+		return base + ".tab.cpp", base + ".tab.hpp"
+	}
+	return base + ".yy.cpp", ""
+}
+
+// grammarTool returns bison or flex for the given grammar, with the arguments for generating the outputs
+func grammarTool(file, src, header string) (string, []string) {
+	if header != "" {
+		return "bison", []string{"-o", src, "--defines=" + header, file}
+	}
+	return "flex", []string{"-o", src, file}
+}
+
+// setupGrammars generates C++ sources from the bison grammars and flex lexers in the project, into
+// the grammar directory of the build directory, which is added to the include directories for the
+// parser headers. Grammars are tracked in the compile cache, so that they are only generated again
+// when they change. The generated sources that should be compiled are returned.
+func setupGrammars(o *Options) ([]string, error) {
+	var grammars []string
+	if err := walkProject(o.BuildDir, func(path string) {
+		if isGrammar(path) {
+			grammars = append(grammars, path)
+		}
+	}); err != nil || len(grammars) == 0 {
+		return nil, err
+	}
+	genDir := filepath.Join(o.BuildDir, "grammar")
+	if err := prepareBuildDir(o); err != nil {
+		return nil, err
+	}
+	if err := makeDir(o, genDir); err != nil {
+		return nil, err
+	}
+	o.IncludeDirs = append(o.IncludeDirs, genDir)
+	cc, err := loadCache(o)
+	if err != nil {
+		return nil, err
+	}
+	var generated []string
+	for _, g := range grammars {
+		src, header := grammarOutputs(genDir, g)
+		if inputChanged(cc, g, src, header) {
+			tool, args := grammarTool(g, src, header)
+			if !haveCmd(tool) {
+				return nil, fmt.Errorf("%s is needed for %s, install it with: %s", tool, g, installSuggestion(o.DetectedDistro, tool))
+			}
+			if err := runVisible(o, tool, args...); err != nil {
+				return nil, err
+			}
+			updateTimestamp(g, cc)
+		}
+		if !contains(o.Sources, src) {
+			o.Sources = append(o.Sources, src)
+		}
+		generated = append(generated, src)
+	}
+	saveCache(o, cc)
+	return generated, nil
+}
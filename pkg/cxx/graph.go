@@ -0,0 +1,167 @@
+package cxx
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// graphEdge is a dependency of one file on another, like of an object file on its source
+type graphEdge struct {
+	from, to string
+}
+
+// dependencyGraph returns the dependencies of each output on its object files, of each object file on its source,
+// and of each source and header on the headers in the project that it includes, together with the kind of each
+// file, which is "output", "object", "source" or "header"
+func dependencyGraph(o *Options) ([]graphEdge, map[string]string) {
+	var edges []graphEdge
+	kinds := map[string]string{}
+	seen := map[graphEdge]bool{}
+	add := func(from, to string) {
+		e := graphEdge{from, to}
+		if !seen[e] {
+			seen[e] = true
+			edges = append(edges, e)
+		}
+	}
+	var files []string
+	for _, step := range buildPlan(o) {
+		if step.Kind == "compile" {
+			kinds[step.Output] = "object"
+			for _, in := range step.Inputs {
+				kinds[in] = "source"
+			}
+			files = append(files, step.Inputs...)
+		} else {
+			kinds[step.Output] = "output"
+		}
+		for _, in := range step.Inputs {
+			add(step.Output, in)
+		}
+	}
+	scanned := map[string]bool{}
+	for len(files) > 0 {
+		f := files[0]
+		files = files[1:]
+		if scanned[f] {
+			continue
+		}
+		scanned[f] = true
+		for _, inc := range discoverIncludes(o, f) {
+			if isStdInclude(inc) {
+				continue
+			}
+			if h := resolveInclude(o, f, inc); h != "" {
+				if kinds[h] == "" {
+					kinds[h] = "header"
+				}
+				add(f, h)
+				files = append(files, h)
+			}
+		}
+	}
+	return edges, kinds
+}
+
+// filterGraph returns the edges between the files that the given file depends on, directly or indirectly,
+// and between the files that depend on it
+func filterGraph(edges []graphEdge, file string) []graphEdge {
+	reach := func(next func(graphEdge) (string, string)) map[string]bool {
+		found := map[string]bool{file: true}
+		for changed := true; changed; {
+			changed = false
+			for _, e := range edges {
+				from, to := next(e)
+				if found[from] && !found[to] {
+					found[to] = true
+					changed = true
+				}
+			}
+		}
+		return found
+	}
+	deps := reach(func(e graphEdge) (string, string) { return e.from, e.to })
+	users := reach(func(e graphEdge) (string, string) { return e.to, e.from })
+	var kept []graphEdge
+	for _, e := range edges {
+		if (deps[e.from] && deps[e.to]) || (users[e.from] && users[e.to]) {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// printGraph prints the dependency graph of the project in the Graphviz DOT format, or as a Mermaid flowchart
+// with --mermaid, only with what depends on and is depended on by the given file or target, if there is one
+func printGraph(o *Options) error {
+	edges, kinds := dependencyGraph(o)
+	if o.GraphFilter != "" {
+		if kinds[o.GraphFilter] == "" {
+			return fmt.Errorf("%s is not in the dependency graph", o.GraphFilter)
+		}
+		edges = filterGraph(edges, o.GraphFilter)
+	}
+	nodes := []string{}
+	if o.GraphFilter != "" {
+		nodes = append(nodes, o.GraphFilter)
+	}
+	for _, e := range edges {
+		for _, n := range []string{e.from, e.to} {
+			if !contains(nodes, n) {
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	sort.SliceStable(nodes, func(i, j int) bool { return graphRank(kinds[nodes[i]]) < graphRank(kinds[nodes[j]]) })
+	if o.Mermaid {
+		printMermaid(nodes, edges, kinds)
+	} else {
+		printDOT(nodes, edges, kinds)
+	}
+	return nil
+}
+
+// graphRank orders the kinds of files from the outputs to the headers
+func graphRank(kind string) int {
+	return map[string]int{"output": 0, "object": 1, "source": 2, "header": 3}[kind]
+}
+
+// printDOT prints the graph for Graphviz, like: cxx2 graph | dot -Tsvg -o graph.svg
+func printDOT(nodes []string, edges []graphEdge, kinds map[string]string) {
+	attrs := map[string]string{
+		"output": "shape=box, style=bold",
+		"object": "shape=box, style=dashed",
+		"source": "shape=box",
+		"header": "shape=note",
+	}
+	fmt.Println("digraph dependencies {")
+	fmt.Println("  rankdir=LR;")
+	for _, n := range nodes {
+		fmt.Printf("  %q [%s];\n", n, attrs[kinds[n]])
+	}
+	for _, e := range edges {
+		fmt.Printf("  %q -> %q;\n", e.from, e.to)
+	}
+	fmt.Println("}")
+}
+
+// printMermaid prints the graph as a Mermaid flowchart, which can be placed in Markdown on GitHub
+func printMermaid(nodes []string, edges []graphEdge, kinds map[string]string) {
+	shapes := map[string][2]string{
+		"output": {"[[", "]]"},
+		"object": {"(", ")"},
+		"source": {"[", "]"},
+		"header": {"[/", "/]"},
+	}
+	ids := map[string]string{}
+	fmt.Println("flowchart LR")
+	for i, n := range nodes {
+		ids[n] = fmt.Sprintf("n%d", i)
+		s := shapes[kinds[n]]
+		fmt.Printf("  %s%s\"%s\"%s\n", ids[n], s[0], strings.ReplaceAll(n, `"`, "#quot;"), s[1])
+	}
+	for _, e := range edges {
+		fmt.Printf("  %s --> %s\n", ids[e.from], ids[e.to])
+	}
+}
@@ -0,0 +1,38 @@
+package cxx
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	gtestPassedRx = regexp.MustCompile(`(?m)^\[  PASSED  \] (\d+) tests?\.`)
+	gtestFailedRx = regexp.MustCompile(`(?m)^\[  FAILED  \] (\d+) tests?, listed below:`)
+)
+
+// gtestMainLibraries returns the libraries that provide a main function for GoogleTest sources without one,
+// from pkg-config if possible
+func gtestMainLibraries(o *Options) []string {
+	if isMSVC(o) {
+		return []string{"gtest_main.lib"}
+	}
+	if out, err := runShellCommand("pkg-config --libs gtest_main"); err == nil && strings.TrimSpace(out) != "" {
+		return strings.Fields(out)
+	}
+	return []string{"-lgtest_main"}
+}
+
+// gtestCounts returns the number of passed and failed tests from the output of a GoogleTest executable,
+// and false if the output has no summary
+func gtestCounts(output string) (passed, failed int, ok bool) {
+	m := gtestPassedRx.FindStringSubmatch(output)
+	if m == nil {
+		return 0, 0, false
+	}
+	passed, _ = strconv.Atoi(m[1])
+	if m := gtestFailedRx.FindStringSubmatch(output); m != nil {
+		failed, _ = strconv.Atoi(m[1])
+	}
+	return passed, failed, true
+}
@@ -0,0 +1,83 @@
+package cxx
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// builtinHeaders maps from headers to the pkg-config modules and distro packages that provide them
+//
+//go:embed headers.toml
+var builtinHeaders string
+
+// userHeadersFile is where entries can be added to or overridden in the header database,
+// relative to the user configuration directory
+const userHeadersFile = "cxx2/headers.toml"
+
+var (
+	headerDBOnce sync.Once
+	headerDB     map[string]map[string]any
+)
+
+// headerDatabase returns the built-in header database, merged with the one of the user, if any
+func headerDatabase() map[string]map[string]any {
+	headerDBOnce.Do(func() {
+		db, err := parseTables(strings.NewReader(builtinHeaders), "headers.toml")
+		if err != nil {
+			panic(err)
+		}
+		headerDB = map[string]map[string]any{}
+		for h, entry := range db {
+			headerDB[strings.ToLower(h)] = entry
+		}
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return
+		}
+		p := filepath.Join(dir, userHeadersFile)
+		f, err := os.Open(p)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		user, err := parseTables(f, p)
+		if err != nil {
+			fmt.Println("Ignoring the user header database:", err)
+			return
+		}
+		for h, entry := range user {
+			h = strings.ToLower(h)
+			if headerDB[h] == nil {
+				headerDB[h] = map[string]any{}
+			}
+			for k, v := range entry {
+				headerDB[h][k] = v
+			}
+		}
+	})
+	return headerDB
+}
+
+// lookupHeader returns the header database entry for the given header, or nil if it is not known.
+// An exact match is preferred, and otherwise the longest matching "prefix*" entry is used.
+func lookupHeader(h string) map[string]any {
+	db := headerDatabase()
+	h = strings.ToLower(h)
+	if entry, ok := db[h]; ok {
+		return entry
+	}
+	best := ""
+	for k := range db {
+		if prefix, ok := strings.CutSuffix(k, "*"); ok && strings.HasPrefix(h, prefix) && len(k) > len(best) {
+			best = k
+		}
+	}
+	if best == "" {
+		return nil
+	}
+	return db[best]
+}
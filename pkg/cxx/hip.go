@@ -0,0 +1,72 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// isHIPSource checks if the source is HIP, which is compiled with hipcc. HIP sources either have
+// the .hip extension, or are C++ sources that include the HIP runtime.
+func isHIPSource(o *Options, s string) bool {
+	if strings.ToLower(filepath.Ext(s)) == ".hip" {
+		return true
+	}
+	return contains(discoverIncludes(o, s), "hip/hip_runtime.h")
+}
+
+// hasHIPSources checks if any of the sources are HIP
+func hasHIPSources(o *Options, srcs []string) bool {
+	for _, s := range srcs {
+		if isHIPSource(o, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// rocmRoot returns where ROCm is installed, from $ROCM_PATH or $HIP_PATH, or /opt/rocm,
+// or "" if it was not found
+func rocmRoot() string {
+	for _, dir := range []string{os.Getenv("ROCM_PATH"), os.Getenv("HIP_PATH"), "/opt/rocm"} {
+		if dir != "" && fileExists(filepath.Join(dir, "bin", "hipcc")) {
+			return dir
+		}
+	}
+	return ""
+}
+
+// hipccCompiler returns hipcc from ROCm, or from PATH, or "" if it is not installed
+func hipccCompiler() string {
+	if root := rocmRoot(); root != "" {
+		return filepath.Join(root, "bin", "hipcc")
+	}
+	if haveCmd("hipcc") {
+		return "hipcc"
+	}
+	return ""
+}
+
+// setupHIP checks that hipcc is there when there are HIP sources, and adds the include directory
+// and the HIP runtime library of ROCm, if it is not installed into /usr like on Debian
+func setupHIP(o *Options) error {
+	if !hasHIPSources(o, o.Sources) {
+		return nil
+	}
+	if o.Win64Docker || o.Target != "" || o.Wasm || isZig(o) || isMSVC(o) {
+		return fmt.Errorf("HIP sources can only be built for the host, with hipcc")
+	}
+	if hipccCompiler() == "" {
+		return fmt.Errorf("hipcc was not found, install ROCm with: %s", installSuggestion(o.DetectedDistro, "hipcc"))
+	}
+	if root := rocmRoot(); root != "" {
+		inc := filepath.Join(root, "include")
+		o.SystemIncludeDirs = append(o.SystemIncludeDirs, inc)
+		o.ExtraCFlags = append(o.ExtraCFlags, "-I"+inc)
+		lib := filepath.Join(root, "lib")
+		o.ExtraLDFlags = append(o.ExtraLDFlags, "-L"+lib, "-Wl,-rpath,"+lib)
+	}
+	o.ExtraLDFlags = append(o.ExtraLDFlags, "-lamdhip64")
+	return nil
+}
@@ -0,0 +1,208 @@
+package cxx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+)
+
+// historyKept is how many builds are kept in the history
+const historyKept = 200
+
+// statsShown is how many of the most recent builds "stats" shows
+const statsShown = 15
+
+// buildRecord is what the history keeps of one build, with the duration in seconds
+type buildRecord struct {
+	Time      time.Time `json:"time"`
+	Mode      string    `json:"mode"`
+	Success   bool      `json:"success"`
+	Duration  float64   `json:"duration"`
+	Files     int       `json:"files"`
+	CacheHits int       `json:"cache_hits"`
+	Output    string    `json:"output,omitempty"`
+	Size      int64     `json:"size,omitempty"`
+}
+
+// historyPath returns the file with one JSON record per line for the builds with the given build directory,
+// before it is changed for the build mode, so that the builds of all modes are kept in the same history
+func historyPath(buildDir string) string {
+	return filepath.Join(buildDir, "history.jsonl")
+}
+
+// readHistory returns the builds in the history, the oldest first
+func readHistory(buildDir string) []buildRecord {
+	var records []buildRecord
+	b, err := os.ReadFile(historyPath(buildDir))
+	if err != nil {
+		return nil
+	}
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		var r buildRecord
+		if json.Unmarshal(sc.Bytes(), &r) == nil {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// recordBuild adds the build in the given report to the history, if anything was compiled or linked.
+// The mode is followed by the build directory relative to the given one, if it is not the same, like "opt (bench)".
+func recordBuild(buildDir string, o *Options, r *buildReport) {
+	if len(r.Compiles) == 0 && len(r.Links) == 0 {
+		return
+	}
+	mode := buildMode(o)
+	if rel, err := filepath.Rel(buildDir, o.BuildDir); err == nil && rel != "." && rel != mode {
+		mode += " (" + filepath.ToSlash(rel) + ")"
+	}
+	rec := buildRecord{
+		Time:      r.start,
+		Mode:      mode,
+		Success:   r.Success,
+		Duration:  r.Duration,
+		Files:     len(r.Compiles),
+		CacheHits: r.CacheHits,
+	}
+	if o.OutputName != "" && fileExists(o.OutputName) {
+		rec.Output = o.OutputName
+		rec.Size = fileSize(o.OutputName)
+	}
+	records := append(readHistory(buildDir), rec)
+	if len(records) > historyKept {
+		records = records[len(records)-historyKept:]
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, rec := range records {
+		enc.Encode(rec)
+	}
+	if err := makeDir(o, buildDir); err == nil {
+		writeFile(o, historyPath(buildDir), buf.Bytes())
+	}
+}
+
+// sizeKey returns the output and mode of the build, since sizes are only comparable for the same of both
+func (r buildRecord) sizeKey() string {
+	return r.Output + " " + r.Mode
+}
+
+// formatSize returns the given number of bytes in B, KiB or MiB
+func formatSize(n int64) string {
+	switch {
+	case n < 0:
+		return "-" + formatSize(-n)
+	case n < 1024:
+		return fmt.Sprintf("%d B", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%.1f KiB", float64(n)/1024)
+	}
+	return fmt.Sprintf("%.1f MiB", float64(n)/(1024*1024))
+}
+
+// showStats prints the most recent builds in the history, and how the build times, the share of cached
+// compilations and the sizes of the outputs have changed
+func showStats(o *Options) error {
+	records := readHistory(o.BuildDir)
+	if len(records) == 0 {
+		fmt.Printf("No builds have been recorded in %s yet\n", o.BuildDir)
+		return nil
+	}
+	recent := records[max(len(records)-statsShown, 0):]
+	fmt.Printf("The last %d of %d recorded builds:\n", len(recent), len(records))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	prevSize := map[string]int64{}
+	for _, r := range records[:len(records)-len(recent)] {
+		if r.Success && r.Output != "" {
+			prevSize[r.sizeKey()] = r.Size
+		}
+	}
+	for _, r := range recent {
+		result := "ok"
+		if !r.Success {
+			result = "failed"
+		}
+		size, growth := "", ""
+		if r.Output != "" {
+			size = r.Output + " " + formatSize(r.Size)
+			if prev, ok := prevSize[r.sizeKey()]; ok && prev != r.Size {
+				growth = formatSize(r.Size - prev)
+				if r.Size > prev {
+					growth = "+" + growth
+				}
+			}
+			if r.Success {
+				prevSize[r.sizeKey()] = r.Size
+			}
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%6.2fs\t%d files\t%3.0f%% cached\t%s\t%s\n", r.Time.Local().Format("2006-01-02 15:04"),
+			r.Mode, result, r.Duration, r.Files, cachedPercent(r), size, growth)
+	}
+	w.Flush()
+	printTrends(records)
+	return nil
+}
+
+// cachedPercent returns how many percent of the sources of the given build were up to date
+func cachedPercent(r buildRecord) float64 {
+	if r.Files == 0 {
+		return 0
+	}
+	return 100 * float64(r.CacheHits) / float64(r.Files)
+}
+
+// printTrends compares the average duration and share of cached compilations of the most recent successful
+// builds with the ones before them, and shows how much each output has grown since it was first recorded
+func printTrends(records []buildRecord) {
+	var ok []buildRecord
+	for _, r := range records {
+		if r.Success {
+			ok = append(ok, r)
+		}
+	}
+	average := func(rs []buildRecord) (float64, float64) {
+		var d, c float64
+		for _, r := range rs {
+			d += r.Duration
+			c += cachedPercent(r)
+		}
+		return d / float64(len(rs)), c / float64(len(rs))
+	}
+	if len(ok) > 0 {
+		recent := ok[max(len(ok)-statsShown, 0):]
+		d, c := average(recent)
+		fmt.Printf("Average of the last %d successful builds: %.2fs, %.0f%% cached", len(recent), d, c)
+		if earlier := ok[:len(ok)-len(recent)]; len(earlier) > 0 {
+			ed, ec := average(earlier)
+			fmt.Printf(", compared to %.2fs and %.0f%% cached before", ed, ec)
+		}
+		fmt.Println()
+	}
+	first, last := map[string]buildRecord{}, map[string]buildRecord{}
+	var keys []string
+	for _, r := range ok {
+		if r.Output == "" {
+			continue
+		}
+		k := r.sizeKey()
+		if _, seen := first[k]; !seen {
+			first[k] = r
+			keys = append(keys, k)
+		}
+		last[k] = r
+	}
+	for _, k := range keys {
+		f, l := first[k], last[k]
+		if f.Size == 0 || f.Time.Equal(l.Time) {
+			continue
+		}
+		fmt.Printf("Size of %s, %s: %s -> %s (%+.1f%%) since %s\n", f.Output, f.Mode, formatSize(f.Size), formatSize(l.Size),
+			100*float64(l.Size-f.Size)/float64(f.Size), f.Time.Local().Format("2006-01-02"))
+	}
+}
@@ -0,0 +1,103 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// exporting checks if the options ask for a project file, the dependency graph or the configuration
+// to be written instead of a build
+func exporting(o *Options) bool {
+	return o.Pro || o.CompDB || o.Ninja || o.Makefile || o.CMake || o.Meson || o.Graph || o.Show
+}
+
+// buildMode returns a short name for the kind of build, for the hooks
+func buildMode(o *Options) string {
+	switch {
+	case o.Coverage:
+		return "coverage"
+	case o.PGO != "":
+		return "pgo-" + o.PGO
+	case o.Debug:
+		return "debug"
+	case o.Small:
+		return "small"
+	case o.Opt:
+		return "opt"
+	case o.Strict:
+		return "strict"
+	case o.Sloppy:
+		return "sloppy"
+	}
+	return "normal"
+}
+
+// hookCommands returns the commands for the given stage, "prebuild" or "postbuild".
+// A prebuild.sh or postbuild.sh script in the project directory is run first,
+// then the commands from the [hooks] table of the configuration file.
+func hookCommands(o *Options, stage string) []string {
+	var cmds []string
+	if fileExists(stage + ".sh") {
+		cmds = append(cmds, "sh ./"+stage+".sh")
+	}
+	if o.Config != nil {
+		switch stage {
+		case "prebuild":
+			cmds = append(cmds, o.Config.Prebuild...)
+		case "postbuild":
+			cmds = append(cmds, o.Config.Postbuild...)
+		}
+	}
+	return cmds
+}
+
+// runHooks runs the hook commands for the given stage with the shell, with the output name,
+// build mode, build directory, compiler and detected distro in CXX2_* environment variables.
+// It returns true if there were any hooks to run.
+func runHooks(o *Options, stage string) (bool, error) {
+	cmds := hookCommands(o, stage)
+	env := append(os.Environ(),
+		"CXX2_OUTPUT="+o.OutputName,
+		"CXX2_MODE="+buildMode(o),
+		"CXX2_BUILD_DIR="+o.BuildDir,
+		"CXX2_CXX="+o.CXX,
+		"CXX2_DISTRO="+o.DetectedDistro,
+	)
+	for _, c := range cmds {
+		fmt.Printf("Running %s hook: %s\n", stage, c)
+		if o.DryRun {
+			continue
+		}
+		cmd := exec.Command("sh", "-c", c)
+		cmd.Env = env
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return true, fmt.Errorf("%s hook %q failed: %w", stage, c, err)
+		}
+	}
+	return len(cmds) > 0, nil
+}
+
+// addGeneratedSources adds the sources that have appeared since the sources were discovered,
+// like those written by a prebuild hook, and returns the new non-test sources
+func addGeneratedSources(o *Options) []string {
+	srcs, err := discoverSources(o)
+	if err != nil {
+		return nil
+	}
+	var added []string
+	for _, s := range excludeSources(srcs, o.Exclude) {
+		if contains(o.Sources, s) {
+			continue
+		}
+		o.Sources = append(o.Sources, s)
+		if isTestSource(s) {
+			o.TestSources = append(o.TestSources, s)
+		} else {
+			added = append(added, s)
+		}
+	}
+	return added
+}
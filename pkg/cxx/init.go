@@ -0,0 +1,228 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// projectTemplate is a skeleton project that "cxx2 init" can create
+type projectTemplate struct {
+	// files maps from a file name to its contents, where NAME is replaced with the project name
+	files map[string]string
+	// headers are the library headers that the template includes, for suggesting packages to install
+	headers []string
+}
+
+const (
+	greetingHeader = `#pragma once
+
+#include <string>
+
+std::string greeting(const std::string& name);
+`
+	greetingSource = `#include "NAME.h"
+
+std::string greeting(const std::string& name) { return "Hello, " + name + "!"; }
+`
+	greetingTest = `#include "NAME.h"
+#include <cstdlib>
+#include <iostream>
+
+int main()
+{
+    if (greeting("World") != "Hello, World!") {
+        std::cerr << "greeting failed" << std::endl;
+        return EXIT_FAILURE;
+    }
+    std::cout << "OK" << std::endl;
+    return EXIT_SUCCESS;
+}
+`
+)
+
+var projectTemplates = map[string]projectTemplate{
+	"cli": {
+		files: map[string]string{
+			"include/NAME.h": greetingHeader,
+			"NAME.cpp":       greetingSource,
+			"NAME_test.cpp":  greetingTest,
+			"main.cpp": `#include "NAME.h"
+#include <iostream>
+
+int main(int argc, char* argv[])
+{
+    std::cout << greeting(argc > 1 ? argv[1] : "World") << std::endl;
+    return 0;
+}
+`,
+		},
+	},
+	"sdl2": {
+		files: map[string]string{
+			"include/NAME.h": greetingHeader,
+			"NAME.cpp":       greetingSource,
+			"NAME_test.cpp":  greetingTest,
+			"main.cpp": `#include "NAME.h"
+#include <SDL2/SDL.h>
+#include <iostream>
+
+int main(int argc, char* argv[])
+{
+    if (SDL_Init(SDL_INIT_VIDEO) != 0) {
+        std::cerr << "SDL_Init: " << SDL_GetError() << std::endl;
+        return 1;
+    }
+    SDL_Window* window = SDL_CreateWindow(greeting("SDL2").c_str(), SDL_WINDOWPOS_CENTERED,
+        SDL_WINDOWPOS_CENTERED, 640, 480, SDL_WINDOW_SHOWN);
+    if (window == nullptr) {
+        std::cerr << "SDL_CreateWindow: " << SDL_GetError() << std::endl;
+        SDL_Quit();
+        return 1;
+    }
+    SDL_Renderer* renderer = SDL_CreateRenderer(window, -1, SDL_RENDERER_ACCELERATED);
+    bool quit = false;
+    while (!quit) {
+        SDL_Event event;
+        while (SDL_PollEvent(&event)) {
+            if (event.type == SDL_QUIT || (event.type == SDL_KEYDOWN && event.key.keysym.sym == SDLK_ESCAPE)) {
+                quit = true;
+            }
+        }
+        SDL_SetRenderDrawColor(renderer, 32, 64, 128, 255);
+        SDL_RenderClear(renderer);
+        SDL_RenderPresent(renderer);
+        SDL_Delay(16);
+    }
+    SDL_DestroyRenderer(renderer);
+    SDL_DestroyWindow(window);
+    SDL_Quit();
+    return 0;
+}
+`,
+		},
+		headers: []string{"SDL2/SDL.h"},
+	},
+	"gtk3": {
+		files: map[string]string{
+			"include/NAME.h": greetingHeader,
+			"NAME.cpp":       greetingSource,
+			"NAME_test.cpp":  greetingTest,
+			"main.cpp": `#include "NAME.h"
+#include <gtk/gtk.h>
+
+static void activate(GtkApplication* app, gpointer)
+{
+    GtkWidget* window = gtk_application_window_new(app);
+    gtk_window_set_title(GTK_WINDOW(window), "NAME");
+    gtk_window_set_default_size(GTK_WINDOW(window), 320, 200);
+    GtkWidget* label = gtk_label_new(greeting("GTK").c_str());
+    gtk_container_add(GTK_CONTAINER(window), label);
+    gtk_widget_show_all(window);
+}
+
+int main(int argc, char* argv[])
+{
+    GtkApplication* app = gtk_application_new("org.example.NAME", G_APPLICATION_DEFAULT_FLAGS);
+    g_signal_connect(app, "activate", G_CALLBACK(activate), nullptr);
+    int status = g_application_run(G_APPLICATION(app), argc, argv);
+    g_object_unref(app);
+    return status;
+}
+`,
+		},
+		headers: []string{"gtk/gtk.h"},
+	},
+	"header-only": {
+		files: map[string]string{
+			"include/NAME/NAME.h": `#pragma once
+
+#include <string>
+
+inline std::string greeting(const std::string& name) { return "Hello, " + name + "!"; }
+`,
+			"NAME_test.cpp": strings.Replace(greetingTest, `"NAME.h"`, `"NAME/NAME.h"`, 1),
+		},
+	},
+}
+
+// templateNames returns the names of the project templates, sorted
+func templateNames() []string {
+	var names []string
+	for name := range projectTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// projectNameFromDir returns a name that can be used for files and identifiers, based on the current directory
+func projectNameFromDir() string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(filepath.Base(mustPwd())) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune('_')
+		}
+	}
+	if name := strings.Trim(sb.String(), "_"); name != "" {
+		return name
+	}
+	return "app"
+}
+
+// initProject creates a skeleton project in the current directory from the given template,
+// without overwriting existing files, and suggests packages to install for the libraries it uses
+func initProject(o *Options) error {
+	name := o.InitTemplate
+	if name == "" {
+		name = "cli"
+	}
+	tmpl, ok := projectTemplates[name]
+	if !ok {
+		return fmt.Errorf("unknown template %q, choose one of: %s", name, strings.Join(templateNames(), ", "))
+	}
+	project := projectNameFromDir()
+	files := map[string]string{}
+	for p, contents := range tmpl.files {
+		files[strings.ReplaceAll(p, "NAME", project)] = strings.ReplaceAll(contents, "NAME", project)
+	}
+	ignore := "/" + defaultBuildDir + "/\n"
+	if _, ok := tmpl.files["main.cpp"]; ok {
+		ignore += "/" + guessOutputNameFromMain("main.cpp", false) + "\n"
+	}
+	files[".gitignore"] = ignore
+	var paths []string
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		if _, err := os.Stat(p); err == nil {
+			fmt.Printf("Keeping existing %s\n", p)
+			continue
+		}
+		if err := makeDir(o, filepath.Dir(p)); err != nil {
+			return err
+		}
+		if err := writeFile(o, p, []byte(files[p])); err != nil {
+			return err
+		}
+		fmt.Printf("Created %s\n", p)
+	}
+	for _, h := range tmpl.headers {
+		pc := pkgConfigName(h)
+		if pc == "" || exec.Command("pkg-config", "--exists", pc).Run() == nil {
+			continue
+		}
+		if _, cmd := mapHeaderToPkg(h, o.DetectedDistro); cmd != "" {
+			fmt.Printf("%s was not found, possibly install with: %s\n", h, cmd)
+		}
+	}
+	fmt.Println("Build with \"cxx2\" and run the tests with \"cxx2 test\"")
+	return nil
+}
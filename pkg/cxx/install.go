@@ -0,0 +1,254 @@
+package cxx
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// installManifest lists every file that was installed, one per line, so that they can be uninstalled
+const installManifest = "install_manifest.txt"
+
+// installer copies build artifacts into DESTDIR + PREFIX and keeps track of what was installed
+type installer struct {
+	o         *Options
+	destDir   string
+	prefix    string
+	installed []string
+}
+
+// dir returns the full installation directory for the given subdirectory of the prefix, like "bin"
+func (in *installer) dir(sub string) string {
+	return filepath.Join(in.destDir, in.prefix, sub)
+}
+
+// copy installs the file src into the directory dstDir, with the given file mode
+func (in *installer) copy(src, dstDir string, mode fs.FileMode) error {
+	if err := makeDir(in.o, dstDir); err != nil {
+		return err
+	}
+	dst := filepath.Join(dstDir, filepath.Base(src))
+	fmt.Printf("Installing %s -> %s\n", src, dst)
+	if in.o.DryRun {
+		return nil
+	}
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	// Remove first, in case dst is a symlink or a running executable
+	os.Remove(dst)
+	w, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	in.installed = append(in.installed, dst)
+	return nil
+}
+
+// symlink creates the symlink dstDir/name pointing to target
+func (in *installer) symlink(target, dstDir, name string) error {
+	dst := filepath.Join(dstDir, name)
+	fmt.Printf("Installing %s -> %s\n", dst, target)
+	if in.o.DryRun {
+		return nil
+	}
+	os.Remove(dst)
+	if err := os.Symlink(target, dst); err != nil {
+		return err
+	}
+	in.installed = append(in.installed, dst)
+	return nil
+}
+
+// libraryHeaders returns the public headers of a library project, as a map from
+// the header file to its path relative to the include directory it will be installed in.
+// Headers are taken from include/ if it exists, or else from the top level directory.
+func libraryHeaders() map[string]string {
+	out := map[string]string{}
+	if dirExists("include") {
+		filepath.WalkDir("include", func(p string, d fs.DirEntry, e error) error {
+			if e == nil && !d.IsDir() && isHeader(p) {
+				rel, _ := filepath.Rel("include", p)
+				out[p] = rel
+			}
+			return nil
+		})
+		return out
+	}
+	entries, _ := os.ReadDir(".")
+	for _, d := range entries {
+		if !d.IsDir() && isHeader(d.Name()) {
+			out[d.Name()] = d.Name()
+		}
+	}
+	return out
+}
+
+func isHeader(p string) bool {
+	switch strings.ToLower(filepath.Ext(p)) {
+	case ".h", ".hh", ".hpp", ".hxx", ".h++", ".inl", ".cuh":
+		return true
+	}
+	return false
+}
+
+// sharedLibraryDir returns the directory of the prefix that the given shared library is installed into,
+// which is bin for a DLL, since Windows looks for them next to the executables, and lib otherwise
+func sharedLibraryDir(name string) string {
+	if strings.HasSuffix(name, ".dll") {
+		return "bin"
+	}
+	return "lib"
+}
+
+// installTargets installs the executables into PREFIX/bin, or a library into PREFIX/lib, or PREFIX/bin for a DLL,
+// together with its headers into PREFIX/include, its pkg-config file and its CMake package configuration,
+// all below DESTDIR.
+// The installed files are recorded in the install manifest.
+func installTargets(o *Options) error {
+	in := &installer{o: o, destDir: o.DestDir, prefix: o.Prefix}
+	switch {
+	case o.Shared:
+		libDir := in.dir(sharedLibraryDir(o.OutputName))
+		if err := in.copy(o.OutputName, libDir, 0o755); err != nil {
+			return err
+		}
+		target := o.OutputName
+		for _, l := range sharedLibraryLinks(o.OutputName, o.LibVersion) {
+			if err := in.symlink(target, libDir, l); err != nil {
+				return err
+			}
+			target = l
+		}
+	case o.Lib:
+		if err := in.copy(o.OutputName, in.dir("lib"), 0o644); err != nil {
+			return err
+		}
+	default:
+		exes := targetOutputs(o)
+		if o.OutputName != "" {
+			exes = append([]string{o.OutputName}, exes...)
+		}
+		if len(exes) == 0 {
+			return fmt.Errorf("nothing to install")
+		}
+		for _, exe := range exes {
+			if err := in.copy(exe, in.dir("bin"), 0o755); err != nil {
+				return err
+			}
+		}
+	}
+	if o.Lib {
+		for src, rel := range libraryHeaders() {
+			if err := in.copy(src, filepath.Dir(filepath.Join(in.dir("include"), rel)), 0o644); err != nil {
+				return err
+			}
+		}
+		if pc := pkgConfigFile(o); fileExists(pc) {
+			if err := in.copy(pc, in.dir(filepath.Join("lib", "pkgconfig")), 0o644); err != nil {
+				return err
+			}
+		}
+		for fn := range cmakeConfigFiles(o) {
+			if cf := filepath.Join(cmakeConfigDir(o), fn); fileExists(cf) {
+				if err := in.copy(cf, in.dir(filepath.Join("lib", "cmake", o.LibName)), 0o644); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return writeInstallManifest(o, in.installed)
+}
+
+func readInstallManifest() ([]string, error) {
+	f, err := os.Open(installManifest)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" {
+			out = append(out, line)
+		}
+	}
+	return out, sc.Err()
+}
+
+// writeInstallManifest adds the installed files to the manifest, keeping earlier entries
+func writeInstallManifest(o *Options, installed []string) error {
+	existing, _ := readInstallManifest()
+	var sb strings.Builder
+	for _, p := range existing {
+		if !contains(installed, p) {
+			sb.WriteString(p + "\n")
+		}
+	}
+	for _, p := range installed {
+		sb.WriteString(p + "\n")
+	}
+	return writeFile(o, installManifest, []byte(sb.String()))
+}
+
+// uninstallTargets removes every file listed in the install manifest, and then the
+// directories that were left empty, up to DESTDIR + PREFIX
+func uninstallTargets(o *Options) error {
+	files, err := readInstallManifest()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no %s found, nothing has been installed from here", installManifest)
+		}
+		return err
+	}
+	root := filepath.Clean(filepath.Join(o.DestDir, o.Prefix))
+	dirs := map[string]bool{}
+	for _, p := range files {
+		if _, err := os.Lstat(p); err != nil {
+			continue
+		}
+		fmt.Printf("Removing %s\n", p)
+		if o.DryRun {
+			continue
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		dirs[filepath.Dir(p)] = true
+	}
+	for d := range dirs {
+		removeEmptyDirs(d, root)
+	}
+	fmt.Printf("Removing %s\n", installManifest)
+	if o.DryRun {
+		return nil
+	}
+	return os.Remove(installManifest)
+}
+
+// removeEmptyDirs removes dir and its parents for as long as they are empty, stopping at root
+func removeEmptyDirs(dir, root string) {
+	for dir = filepath.Clean(dir); dir != root && strings.HasPrefix(dir, root+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		entries, err := os.ReadDir(dir)
+		if err != nil || len(entries) > 0 {
+			return
+		}
+		fmt.Printf("Removing %s\n", dir)
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
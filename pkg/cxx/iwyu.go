@@ -0,0 +1,106 @@
+package cxx
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// fixIncludes returns the script that applies the suggestions of include-what-you-use, or "" if it is not installed
+func fixIncludes() string {
+	for _, name := range []string{"fix_includes.py", "fix_include", "iwyu-fix-includes"} {
+		if haveCmd(name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// iwyuArgs returns the arguments for running include-what-you-use on the given source, with the standard,
+// include directories and defines that it is built with. The warning flags are left out, since they may be for g++.
+func iwyuArgs(o *Options, src string) []string {
+	var args []string
+	if o.Std != "" {
+		args = append(args, "-std="+o.Std)
+	}
+	args = append(args, strings.Fields(includeFlags(o))...)
+	args = append(args, o.ExtraCFlags...)
+	return append(args, "-c", src)
+}
+
+// runIWYU runs include-what-you-use on every source and prints which includes should be added and removed.
+// With --fix, the suggestions are applied with fix_includes.py.
+func runIWYU(o *Options) error {
+	if !haveCmd("include-what-you-use") {
+		return fmt.Errorf("include-what-you-use was not found, install it with: %s", installSuggestion(o.DetectedDistro, "include-what-you-use"))
+	}
+	fixer := ""
+	if o.Fix {
+		if fixer = fixIncludes(); fixer == "" {
+			return fmt.Errorf("fix_includes.py, that comes with include-what-you-use, was not found")
+		}
+	}
+	srcs := lintSources(o)
+	outputs := make([][]byte, len(srcs))
+	sem := make(chan struct{}, max(o.Jobs, 1))
+	var wg sync.WaitGroup
+	for i, s := range srcs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			// include-what-you-use exits with an error code also when it has suggestions
+			outputs[i], _ = exec.Command("include-what-you-use", iwyuArgs(o, s)...).CombinedOutput()
+		}()
+	}
+	wg.Wait()
+
+	var all bytes.Buffer
+	added, removed := 0, 0
+	section := ""
+	for i, out := range outputs {
+		fmt.Println("include-what-you-use", srcs[i])
+		os.Stdout.Write(out)
+		all.Write(out)
+		for _, line := range strings.Split(string(out), "\n") {
+			switch {
+			case strings.HasSuffix(line, "should add these lines:"):
+				section = "add"
+			case strings.HasSuffix(line, "should remove these lines:"):
+				section = "remove"
+			case strings.HasPrefix(line, "The full include-list for"), line == "":
+				section = ""
+			case section == "add" && strings.HasPrefix(line, "#include"):
+				added++
+			case section == "remove" && strings.HasPrefix(line, "- #include"):
+				removed++
+			}
+		}
+	}
+	if added == 0 && removed == 0 {
+		fmt.Printf("The includes are right in %d source(s)\n", len(srcs))
+		return nil
+	}
+	fmt.Printf("include-what-you-use suggests adding %d and removing %d include(s)\n", added, removed)
+	if !o.Fix {
+		fmt.Println("Apply the suggestions with: cxx2 iwyu --fix")
+		return nil
+	}
+	cmd := exec.Command(fixer, "--nosafe_headers")
+	cmd.Stdin = &all
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// fix_includes.py exits with the number of files that were changed
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// knownLaunchers are the compiler caches that are used automatically when found in PATH, in order of preference
+var knownLaunchers = []string{"ccache", "sccache"}
+
+// resolveLauncher returns the command that compiler invocations should be prefixed with, or "" for none.
+// An explicit --launcher= is used as given, "none" disables the launcher and "auto" (the default)
+// picks the first of the knownLaunchers that is installed.
+func resolveLauncher(o *Options) string {
+	switch o.Launcher {
+	case "none":
+		return ""
+	case "", "auto":
+		if o.Win64Docker {
+			// Not available inside the container
+			return ""
+		}
+		for _, l := range knownLaunchers {
+			if haveCmd(l) {
+				return l
+			}
+		}
+		return ""
+	}
+	return o.Launcher
+}
+
+// showCacheStats prints the hit and miss statistics of the compiler cache
+func showCacheStats(o *Options) error {
+	if o.Launcher == "" {
+		return fmt.Errorf("no compiler cache in use, install ccache or sccache or use --launcher=")
+	}
+	switch filepath.Base(o.Launcher) {
+	case "ccache", "sccache":
+	default:
+		return fmt.Errorf("statistics are not supported for %s", o.Launcher)
+	}
+	cmd := exec.Command(o.Launcher, "--show-stats")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
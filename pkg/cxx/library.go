@@ -0,0 +1,223 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// libraryExtensions are the extensions that are removed from a configured library name, like libfoo.a
+var libraryExtensions = []string{".a", ".so", ".dylib", ".lib", ".dll"}
+
+// libraryBaseName returns the name of the library, without the "lib" prefix and without an extension.
+// The name is taken from the configured output name, or from the current directory.
+// Dots in the name of the directory are kept, and so is "lib" at the start of a word, like in "library".
+func libraryBaseName(configured string) string {
+	b := configured
+	if b == "" {
+		dir, _ := os.Getwd()
+		b = filepath.Base(dir)
+		if b == "src" {
+			b = filepath.Base(filepath.Dir(dir))
+		}
+	}
+	if ext := filepath.Ext(b); configured != "" && contains(libraryExtensions, ext) {
+		// A file name, like libfoo.a
+		b = strings.TrimPrefix(strings.TrimSuffix(b, ext), "lib")
+	} else if rest, ok := strings.CutPrefix(b, "lib"); ok && rest != "" {
+		// Like lib-foo, lib_foo or libFoo
+		if c := rest[0]; c == '-' || c == '_' || c == '.' {
+			b = rest[1:]
+		} else if unicode.IsUpper(rune(c)) {
+			b = rest
+		}
+	}
+	if b == "" {
+		b = "main"
+	}
+	return b
+}
+
+func staticLibraryName(name string) string {
+	return "lib" + name + ".a"
+}
+
+// archiver returns the ar command that matches the selected compiler,
+// for instance x86_64-w64-mingw32-ar for x86_64-w64-mingw32-g++.
+// LTO objects need the gcc-ar or llvm-ar wrappers, that know how to index them.
+func archiver(o *Options) string {
+	if o.Wasm {
+		// Next to em++, which may be in $EMSDK instead of in PATH
+		return filepath.Join(filepath.Dir(o.CXX), "emar")
+	}
+	if o.Wasi || o.Android {
+		// wasi-sdk and the NDK come with llvm-ar
+		return filepath.Join(filepath.Dir(o.CXX), "llvm-ar")
+	}
+	if isZig(o) {
+		return compilerExecutable(o) + " ar"
+	}
+	if o.LTO {
+		if isClang(o) {
+			return "llvm-ar" + compilerVersionSuffix(o)
+		}
+		if i := strings.LastIndex(o.CXX, "g++"); i >= 0 {
+			return o.CXX[:i] + "gcc-ar" + o.CXX[i+len("g++"):]
+		}
+	}
+	if i := strings.LastIndex(o.CXX, "-"); i > 0 && strings.HasSuffix(o.CXX, "g++") {
+		return o.CXX[:i+1] + "ar"
+	}
+	return "ar"
+}
+
+// compileLibraryObjects compiles all non-test sources and returns the object files
+func compileLibraryObjects(o *Options, cc *CompileCache) ([]string, error) {
+	var srcs []string
+	for _, s := range o.Sources {
+		if !isTestSource(s) {
+			srcs = append(srcs, s)
+		}
+	}
+	if len(srcs) == 0 {
+		return nil, fmt.Errorf("no library sources found")
+	}
+	return compileAll(o, cc, srcs)
+}
+
+// buildStaticLibrary compiles all non-test sources and archives the objects into a static library
+func buildStaticLibrary(o *Options, cc *CompileCache) error {
+	objs, e := compileLibraryObjects(o, cc)
+	if e != nil {
+		return e
+	}
+	// Start from an empty archive, so that objects from removed sources are not kept around
+	removeFile(o, o.OutputName)
+	start := time.Now()
+	if e := runCommand(buildArchiveCmd(o, objs), o); e != nil {
+		return e
+	}
+	o.state.report.linked(o.OutputName, time.Since(start), false)
+	return nil
+}
+
+func buildArchiveCmd(o *Options, objs []string) string {
+	return archiveCmd(o, o.OutputName, objs)
+}
+
+// archiveCmd returns the command that creates the given static library from the given object files
+func archiveCmd(o *Options, out string, objs []string) string {
+	objs = linkOrder(o, objs)
+	if isMSVC(o) {
+		return msvcArchiveCmd(out, objs)
+	}
+	modifiers := "rcs"
+	if o.Reproducible && !onMacOS(o) {
+		// Zero timestamps, uids and gids, where ZERO_AR_DATE is used on macOS instead
+		modifiers += "D"
+	}
+	return fmt.Sprintf("%s %s %s %s", archiver(o), modifiers, out, strings.Join(objs, " "))
+}
+
+// sharedLibraryName returns the filename of the versioned shared library,
+// like libNAME.so.1.2.3, libNAME.1.2.3.dylib or libNAME.dll
+func sharedLibraryName(name, version string, docker bool) string {
+	switch {
+	case docker || runtime.GOOS == "windows":
+		return "lib" + name + ".dll"
+	case runtime.GOOS == "darwin":
+		return "lib" + name + "." + version + ".dylib"
+	}
+	return "lib" + name + ".so." + version
+}
+
+// sharedLibraryLinks returns the symlinks that should point to the versioned shared library,
+// the soname first and then the unversioned development name
+func sharedLibraryLinks(libName, version string) []string {
+	major, _, _ := strings.Cut(version, ".")
+	var links []string
+	switch {
+	case strings.HasSuffix(libName, ".so."+version):
+		base := strings.TrimSuffix(libName, "."+version)
+		links = []string{base + "." + major, base}
+	case strings.HasSuffix(libName, "."+version+".dylib"):
+		base := strings.TrimSuffix(libName, "."+version+".dylib")
+		links = []string{base + "." + major + ".dylib", base + ".dylib"}
+	}
+	var out []string
+	for _, l := range links {
+		if l != libName {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// sonameFlag returns the linker flag that records the soname / install name in the shared library
+func sonameFlag(libName, version string) string {
+	libName = filepath.Base(libName)
+	major, _, _ := strings.Cut(version, ".")
+	switch {
+	case strings.HasSuffix(libName, ".so."+version):
+		return "-Wl,-soname," + strings.TrimSuffix(libName, "."+version) + "." + major
+	case strings.HasSuffix(libName, "."+version+".dylib"):
+		return "-install_name @rpath/" + strings.TrimSuffix(libName, "."+version+".dylib") + "." + major + ".dylib"
+	case strings.HasSuffix(libName, ".so"):
+		// Unversioned, like on Android
+		return "-Wl,-soname," + filepath.Base(libName)
+	}
+	return ""
+}
+
+func buildSharedLinkCmd(o *Options, objs []string) string {
+	objs = linkOrder(o, objs)
+	if isMSVC(o) {
+		return msvcLinkCmd(o, objs, o.OutputName, true)
+	}
+	flags := driverFlags(o)
+	if !strings.Contains(flags, "-fPIC") {
+		flags += " -fPIC"
+	}
+	line := fmt.Sprintf("%s %s -shared %s %s -o %s",
+		linkDriver(o), flags, sonameFlag(o.OutputName, o.LibVersion), strings.Join(objs, " "), o.OutputName)
+	if linkFlags := joinExtraLDFlags(append(linkerFlags(o), o.ExtraLDFlags...)); linkFlags != "" {
+		line += " " + linkFlags
+	}
+	return line
+}
+
+// buildSharedLibrary compiles all non-test sources with -fPIC, links them into a versioned
+// shared library and creates the soname and development symlinks
+func buildSharedLibrary(o *Options, cc *CompileCache) error {
+	objs, e := compileLibraryObjects(o, cc)
+	if e != nil {
+		return e
+	}
+	start := time.Now()
+	if e := runCommand(buildSharedLinkCmd(o, objs), o); e != nil {
+		return e
+	}
+	o.state.report.linked(o.OutputName, time.Since(start), false)
+	return linkSharedLibraryNames(o)
+}
+
+// linkSharedLibraryNames creates the soname and development symlinks next to the shared library.
+// Each link points to the previous one: libNAME.so -> libNAME.so.1 -> libNAME.so.1.2.3
+func linkSharedLibraryNames(o *Options) error {
+	if o.DryRun {
+		return nil
+	}
+	target := o.OutputName
+	for _, l := range sharedLibraryLinks(o.OutputName, o.LibVersion) {
+		os.Remove(l)
+		if e := os.Symlink(filepath.Base(target), l); e != nil {
+			return e
+		}
+		target = l
+	}
+	return nil
+}
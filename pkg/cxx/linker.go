@@ -0,0 +1,81 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// knownLinkers are the linkers that are used automatically when found in PATH, in order of preference
+var knownLinkers = []string{"mold", "lld"}
+
+// linkerCommands returns the executables that the compiler driver looks for with -fuse-ld=name
+func linkerCommands(name string) []string {
+	return []string{"ld." + name, name}
+}
+
+// resolveLinker returns the linker to pass to -fuse-ld=, or "" for the default linker of the compiler.
+// "mold", "lld", "gold" and "bfd" can be selected with --linker=, "none" keeps the default linker
+// and "auto" (the default) picks the first of the knownLinkers that is installed.
+func resolveLinker(o *Options) (string, error) {
+	switch o.Linker {
+	case "none":
+		return "", nil
+	case "", "auto":
+		if o.Win64Docker {
+			// Not available inside the container
+			return "", nil
+		}
+		if o.Target != "" {
+			// The host linkers may not support the target
+			return "", nil
+		}
+		for _, l := range knownLinkers {
+			for _, c := range linkerCommands(l) {
+				if haveCmd(c) && linkerSupported(o, l) {
+					return l, nil
+				}
+			}
+		}
+		return "", nil
+	case "mold", "lld", "gold", "bfd":
+		return o.Linker, nil
+	}
+	return "", fmt.Errorf("unknown linker %q, use mold, lld, gold, bfd or none", o.Linker)
+}
+
+// linkerSupported checks that the compiler can link a small program with -fuse-ld= and the given linker,
+// since older compilers do not know about all of them, like GCC before 12.1 and mold
+func linkerSupported(o *Options, linker string) bool {
+	if isMSVC(o) {
+		return false
+	}
+	dir, err := os.MkdirTemp("", "cxx2-linker")
+	if err != nil {
+		return false
+	}
+	defer os.RemoveAll(dir)
+	cmd := compilerCommand(o, "-fuse-ld="+linker, "-x", "c++", "-", "-o", filepath.Join(dir, "a.out"))
+	cmd.Stdin = strings.NewReader("int main() { return 0; }\n")
+	return cmd.Run() == nil
+}
+
+// linkerFlags returns the flags that select the linker when linking executables and shared libraries,
+// that leave out what is not used in small mode and that leave out the timestamps for --reproducible
+func linkerFlags(o *Options) []string {
+	if isMSVC(o) {
+		return nil
+	}
+	var flags []string
+	if o.Linker != "" {
+		flags = append(flags, "-fuse-ld="+o.Linker)
+	}
+	if o.Small {
+		flags = append(flags, smallLinkFlags(o)...)
+	}
+	if o.Reproducible {
+		flags = append(flags, reproducibleLinkFlags(o)...)
+	}
+	return flags
+}
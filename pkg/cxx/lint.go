@@ -0,0 +1,142 @@
+package cxx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// lintFinding matches the warnings and errors in the output of clang-tidy
+var lintFinding = regexp.MustCompile(`^(.+?):\d+:\d+: (warning|error): .*\[([^\]]+)\]$`)
+
+// clangTidy returns the clang-tidy that matches the selected compiler, like clang-tidy-17 for clang++-17
+func clangTidy(o *Options) string {
+	if isClang(o) {
+		if v := compilerVersionSuffix(o); v != "" && haveCmd("clang-tidy"+v) {
+			return "clang-tidy" + v
+		}
+	}
+	return "clang-tidy"
+}
+
+// lintSources returns the sources that clang-tidy should check, leaving out assembly, resources
+// and the sources that are generated or come from outside of the project
+func lintSources(o *Options) []string {
+	var out []string
+	for _, s := range o.Sources {
+		if isAsmSource(s) || isResourceSource(s) || filepath.IsAbs(s) {
+			continue
+		}
+		if rel, err := filepath.Rel(o.BuildDir, s); err == nil && !strings.HasPrefix(rel, "..") {
+			continue
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// runLint runs clang-tidy on every source, with the same flags as for building, through a compile_commands.json
+// in the build directory. The checks are configured in .clang-tidy files, as usual. With --fix, the suggested
+// fixes are applied, one source at a time, so that fixes to the same header do not clash.
+// A summary of the findings per file is printed at the end.
+func runLint(o *Options) error {
+	tidy := clangTidy(o)
+	if !haveCmd(tidy) {
+		return fmt.Errorf("%s was not found, install it with: %s", tidy, installSuggestion(o.DetectedDistro, "clang-tidy"))
+	}
+	if err := prepareBuildDir(o); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(compilationDatabase(o), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(o.BuildDir, "compile_commands.json"), append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	if !fileExists(".clang-tidy") {
+		fmt.Println("No .clang-tidy file was found, using the default checks of", tidy)
+	}
+	args := []string{"-p", o.BuildDir, "--quiet",
+		// The flags may be for g++
+		"--extra-arg=-Wno-unknown-warning-option", "--extra-arg=-Wno-unused-command-line-argument"}
+	jobs := max(o.Jobs, 1)
+	if o.Fix {
+		args = append(args, "--fix")
+		jobs = 1
+	}
+	srcs := lintSources(o)
+	outputs := make([][]byte, len(srcs))
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i, s := range srcs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			// clang-tidy exits with an error when there are compiler errors, which are counted below
+			outputs[i], _ = exec.Command(tidy, append(args, s)...).CombinedOutput()
+		}()
+	}
+	wg.Wait()
+
+	counts := map[string]int{}
+	checks := map[string]int{}
+	total := 0
+	seen := map[string]bool{}
+	for i, out := range outputs {
+		fmt.Printf("%s %s\n", tidy, srcs[i])
+		os.Stdout.Write(out)
+		recordDiagnostics(o, "clang-tidy", string(out))
+		for _, line := range strings.Split(string(bytes.TrimSpace(out)), "\n") {
+			m := lintFinding.FindStringSubmatch(line)
+			// Findings in headers are reported once for every source that includes them
+			if m == nil || seen[line] {
+				continue
+			}
+			seen[line] = true
+			counts[m[1]]++
+			checks[m[3]]++
+			total++
+		}
+	}
+	if total == 0 {
+		fmt.Printf("No findings in %d source(s)\n", len(srcs))
+		return nil
+	}
+	fmt.Println("Findings per file:")
+	printCounts(counts)
+	fmt.Println("Findings per check:")
+	printCounts(checks)
+	if o.Fix {
+		fmt.Println("The fixes that clang-tidy could make have been applied")
+	}
+	return fmt.Errorf("%d finding(s) in %d file(s)", total, len(counts))
+}
+
+// printCounts prints the given counts, the highest first
+func printCounts(counts map[string]int) {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	for _, k := range keys {
+		fmt.Printf("  %5d  %s\n", counts[k], k)
+	}
+}
@@ -0,0 +1,160 @@
+package cxx
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// lockFilename is the lockfile with the exact versions of the git and fetched dependencies,
+// meant to be committed so that everyone builds with the same dependencies
+const lockFilename = "cxx2.lock"
+
+// lockedGit is the commit that a git dependency was locked to
+type lockedGit struct {
+	URL    string
+	Tag    string
+	Commit string
+}
+
+// lockedFetch is the checksum that a fetched header was locked to
+type lockedFetch struct {
+	URL    string
+	SHA256 string
+}
+
+// Lock is the contents of cxx2.lock
+type Lock struct {
+	Git     map[string]lockedGit
+	Fetch   map[string]lockedFetch
+	changed bool
+}
+
+// readLock reads cxx2.lock, or returns an empty Lock if there is none
+func readLock() (*Lock, error) {
+	l := &Lock{Git: map[string]lockedGit{}, Fetch: map[string]lockedFetch{}}
+	f, err := os.Open(lockFilename)
+	if os.IsNotExist(err) {
+		return l, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	tables, err := parseTables(f, lockFilename)
+	if err != nil {
+		return nil, err
+	}
+	for name, t := range tables {
+		if dep, ok := strings.CutPrefix(name, "git."); ok {
+			l.Git[dep] = lockedGit{configString(t, "url"), configString(t, "tag"), configString(t, "commit")}
+		} else if h, ok := strings.CutPrefix(name, "fetch."); ok {
+			l.Fetch[h] = lockedFetch{configString(t, "url"), configString(t, "sha256")}
+		}
+	}
+	return l, nil
+}
+
+// save writes cxx2.lock, if anything was locked or changed since it was read
+func (l *Lock) save(o *Options) error {
+	if !l.changed {
+		return nil
+	}
+	var sb strings.Builder
+	sb.WriteString("# Generated by cxx2, for reproducible dependencies. Commit this file.\n")
+	deps := make([]string, 0, len(l.Git))
+	for dep := range l.Git {
+		deps = append(deps, dep)
+	}
+	sort.Strings(deps)
+	for _, dep := range deps {
+		g := l.Git[dep]
+		fmt.Fprintf(&sb, "\n[git.%q]\nurl = %q\ntag = %q\ncommit = %q\n", dep, g.URL, g.Tag, g.Commit)
+	}
+	headers := make([]string, 0, len(l.Fetch))
+	for h := range l.Fetch {
+		headers = append(headers, h)
+	}
+	sort.Strings(headers)
+	for _, h := range headers {
+		f := l.Fetch[h]
+		fmt.Fprintf(&sb, "\n[fetch.%q]\nurl = %q\nsha256 = %q\n", h, f.URL, f.SHA256)
+	}
+	if err := writeFile(o, lockFilename, []byte(sb.String())); err != nil {
+		return err
+	}
+	l.changed = false
+	return nil
+}
+
+// lockGit records the commit of a git dependency
+func (l *Lock) lockGit(d GitDependency, commit string) {
+	if g := (lockedGit{d.URL, d.Tag, commit}); l.Git[d.Name] != g {
+		l.Git[d.Name] = g
+		l.changed = true
+	}
+}
+
+// lockedCommit returns the commit that the git dependency is locked to, or "" if it is not locked,
+// or if the URL or tag has been changed in the configuration since it was locked
+func (l *Lock) lockedCommit(d GitDependency) string {
+	if g, ok := l.Git[d.Name]; ok && g.URL == d.URL && g.Tag == d.Tag {
+		return g.Commit
+	}
+	return ""
+}
+
+// fileSHA256 returns the hex encoded SHA-256 sum of the given file
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// checkFetched verifies a fetched header against the lockfile, or locks it if it is not locked yet
+func (l *Lock) checkFetched(h, url string) error {
+	path := filepath.Join(thirdPartyDir, h)
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return err
+	}
+	if f, ok := l.Fetch[h]; ok && f.URL == url {
+		if f.SHA256 != sum {
+			return fmt.Errorf("checksum mismatch for %s: %s has %s, but %s was downloaded; remove the entry from %s to accept it",
+				path, lockFilename, f.SHA256, sum, lockFilename)
+		}
+		return nil
+	}
+	l.Fetch[h] = lockedFetch{url, sum}
+	l.changed = true
+	return nil
+}
+
+// verifyFetched checks that the fetched headers in third_party/ have the checksums in the lockfile
+func (l *Lock) verifyFetched() error {
+	for h, f := range l.Fetch {
+		path := filepath.Join(thirdPartyDir, h)
+		if !fileExists(path) {
+			continue
+		}
+		sum, err := fileSHA256(path)
+		if err != nil {
+			return err
+		}
+		if sum != f.SHA256 {
+			return fmt.Errorf("%s has been changed since it was fetched, the checksum in %s is %s, but it is %s", path, lockFilename, f.SHA256, sum)
+		}
+	}
+	return nil
+}
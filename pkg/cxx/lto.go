@@ -0,0 +1,41 @@
+package cxx
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ltoFlags returns the link-time optimization flags, for both compiling and linking.
+// Clang uses ThinLTO, GCC uses as many parallel jobs as there are cores.
+func ltoFlags(o *Options) []string {
+	if isClang(o) {
+		return []string{"-flto=thin"}
+	}
+	return []string{"-flto=auto"}
+}
+
+// ltoSupported checks that the compiler can build and link a small program with LTO,
+// and that the archiver wrapper is available when a static library is built
+func ltoSupported(o *Options) bool {
+	if isMSVC(o) {
+		// /GL and /LTCG are always available
+		return true
+	}
+	if o.Win64Docker {
+		// The MinGW container supports LTO, and the compiler is not available outside of it
+		return true
+	}
+	if o.Lib && !o.Shared && !haveCmd(archiver(o)) {
+		return false
+	}
+	dir, err := os.MkdirTemp("", "cxx2-lto")
+	if err != nil {
+		return false
+	}
+	defer os.RemoveAll(dir)
+	args := append(ltoFlags(o), "-x", "c++", "-", "-o", filepath.Join(dir, "a.out"))
+	cmd := compilerCommand(o, args...)
+	cmd.Stdin = strings.NewReader("int main() { return 0; }\n")
+	return cmd.Run() == nil
+}
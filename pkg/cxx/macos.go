@@ -0,0 +1,131 @@
+package cxx
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// homebrewPrefixes are where Homebrew is installed on Apple Silicon and on Intel Macs
+var homebrewPrefixes = []string{"/opt/homebrew", "/usr/local"}
+
+// macPortsPrefix is where MacPorts installs packages
+const macPortsPrefix = "/opt/local"
+
+var (
+	macSDKOnce sync.Once
+	macSDK     string
+)
+
+// onMacOS checks if the build is for macOS, which is not the case when building with Docker
+func onMacOS(o *Options) bool {
+	return runtime.GOOS == "darwin" && !o.Win64Docker && o.Target == "" && !o.Wasm
+}
+
+// macSDKPath returns the path to the macOS SDK, or "" if xcrun is not available
+func macSDKPath() string {
+	macSDKOnce.Do(func() {
+		out, err := exec.Command("xcrun", "--show-sdk-path").Output()
+		if err == nil {
+			macSDK = strings.TrimSpace(string(out))
+		}
+	})
+	return macSDK
+}
+
+// macPackagePrefixes returns the Homebrew and MacPorts prefixes that exist, starting with $HOMEBREW_PREFIX if it is set
+func macPackagePrefixes() []string {
+	var prefixes []string
+	candidates := append([]string{os.Getenv("HOMEBREW_PREFIX")}, homebrewPrefixes...)
+	for _, p := range append(candidates, macPortsPrefix) {
+		if p != "" && dirExists(p) && !contains(prefixes, p) {
+			prefixes = append(prefixes, p)
+		}
+	}
+	return prefixes
+}
+
+// setupMacOS adds the SDK headers and the include and library directories of Homebrew and MacPorts
+func setupMacOS(o *Options) {
+	if sdk := macSDKPath(); sdk != "" {
+		o.SystemIncludeDirs = append(o.SystemIncludeDirs, filepath.Join(sdk, "usr", "include"))
+	}
+	for _, p := range macPackagePrefixes() {
+		addPackagePrefix(o, p)
+	}
+}
+
+// frameworkDirs returns the directories that may contain macOS frameworks
+func frameworkDirs() []string {
+	var dirs []string
+	if sdk := macSDKPath(); sdk != "" {
+		dirs = append(dirs, filepath.Join(sdk, "System", "Library", "Frameworks"))
+	}
+	return append(dirs, "/System/Library/Frameworks", "/Library/Frameworks")
+}
+
+// frameworkForHeader returns the framework that provides an include like <Cocoa/Cocoa.h>, or "" if there is none
+func frameworkForHeader(h string, dirs []string) string {
+	name, rest, ok := strings.Cut(h, "/")
+	if !ok {
+		return ""
+	}
+	for _, d := range dirs {
+		if fileExists(filepath.Join(d, name+".framework", "Headers", rest)) {
+			return name
+		}
+	}
+	return ""
+}
+
+// kegOnlyPrefix returns the prefix of a Homebrew package that is installed without being linked
+// into the Homebrew prefix, like openssl@3, if it provides the given header
+func kegOnlyPrefix(h string) string {
+	pkg := configString(lookupHeader(h), "brew")
+	if pkg == "" {
+		return ""
+	}
+	for _, p := range macPackagePrefixes() {
+		keg := filepath.Join(p, "opt", pkg)
+		if fileExists(filepath.Join(keg, "include", h)) {
+			return keg
+		}
+	}
+	return ""
+}
+
+// macDependencies links with the frameworks and keg-only Homebrew packages that provide the included headers,
+// and returns the headers that are provided by them
+func macDependencies(o *Options, includes []string) map[string]bool {
+	provided := map[string]bool{}
+	dirs := frameworkDirs()
+	for _, h := range includes {
+		if fw := frameworkForHeader(h, dirs); fw != "" {
+			if !contains(o.ExtraLDFlags, fw) {
+				o.ExtraLDFlags = append(o.ExtraLDFlags, "-framework", fw)
+			}
+			provided[h] = true
+			continue
+		}
+		if keg := kegOnlyPrefix(h); keg != "" {
+			inc := "-I" + filepath.Join(keg, "include")
+			if !contains(o.ExtraCFlags, inc) {
+				o.ExtraCFlags = append(o.ExtraCFlags, inc)
+				o.ExtraLDFlags = append(o.ExtraLDFlags, "-L"+filepath.Join(keg, "lib"))
+			}
+			provided[h] = true
+		}
+	}
+	return provided
+}
+
+// macPortsInstead returns MacPorts as the package manager if Homebrew is not installed, but MacPorts is
+func macPortsInstead(pm packageManager) packageManager {
+	if pm.Command == "brew" && !haveCmd("brew") && haveCmd("port") {
+		return packageManager{"port", "sudo port install"}
+	}
+	return pm
+}
@@ -0,0 +1,156 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// makeEscape escapes dollar signs, so that flags are passed through make unchanged
+func makeEscape(s string) string {
+	return strings.ReplaceAll(s, "$", "$$")
+}
+
+// generateMakefile writes a plain Makefile with one rule per object file, using the
+// discovered include directories, compile flags and pkg-config flags, so that the project
+// can be built without cxx2. The flags from the [files."PATTERN"] tables are given to
+// the objects they are for as target-specific variables.
+func generateMakefile(o *Options) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Generated by cxx2 %s, regenerate with: cxx2 make\n\n", Version)
+	fmt.Fprintf(&sb, "CXX = %s\n", o.CXX)
+	fmt.Fprintf(&sb, "AR = %s\n", archiver(o))
+	if o.Std != "" {
+		fmt.Fprintf(&sb, "CXXSTD = -std=%s\n", o.Std)
+	}
+	fmt.Fprintf(&sb, "CXXFLAGS ?= %s\n", makeEscape(compileFlags(o)))
+	if hasSources(o.Sources, isCSource) {
+		fmt.Fprintf(&sb, "CC = %s\n", cCompiler(o))
+		if o.CStd != "" {
+			fmt.Fprintf(&sb, "CSTD = -std=%s\n", o.CStd)
+		}
+		fmt.Fprintf(&sb, "CFLAGS ?= %s\n", makeEscape(cCompileFlags(o)))
+	}
+	fmt.Fprintf(&sb, "INCLUDES = %s\n", includeFlags(o))
+	fmt.Fprintf(&sb, "PKG_CFLAGS = %s\n", makeEscape(joinExtraCFlags(o.ExtraCFlags)))
+	fmt.Fprintf(&sb, "LIBS = %s\n", makeEscape(joinExtraLDFlags(o.ExtraLDFlags)))
+	fmt.Fprintf(&sb, "BUILDDIR = %s\n", o.BuildDir)
+	fmt.Fprintf(&sb, "PREFIX ?= %s\n", o.Prefix)
+	fmt.Fprintf(&sb, "DESTDIR ?=\n\n")
+
+	steps := buildPlan(o)
+	var outputs, tests, deps []string
+	for _, step := range steps {
+		switch {
+		case step.Kind == "generate":
+		case step.Kind == "compile":
+			if writesDepfile(o, step.Inputs[0]) {
+				deps = append(deps, strings.TrimSuffix(step.Output, ".o")+".d")
+			}
+		case step.Test:
+			tests = append(tests, step.Output)
+		default:
+			outputs = append(outputs, step.Output)
+		}
+	}
+	fmt.Fprintf(&sb, "all: %s\n\n", strings.Join(outputs, " "))
+
+	// Only C sources are linked with the C compiler
+	driver, driverFlags := "$(CXX)", "$(CXXFLAGS)"
+	if isCProject(o) {
+		driver, driverFlags = "$(CC)", "$(CFLAGS)"
+	}
+
+	for _, step := range steps {
+		fmt.Fprintf(&sb, "%s: %s\n", step.Output, strings.Join(step.Inputs, " "))
+		switch step.Kind {
+		case "compile":
+			src := step.Inputs[0]
+			switch {
+			case !writesDepfile(o, src) || isCUDASource(src):
+				// nasm, windres, nvcc and cl.exe take flags of their own
+				fmt.Fprintf(&sb, "\t@mkdir -p $(@D)\n\t%s\n\n", makeEscape(step.Command))
+			case isCSource(src):
+				fmt.Fprintf(&sb, "\t@mkdir -p $(@D)\n\t$(CC) $(CSTD) $(filter-out $(FILE_REMOVE),$(CFLAGS) $(CPPFLAGS) $(INCLUDES) $(PKG_CFLAGS)) $(FILE_CFLAGS) -MMD -MP -c $< -o $@\n\n")
+			default:
+				fmt.Fprintf(&sb, "\t@mkdir -p $(@D)\n\t$(CXX) $(CXXSTD) $(filter-out $(FILE_REMOVE),$(CXXFLAGS) $(CPPFLAGS) $(INCLUDES) $(PKG_CFLAGS)) $(FILE_CFLAGS) -MMD -MP -c $< -o $@\n\n")
+			}
+			if add, remove := fileFlagOverride(o, src); len(add) > 0 || len(remove) > 0 {
+				// Separate variables, so that they are kept when CXXFLAGS or CFLAGS are given to make
+				if len(remove) > 0 {
+					fmt.Fprintf(&sb, "%s: FILE_REMOVE = %s\n", step.Output, makeEscape(strings.Join(remove, " ")))
+				}
+				if len(add) > 0 {
+					fmt.Fprintf(&sb, "%s: FILE_CFLAGS = %s\n", step.Output, makeEscape(strings.Join(add, " ")))
+				}
+				sb.WriteString("\n")
+			}
+		case "generate":
+			fmt.Fprintf(&sb, "\t@mkdir -p $(@D)\n\t%s\n\n", makeEscape(step.Command))
+		case "link":
+			libs := ""
+			if len(step.Libs) > 0 {
+				libs = makeEscape(strings.Join(step.Libs, " ")) + " "
+			}
+			fmt.Fprintf(&sb, "\t@mkdir -p $(@D)\n\t%s %s $^ %s-o $@ $(LDFLAGS) $(LIBS)\n\n", driver, driverFlags, libs)
+		case "shared":
+			fmt.Fprintf(&sb, "\t%s %s -shared %s $^ -o $@ $(LDFLAGS) $(LIBS)\n\n", driver, driverFlags, sonameFlag(o.OutputName, o.LibVersion))
+		case "archive":
+			fmt.Fprintf(&sb, "\trm -f $@\n\t$(AR) rcs $@ $^\n\n")
+		case "symlink":
+			fmt.Fprintf(&sb, "\tln -sf $< $@\n\n")
+		}
+	}
+
+	if len(tests) > 0 {
+		fmt.Fprintf(&sb, "test: %s\n\tfor t in $^; do ./$$t || exit 1; done\n\n", strings.Join(tests, " "))
+	}
+
+	fmt.Fprintf(&sb, "install: all\n")
+	for _, line := range makeInstallLines(o) {
+		fmt.Fprintf(&sb, "\t%s\n", line)
+	}
+	fmt.Fprintf(&sb, "\nclean:\n\trm -rf $(BUILDDIR) %s\n\n", strings.Join(outputs, " "))
+	fmt.Fprintf(&sb, ".PHONY: all test install clean\n\n")
+	fmt.Fprintf(&sb, "-include %s\n", strings.Join(deps, " "))
+	return os.WriteFile("Makefile", []byte(sb.String()), 0o644)
+}
+
+// makeInstallLines returns the install commands for the Makefile, mirroring installTargets
+func makeInstallLines(o *Options) []string {
+	var lines []string
+	switch {
+	case o.Shared:
+		dir := sharedLibraryDir(o.OutputName)
+		lines = append(lines, fmt.Sprintf("install -Dm755 %s $(DESTDIR)$(PREFIX)/%s/%s", o.OutputName, dir, o.OutputName))
+		target := o.OutputName
+		for _, l := range sharedLibraryLinks(o.OutputName, o.LibVersion) {
+			lines = append(lines, fmt.Sprintf("ln -sf %s $(DESTDIR)$(PREFIX)/%s/%s", target, dir, l))
+			target = l
+		}
+	case o.Lib:
+		lines = append(lines, fmt.Sprintf("install -Dm644 %s $(DESTDIR)$(PREFIX)/lib/%s", o.OutputName, o.OutputName))
+	default:
+		exes := targetOutputs(o)
+		if o.OutputName != "" {
+			exes = append([]string{o.OutputName}, exes...)
+		}
+		for _, exe := range exes {
+			lines = append(lines, fmt.Sprintf("install -Dm755 %s $(DESTDIR)$(PREFIX)/bin/%s", exe, filepath.Base(exe)))
+		}
+	}
+	if o.Lib {
+		headers := libraryHeaders()
+		var srcs []string
+		for src := range headers {
+			srcs = append(srcs, src)
+		}
+		sort.Strings(srcs)
+		for _, src := range srcs {
+			lines = append(lines, fmt.Sprintf("install -Dm644 %s $(DESTDIR)$(PREFIX)/include/%s", src, filepath.ToSlash(headers[src])))
+		}
+	}
+	return lines
+}
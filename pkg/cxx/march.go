@@ -0,0 +1,58 @@
+package cxx
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// msvcArchs maps from the x86-64 ISA levels to the closest /arch: flags of MSVC
+var msvcArchs = map[string]string{
+	"x86-64-v2": "/arch:SSE4.2",
+	"x86-64-v3": "/arch:AVX2",
+	"x86-64-v4": "/arch:AVX512",
+}
+
+// marchFlags returns the flags for the CPU that is built for with --march=, like native or x86-64-v3.
+// On ARM and POWER, -mcpu=native is what selects both the instructions and the tuning for this CPU.
+func marchFlags(o *Options) []string {
+	switch {
+	case o.March == "":
+		return nil
+	case isMSVC(o):
+		if flag, ok := msvcArchs[o.March]; ok {
+			return []string{flag}
+		}
+		return nil
+	case o.March != "native":
+		return []string{"-march=" + o.March}
+	}
+	switch targetArch(o) {
+	case "arm64", "arm", "ppc64", "ppc64le":
+		return []string{"-mcpu=native"}
+	}
+	return []string{"-march=native", "-mtune=native"}
+}
+
+// setupMarch checks --march= against the rest of the options. -march=native is left out when the build is
+// not for this machine, and a warning is printed when it is combined with --reproducible, since the output
+// then depends on the CPU of the machine that builds it.
+func setupMarch(o *Options) {
+	if o.March == "" {
+		return
+	}
+	if isMSVC(o) && len(marchFlags(o)) == 0 {
+		fmt.Printf("Warning: MSVC has no flag for --march=%s, it is left out\n", o.March)
+		return
+	}
+	if o.March != "native" {
+		return
+	}
+	if o.Wasm || o.Wasi || o.Universal || targetArch(o) != runtime.GOARCH {
+		fmt.Println("Warning: --march=native is left out, since it is for the CPU of this machine and not for the target")
+		o.March = ""
+		return
+	}
+	if o.Reproducible {
+		fmt.Println("Warning: --march=native makes the output depend on the CPU of this machine, so that it is only reproducible on the same CPU. An ISA level, like --march=x86-64-v3, can be used instead.")
+	}
+}
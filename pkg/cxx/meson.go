@@ -0,0 +1,127 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// mesonList formats the strings as a meson array of string literals
+func mesonList(items []string) string {
+	var quoted []string
+	for _, s := range items {
+		quoted = append(quoted, "'"+strings.ReplaceAll(filepath.ToSlash(s), "'", "\\'")+"'")
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// generateMesonBuild writes a meson.build with the targets, sources, include directories and
+// a dependency() for each included library that is known to the header to package mapping
+func generateMesonBuild(o *Options, includes []string) error {
+	name := projectName(o)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Generated by cxx2 %s, regenerate with: cxx2 meson\n\n", Version)
+	var defaultOptions []string
+	if o.Std != "" {
+		defaultOptions = append(defaultOptions, "cpp_std="+o.Std)
+	}
+	if o.CStd != "" && hasSources(o.Sources, isCSource) {
+		defaultOptions = append(defaultOptions, "c_std="+o.CStd)
+	}
+	languages := "'c', 'cpp'"
+	if hasCUDASources(o.Sources) {
+		languages += ", 'cuda'"
+	}
+	if hasSources(o.Sources, isNASMSource) {
+		languages += ", 'nasm'"
+	}
+	fmt.Fprintf(&sb, "project('%s', %s, version: '%s', default_options: %s)\n\n", name, languages, o.LibVersion, mesonList(defaultOptions))
+
+	depNames := map[string]bool{}
+	for _, inc := range includes {
+		if n := pkgConfigName(inc); n != "" {
+			depNames[n] = true
+		}
+	}
+	var deps []string
+	for n := range depNames {
+		deps = append(deps, fmt.Sprintf("dependency('%s')", n))
+	}
+	sort.Strings(deps)
+	fmt.Fprintf(&sb, "deps = [%s]\n", strings.Join(deps, ", "))
+
+	var incDirs []string
+	for _, d := range o.IncludeDirs {
+		if dirExists(d) {
+			incDirs = append(incDirs, d)
+		}
+	}
+	fmt.Fprintf(&sb, "inc = include_directories(%s)\n", strings.TrimSuffix(strings.TrimPrefix(mesonList(incDirs), "["), "]"))
+	args := strings.Fields(compileFlags(o))
+	for _, f := range o.ExtraCFlags {
+		if !strings.HasPrefix(f, "-I") {
+			args = append(args, f)
+		}
+	}
+	fmt.Fprintf(&sb, "cpp_args = %s\n", mesonList(args))
+	fmt.Fprintf(&sb, "link_args = %s\n\n", mesonList(o.ExtraLDFlags))
+	const common = "include_directories: inc, dependencies: deps, cpp_args: cpp_args, link_args: link_args"
+
+	var libSources, sharedSources []string
+	for _, s := range o.Sources {
+		if isTestSource(s) {
+			continue
+		}
+		libSources = append(libSources, s)
+		if s != o.MainSource && targetOf(o, s) == nil {
+			sharedSources = append(sharedSources, s)
+		}
+	}
+
+	// linkWith is what the executables and tests link with, in addition to their own sources
+	linkWith := ""
+	switch {
+	case o.Lib:
+		if o.Shared {
+			major, _, _ := strings.Cut(o.LibVersion, ".")
+			fmt.Fprintf(&sb, "lib = shared_library('%s', %s, version: '%s', soversion: '%s', %s, install: true)\n", name, mesonList(libSources), o.LibVersion, major, common)
+		} else {
+			fmt.Fprintf(&sb, "lib = static_library('%s', %s, %s, install: true)\n", name, mesonList(libSources), common)
+		}
+		if dirExists("include") {
+			fmt.Fprintf(&sb, "install_subdir('include', install_dir: get_option('includedir'), strip_directory: true)\n")
+		} else if headers := libraryHeaders(); len(headers) > 0 {
+			var hs []string
+			for h := range headers {
+				hs = append(hs, h)
+			}
+			sort.Strings(hs)
+			fmt.Fprintf(&sb, "install_headers(%s)\n", mesonList(hs))
+		}
+		linkWith = ", link_with: lib"
+	default:
+		if len(sharedSources) > 0 && (len(o.Targets) > 0 || len(o.TestSources) > 0) {
+			// Compile the sources without a main function once, for the executables and the tests
+			fmt.Fprintf(&sb, "common_lib = static_library('%s_common', %s, %s)\n", name, mesonList(sharedSources), common)
+			linkWith = ", link_with: common_lib"
+			sharedSources = nil
+		}
+		if o.MainSource != "" {
+			fmt.Fprintf(&sb, "executable('%s', %s, %s%s, install: true)\n", name, mesonList(append([]string{o.MainSource}, sharedSources...)), common, linkWith)
+		}
+		for _, t := range o.Targets {
+			fmt.Fprintf(&sb, "executable('%s', %s, %s%s, install: true)\n", t.Name, mesonList(t.Sources), common, linkWith)
+		}
+	}
+
+	if len(o.TestSources) > 0 {
+		fmt.Fprintln(&sb)
+		for _, s := range o.TestSources {
+			t := strings.TrimSuffix(filepath.Base(s), filepath.Ext(s))
+			fmt.Fprintf(&sb, "test('%s', executable('%s', %s, %s%s))\n", t, t, mesonList([]string{s}), common, linkWith)
+		}
+	}
+	return os.WriteFile("meson.build", []byte(sb.String()), 0o644)
+}
@@ -0,0 +1,104 @@
+package cxx
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// msys2Environments maps from the MSYS2 environments, as in $MSYSTEM, to the prefix of their package names
+var msys2Environments = map[string]string{
+	"ucrt64":     "mingw-w64-ucrt-x86_64-",
+	"mingw64":    "mingw-w64-x86_64-",
+	"clang64":    "mingw-w64-clang-x86_64-",
+	"clangarm64": "mingw-w64-clang-aarch64-",
+	"mingw32":    "mingw-w64-i686-",
+}
+
+// msys2DefaultRoot is where the MSYS2 installer places it, unless $MSYS2_ROOT says otherwise
+const msys2DefaultRoot = `C:\msys64`
+
+// onWindows checks if the build is a native Windows build with MinGW-w64, and not with MSVC, zig or for another platform
+func onWindows(o *Options) bool {
+	return runtime.GOOS == "windows" && !o.Win64Docker && o.Target == "" && !o.Wasm && !isMSVC(o) && !isZig(o)
+}
+
+// msys2PackagePrefix returns the prefix of the package names for the current MSYS2 environment
+func msys2PackagePrefix() string {
+	if prefix, ok := msys2Environments[strings.ToLower(os.Getenv("MSYSTEM"))]; ok {
+		return prefix
+	}
+	return msys2Environments["mingw64"]
+}
+
+// msys2Package returns the full name of the given MSYS2 package, like mingw-w64-x86_64-SDL2 for SDL2
+func msys2Package(pkg string) string {
+	return msys2PackagePrefix() + pkg
+}
+
+// isMinGWPrefix checks if the given directory is the prefix of an MSYS2 environment or of a MinGW-w64 toolchain
+func isMinGWPrefix(dir string) bool {
+	_, ok := msys2Environments[strings.ToLower(filepath.Base(dir))]
+	return ok || dirExists(filepath.Join(dir, "x86_64-w64-mingw32"))
+}
+
+// mingwPrefix returns the prefix of the MinGW-w64 compiler in PATH, like C:\msys64\ucrt64,
+// or else of the first MSYS2 environment that has the compiler, or "" if none is found
+func mingwPrefix(o *Options) string {
+	if p, err := exec.LookPath(o.CXX); err == nil {
+		if prefix := filepath.Dir(filepath.Dir(p)); isMinGWPrefix(prefix) {
+			return prefix
+		}
+		return ""
+	}
+	root := os.Getenv("MSYS2_ROOT")
+	if root == "" {
+		root = msys2DefaultRoot
+	}
+	for _, env := range []string{"ucrt64", "mingw64", "clang64"} {
+		if prefix := filepath.Join(root, env); fileExists(filepath.Join(prefix, "bin", o.CXX+".exe")) {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// setupMinGW uses the MinGW-w64 compiler of an MSYS2 installation when it is not in PATH,
+// and lets pkg-config find the libraries of the MinGW prefix
+func setupMinGW(o *Options) {
+	if !onWindows(o) {
+		return
+	}
+	prefix := mingwPrefix(o)
+	if prefix == "" {
+		return
+	}
+	bin := filepath.Join(prefix, "bin")
+	if !haveCmd(o.CXX) {
+		// The compiler needs the DLLs and tools in the same directory
+		os.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+		o.CXX = filepath.Join(bin, o.CXX+".exe")
+	}
+	pc := filepath.Join(prefix, "lib", "pkgconfig")
+	if old := os.Getenv("PKG_CONFIG_PATH"); old != "" {
+		pc += string(os.PathListSeparator) + old
+	}
+	os.Setenv("PKG_CONFIG_PATH", pc)
+}
+
+// mingwSystemIncludeDirs returns the include directories of the MinGW-w64 prefix
+func mingwSystemIncludeDirs(o *Options) []string {
+	prefix := mingwPrefix(o)
+	if prefix == "" {
+		return nil
+	}
+	var dirs []string
+	for _, d := range []string{filepath.Join(prefix, "include"), filepath.Join(prefix, "x86_64-w64-mingw32", "include")} {
+		if dirExists(d) {
+			dirs = append(dirs, d)
+		}
+	}
+	return dirs
+}
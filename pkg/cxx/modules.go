@@ -0,0 +1,305 @@
+package cxx
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ModuleUnit is what a source provides and needs of C++20 modules
+type ModuleUnit struct {
+	// Provides is the module or partition that the source is a unit of, like "shapes" or "shapes:circle"
+	Provides string
+	// Interface is true for module interface units, that start with "export module"
+	Interface bool
+	// Imports are the modules and partitions that the source imports
+	Imports []string
+	// StdLibrary is true for the std and std.compat modules, that are built from the standard library
+	StdLibrary bool
+}
+
+// isModuleInterfaceExt checks if the source has one of the extensions that are used for module interface units
+func isModuleInterfaceExt(s string) bool {
+	switch strings.ToLower(filepath.Ext(s)) {
+	case ".cppm", ".ixx", ".mpp":
+		return true
+	}
+	return false
+}
+
+// scanModuleUnit looks for the module declaration and the imports in the given source.
+// Header units, like import <vector>;, are left out, since they are not built from the sources.
+func scanModuleUnit(src string) (*ModuleUnit, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	u := &ModuleUnit{}
+	module := ""
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	inComment := false
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if inComment {
+			_, after, found := strings.Cut(line, "*/")
+			if !found {
+				continue
+			}
+			inComment = false
+			line = strings.TrimSpace(after)
+		}
+		if strings.HasPrefix(line, "/*") {
+			if _, after, found := strings.Cut(line[2:], "*/"); found {
+				line = strings.TrimSpace(after)
+			} else {
+				inComment = true
+				continue
+			}
+		}
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		exported := strings.HasPrefix(line, "export ")
+		decl := strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		name, ok := strings.CutSuffix(decl, ";")
+		if !ok {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(name, "module "):
+			name = strings.TrimSpace(strings.TrimPrefix(name, "module "))
+			if strings.HasPrefix(name, ":") {
+				// module :private;
+				continue
+			}
+			u.Provides = name
+			u.Interface = exported
+			module, _, _ = strings.Cut(name, ":")
+			if !exported && !strings.Contains(name, ":") {
+				// Implementation units import their module implicitly
+				u.Imports = append(u.Imports, name)
+			}
+		case strings.HasPrefix(name, "import "):
+			name = strings.TrimSpace(strings.TrimPrefix(name, "import "))
+			switch {
+			case strings.HasPrefix(name, "<") || strings.HasPrefix(name, `"`):
+			case strings.HasPrefix(name, ":"):
+				u.Imports = append(u.Imports, module+name)
+			default:
+				u.Imports = append(u.Imports, name)
+			}
+		}
+	}
+	if u.Provides == "" && len(u.Imports) == 0 {
+		return nil, sc.Err()
+	}
+	return u, sc.Err()
+}
+
+// setupModules scans the sources for C++20 module declarations and imports. If any source is
+// a module unit, the sources are compiled in the order of the imports and the module flags of
+// the compiler are added.
+func setupModules(o *Options) error {
+	units := map[string]*ModuleUnit{}
+	for _, s := range o.Sources {
+		if isCUDASource(s) || isAsmSource(s) || isResourceSource(s) {
+			continue
+		}
+		u, err := scanModuleUnit(s)
+		if err != nil {
+			return err
+		}
+		if u != nil {
+			units[s] = u
+		}
+	}
+	if o.ImportStd {
+		if isZig(o) || o.Wasm || o.Wasi || o.Win64Docker {
+			return fmt.Errorf("import_std is only supported with g++, clang++ and msvc, when building for the host")
+		}
+		if err := setupImportStd(o, units); err != nil {
+			return err
+		}
+	}
+	provided := false
+	for _, u := range units {
+		provided = provided || u.Provides != ""
+	}
+	if !provided && len(o.HeaderUnits) == 0 {
+		return nil
+	}
+	if isZig(o) || o.Wasm || o.Wasi {
+		return fmt.Errorf("C++20 modules are only supported with g++, clang++ and msvc")
+	}
+	o.Modules = units
+	if _, err := moduleLevels(o, o.Sources); err != nil {
+		return err
+	}
+	if err := makeDir(o, moduleDir(o)); err != nil {
+		return err
+	}
+	if !isClang(o) && !isMSVC(o) {
+		return writeModuleMapper(o)
+	}
+	return nil
+}
+
+// moduleDir returns where the compiled module interfaces are placed
+func moduleDir(o *Options) string {
+	return filepath.Join(o.BuildDir, "modules")
+}
+
+// moduleInterfacePath returns the compiled module interface for the given module or partition, named the
+// way that the compiler looks for it: shapes-circle.pcm for shapes:circle with clang, shapes-circle.ifc with msvc
+// and shapes:circle.gcm with g++, where the mapper file decides the name.
+func moduleInterfacePath(o *Options, name string) string {
+	switch {
+	case isClang(o):
+		return filepath.Join(moduleDir(o), strings.ReplaceAll(name, ":", "-")+".pcm")
+	case isMSVC(o):
+		return filepath.Join(moduleDir(o), strings.ReplaceAll(name, ":", "-")+".ifc")
+	}
+	return filepath.Join(moduleDir(o), strings.ReplaceAll(name, ":", "-")+".gcm")
+}
+
+// moduleMapperPath returns the module mapper file for g++, that maps from module names to .gcm files
+func moduleMapperPath(o *Options) string {
+	return filepath.Join(moduleDir(o), "mapper.txt")
+}
+
+// writeModuleMapper writes the module mapper file for g++, so that the .gcm files are placed
+// in the build directory instead of in gcm.cache. Header units are named by the path of the header.
+func writeModuleMapper(o *Options) error {
+	var lines []string
+	for _, u := range o.Modules {
+		if u.Interface || strings.Contains(u.Provides, ":") {
+			lines = append(lines, u.Provides+" "+moduleInterfacePath(o, u.Provides))
+		}
+	}
+	for h, p := range o.HeaderUnits {
+		lines = append(lines, p+" "+headerUnitPath(o, h))
+	}
+	sort.Strings(lines)
+	return writeFile(o, moduleMapperPath(o), []byte(strings.Join(lines, "\n")+"\n"))
+}
+
+// moduleProviders returns the sources that provide the modules and partitions that the given source imports
+func moduleProviders(o *Options, src string) []string {
+	u := o.Modules[src]
+	if u == nil {
+		return nil
+	}
+	var out []string
+	for _, imp := range u.Imports {
+		for s, p := range o.Modules {
+			if s != src && p.Provides == imp && (p.Interface || strings.Contains(imp, ":")) {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+// moduleLevels orders the given sources into levels, so that every source comes after the sources that
+// provide what it imports. The sources within a level can be compiled in parallel.
+func moduleLevels(o *Options, srcs []string) ([][]string, error) {
+	if len(o.Modules) == 0 {
+		return [][]string{srcs}, nil
+	}
+	level := map[string]int{}
+	visiting := map[string]bool{}
+	var visit func(s string) (int, error)
+	visit = func(s string) (int, error) {
+		if l, ok := level[s]; ok {
+			return l, nil
+		}
+		if visiting[s] {
+			return 0, fmt.Errorf("the modules that %s imports, import it in turn", s)
+		}
+		visiting[s] = true
+		l := 0
+		for _, p := range moduleProviders(o, s) {
+			pl, err := visit(p)
+			if err != nil {
+				return 0, err
+			}
+			l = max(l, pl+1)
+		}
+		visiting[s] = false
+		level[s] = l
+		return l, nil
+	}
+	var levels [][]string
+	for _, s := range srcs {
+		l, err := visit(s)
+		if err != nil {
+			return nil, err
+		}
+		for len(levels) <= l {
+			levels = append(levels, nil)
+		}
+		levels[l] = append(levels[l], s)
+	}
+	// Providers that are not among the given sources leave gaps
+	var out [][]string
+	for _, l := range levels {
+		if len(l) > 0 {
+			out = append(out, l)
+		}
+	}
+	return out, nil
+}
+
+// moduleInterfaceChanged checks if any of the module interfaces that the given source imports
+// are newer than its object file, so that it has to be compiled again
+func moduleInterfaceChanged(o *Options, src, obj string) bool {
+	for _, p := range moduleProviders(o, src) {
+		if !stampIsNewer(obj, moduleInterfacePath(o, o.Modules[p].Provides)) {
+			return true
+		}
+	}
+	return false
+}
+
+// moduleFlags returns the flags for compiling the given source in a project with modules
+func moduleFlags(o *Options, src string) string {
+	if len(o.Modules) == 0 && len(o.HeaderUnits) == 0 {
+		return ""
+	}
+	u := o.Modules[src]
+	provides := u != nil && (u.Interface || strings.Contains(u.Provides, ":"))
+	var flags []string
+	switch {
+	case isClang(o):
+		flags = append(flags, "-fprebuilt-module-path="+moduleDir(o))
+		if provides {
+			flags = append(flags, "-fmodule-output="+moduleInterfacePath(o, u.Provides), "-x", "c++-module")
+			if u.StdLibrary {
+				flags = append(flags, "-Wno-reserved-module-identifier")
+			}
+		} else if isModuleInterfaceExt(src) {
+			flags = append(flags, "-x", "c++")
+		}
+	case isMSVC(o):
+		flags = append(flags, "/ifcSearchDir", moduleDir(o))
+		if provides {
+			flags = append(flags, "/ifcOutput", moduleInterfacePath(o, u.Provides))
+			if u.Interface {
+				flags = append(flags, "/interface")
+			} else {
+				flags = append(flags, "/internalPartition")
+			}
+		}
+	default:
+		flags = append(flags, "-fmodules-ts", "-fmodule-mapper="+moduleMapperPath(o))
+		if isModuleInterfaceExt(src) {
+			flags = append(flags, "-x", "c++")
+		}
+	}
+	return strings.Join(flags, " ")
+}
@@ -0,0 +1,96 @@
+package cxx
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// mpiCompiler returns the MPI compiler wrapper, or "" if MPI is not installed
+func mpiCompiler() string {
+	for _, name := range []string{"mpicxx", "mpic++"} {
+		if haveCmd(name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// mpiWrapperFlags returns the flags that the MPI compiler wrapper adds for compiling and for linking.
+// Open MPI prints them with -showme:compile and -showme:link, while MPICH prints the whole
+// command, including the compiler, with -compile_info and -link_info.
+func mpiWrapperFlags(mpicxx string) ([]string, []string, error) {
+	compile, err := exec.Command(mpicxx, "-showme:compile").Output()
+	if err == nil {
+		link, err := exec.Command(mpicxx, "-showme:link").Output()
+		if err != nil {
+			return nil, nil, err
+		}
+		return strings.Fields(string(compile)), strings.Fields(string(link)), nil
+	}
+	compile, err = exec.Command(mpicxx, "-compile_info").Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s does not tell which flags it uses", mpicxx)
+	}
+	link, err := exec.Command(mpicxx, "-link_info").Output()
+	if err != nil {
+		return nil, nil, err
+	}
+	withoutCompiler := func(out []byte) []string {
+		var flags []string
+		for i, f := range strings.Fields(string(out)) {
+			if i > 0 && f != "-c" {
+				flags = append(flags, f)
+			}
+		}
+		return flags
+	}
+	return withoutCompiler(compile), withoutCompiler(link), nil
+}
+
+// setupMPI adds the flags of the MPI compiler wrapper with "mpi", or when a source includes <mpi.h>,
+// so that MPI programs are built with the configured compiler, just like mpicxx would build them
+func setupMPI(o *Options) error {
+	if !o.MPI && !contains(gatherAllIncludes(o, o.Sources), "mpi.h") {
+		return nil
+	}
+	cross := o.Win64Docker || o.Target != "" || o.Wasm
+	mpicxx := mpiCompiler()
+	if !o.MPI && (cross || mpicxx == "") {
+		// The missing header is reported, together with the package that provides it
+		return nil
+	}
+	if cross {
+		return fmt.Errorf("mpi can not be combined with cross compilation")
+	}
+	if mpicxx == "" {
+		return fmt.Errorf("mpicxx was not found, install MPI with: %s", installSuggestion(o.DetectedDistro, "mpicxx"))
+	}
+	compile, link, err := mpiWrapperFlags(mpicxx)
+	if err != nil {
+		return err
+	}
+	for _, f := range compile {
+		if dir, ok := strings.CutPrefix(f, "-I"); ok && !contains(o.SystemIncludeDirs, dir) {
+			o.SystemIncludeDirs = append(o.SystemIncludeDirs, dir)
+		}
+		if !contains(o.ExtraCFlags, f) {
+			o.ExtraCFlags = append(o.ExtraCFlags, f)
+		}
+	}
+	for _, f := range link {
+		if !strings.HasPrefix(f, "-I") && !contains(o.ExtraLDFlags, f) {
+			o.ExtraLDFlags = append(o.ExtraLDFlags, f)
+		}
+	}
+	return nil
+}
+
+// mpiCommand wraps the command in mpirun, for running it as the given number of processes
+func mpiCommand(cmd *exec.Cmd, procs int) (*exec.Cmd, error) {
+	if !haveCmd("mpirun") {
+		return nil, fmt.Errorf("mpirun is needed for running %d processes", procs)
+	}
+	return exec.Command("mpirun", append([]string{"-np", strconv.Itoa(procs)}, cmd.Args...)...), nil
+}
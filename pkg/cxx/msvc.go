@@ -0,0 +1,230 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// isMSVC checks if the compiler is cl.exe from Visual Studio, which takes MSVC style flags
+func isMSVC(o *Options) bool {
+	b := strings.ToLower(filepath.Base(o.CXX))
+	return b == "cl" || b == "cl.exe"
+}
+
+// vswherePath returns where the Visual Studio Installer places vswhere.exe
+func vswherePath() string {
+	return filepath.Join(os.Getenv("ProgramFiles(x86)"), "Microsoft Visual Studio", "Installer", "vswhere.exe")
+}
+
+// importVCVars finds the latest Visual Studio with the C++ tools with vswhere, runs its vcvars64.bat
+// and imports the environment, just like a Developer Command Prompt would do
+func importVCVars() error {
+	vswhere := vswherePath()
+	if !fileExists(vswhere) {
+		return fmt.Errorf("vswhere.exe was not found, install Visual Studio with the C++ tools or use a Developer Command Prompt")
+	}
+	out, err := exec.Command(vswhere, "-latest", "-products", "*", "-requires",
+		"Microsoft.VisualStudio.Component.VC.Tools.x86.x64", "-property", "installationPath").Output()
+	if err != nil {
+		return err
+	}
+	install := strings.TrimSpace(string(out))
+	if install == "" {
+		return fmt.Errorf("no Visual Studio installation with the C++ tools was found")
+	}
+	vcvars := filepath.Join(install, "VC", "Auxiliary", "Build", "vcvars64.bat")
+	fmt.Println("Using", vcvars)
+	out, err = exec.Command("cmd", "/c", "call", vcvars, ">nul", "&&", "set").Output()
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", vcvars, err)
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(line), "="); ok && k != "" {
+			os.Setenv(k, v)
+		}
+	}
+	return nil
+}
+
+// setupMSVC switches to cl.exe with "msvc", or on Windows when the Visual Studio environment is set up
+// and the configured compiler is not available. Outside of a Developer Command Prompt, the environment
+// of the latest Visual Studio installation is imported.
+func setupMSVC(o *Options) error {
+	if !o.MSVC && !isMSVC(o) {
+		if runtime.GOOS != "windows" || os.Getenv("VCINSTALLDIR") == "" || haveCmd(compilerExecutable(o)) {
+			return nil
+		}
+	}
+	if o.Win64Docker || o.Target != "" || o.Wasm {
+		return fmt.Errorf("msvc can not be combined with cross compilation")
+	}
+	if o.Coverage || o.PGO != "" {
+		return fmt.Errorf("coverage and PGO are not supported with msvc")
+	}
+	if !haveCmd("cl") {
+		if runtime.GOOS != "windows" {
+			return fmt.Errorf("cl.exe is only available on Windows")
+		}
+		if err := importVCVars(); err != nil {
+			return err
+		}
+		if !haveCmd("cl") {
+			return fmt.Errorf("cl.exe was not found after setting up the Visual Studio environment")
+		}
+	}
+	o.CXX = "cl"
+	// link.exe is used directly
+	o.Linker = ""
+	return nil
+}
+
+// msvcSystemIncludeDirs returns the include directories in %INCLUDE%, as set up by vcvars
+func msvcSystemIncludeDirs() []string {
+	var dirs []string
+	for _, d := range filepath.SplitList(os.Getenv("INCLUDE")) {
+		if d != "" {
+			dirs = append(dirs, d)
+		}
+	}
+	return dirs
+}
+
+// msvcStd returns the /std: flag for the given standard, like /std:c++20 for c++20 or gnu++20.
+// Newer standards than cl has a flag for use /std:c++latest.
+func msvcStd(std string) string {
+	if std == "" {
+		return ""
+	}
+	switch v := strings.TrimPrefix(strings.TrimPrefix(std, "gnu++"), "c++"); v {
+	case "14", "17", "20":
+		return "/std:c++" + v
+	}
+	return "/std:c++latest"
+}
+
+// msvcCStd converts a -std= value like c11 or gnu17 to the /std: flag for C, or "" for the older standards,
+// which cl.exe has no flag for
+func msvcCStd(std string) string {
+	switch v := strings.TrimPrefix(strings.TrimPrefix(std, "gnu"), "c"); v {
+	case "11", "17":
+		return "/std:c" + v
+	case "18":
+		return "/std:c17"
+	case "89", "90", "99", "":
+		return ""
+	}
+	return "/std:clatest"
+}
+
+// msvcCompileFlags returns the cl.exe flags that correspond to the flags from compileFlags
+func msvcCompileFlags(o *Options) string {
+	flags := []string{"/nologo", "/EHsc", "/permissive-", "/Zc:__cplusplus", "/utf-8"}
+	switch {
+	case o.Sloppy:
+		flags = append(flags, "/w")
+	case o.Strict:
+		flags = append(flags, "/W4")
+	default:
+		flags = append(flags, "/W3")
+	}
+	flags = applyWarningProfile(o, flags)
+	if o.Debug {
+		flags = append(flags, "/Od", "/Zi", "/MDd", "/RTC1")
+	} else {
+		flags = append(flags, "/MD")
+		if o.SplitDebug {
+			flags = append(flags, "/Zi")
+		}
+		if o.Reproducible {
+			flags = append(flags, reproducibleFlags(o)...)
+		}
+		if o.Small {
+			flags = append(flags, smallFlags(o)...)
+		} else if o.Opt {
+			flags = append(flags, "/O2")
+		}
+	}
+	flags = append(flags, marchFlags(o)...)
+	if o.LTO {
+		flags = append(flags, "/GL")
+	}
+	return strings.Join(flags, " ")
+}
+
+// msvcCompileCmd returns the cl.exe command for compiling the given source into the given object file
+func msvcCompileCmd(o *Options, src, obj string) string {
+	cxx := o.CXX
+	if o.Launcher != "" {
+		cxx = o.Launcher + " " + cxx
+	}
+	if isCSource(src) {
+		// cl.exe compiles .c files as C
+		flags, cf := fileFlags(o, src, msvcCompileFlags(o), cExtraFlags(o))
+		return joinNonEmpty([]string{cxx, msvcCStd(o.CStd), flags, includeFlags(o), cf, "/c", src, "/Fo" + obj})
+	}
+	flags, cf := fileFlags(o, src, msvcCompileFlags(o), joinExtraCFlags(o.ExtraCFlags))
+	parts := []string{cxx, msvcStd(o.Std), flags, includeFlags(o), cf, moduleFlags(o, src), "/c", src, "/Fo" + obj}
+	return joinNonEmpty(parts)
+}
+
+// msvcLinkFlags translates the GCC style linker flags, like -lfoo and -L/path, to link.exe flags.
+// Flags that link.exe has no equivalent for are left out.
+func msvcLinkFlags(o *Options) []string {
+	var out []string
+	if o.Debug || o.SplitDebug {
+		out = append(out, "/DEBUG")
+	}
+	if o.LTO {
+		out = append(out, "/LTCG")
+	}
+	if o.Small {
+		out = append(out, smallLinkFlags(o)...)
+	}
+	if o.Reproducible {
+		out = append(out, reproducibleLinkFlags(o)...)
+	}
+	for _, f := range o.ExtraLDFlags {
+		switch {
+		case strings.HasPrefix(f, "-l"):
+			out = append(out, strings.TrimPrefix(f, "-l")+".lib")
+		case strings.HasPrefix(f, "-L"):
+			out = append(out, "/LIBPATH:"+strings.TrimPrefix(f, "-L"))
+		case strings.HasPrefix(f, "-"):
+			// GCC only, like -pthread and -Wl,
+		default:
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// msvcLinkCmd returns the link.exe command for linking an executable, or a DLL if dll is true
+func msvcLinkCmd(o *Options, objs []string, out string, dll bool) string {
+	parts := []string{"link", "/nologo"}
+	if dll {
+		parts = append(parts, "/DLL")
+	}
+	parts = append(parts, "/OUT:"+out)
+	parts = append(parts, objs...)
+	return joinNonEmpty(append(parts, msvcLinkFlags(o)...))
+}
+
+// msvcArchiveCmd returns the lib.exe command for creating a static library
+func msvcArchiveCmd(out string, objs []string) string {
+	return fmt.Sprintf("lib /nologo /OUT:%s %s", out, strings.Join(objs, " "))
+}
+
+// joinNonEmpty joins the non-empty parts with spaces
+func joinNonEmpty(parts []string) string {
+	var out []string
+	for _, p := range parts {
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, " ")
+}
@@ -0,0 +1,63 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ninjaPath escapes a path for use in a ninja build statement
+func ninjaPath(p string) string {
+	return strings.NewReplacer("$", "$$", " ", "$ ", ":", "$:").Replace(p)
+}
+
+func ninjaPaths(ps []string) string {
+	var out []string
+	for _, p := range ps {
+		out = append(out, ninjaPath(p))
+	}
+	return strings.Join(out, " ")
+}
+
+// writesDepfile checks if the compile command for the given source can write a depfile with -MMD -MF,
+// which nasm, windres and cl.exe can not
+func writesDepfile(o *Options, src string) bool {
+	return !isNASMSource(src) && !isResourceSource(src) && !isMSVC(o)
+}
+
+// generateNinjaFile writes a build.ninja with every compile and link step of the build,
+// letting ninja track header dependencies through the depfiles written by the compiler
+func generateNinjaFile(o *Options) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Generated by cxx2 %s, regenerate with: cxx2 ninja\n\n", Version)
+	fmt.Fprintf(&sb, "ninja_required_version = 1.3\n")
+	fmt.Fprintf(&sb, "builddir = %s\n\n", ninjaPath(o.BuildDir))
+	fmt.Fprintf(&sb, "rule compile\n  command = $cmd -MMD -MF $out.d\n  depfile = $out.d\n  deps = gcc\n  description = CXX $out\n\n")
+	fmt.Fprintf(&sb, "rule compile_nodeps\n  command = $cmd\n  description = CXX $out\n\n")
+	fmt.Fprintf(&sb, "rule run\n  command = $cmd\n  description = LINK $out\n\n")
+	var defaults, tests []string
+	for _, step := range buildPlan(o) {
+		rule := "run"
+		if step.Kind == "compile" {
+			rule = "compile"
+			if !writesDepfile(o, step.Inputs[0]) {
+				rule = "compile_nodeps"
+			}
+		}
+		fmt.Fprintf(&sb, "build %s: %s %s\n  cmd = %s\n\n", ninjaPath(step.Output), rule, ninjaPaths(step.Inputs), strings.ReplaceAll(step.Command, "$", "$$"))
+		switch {
+		case step.Kind == "compile" || step.Kind == "generate":
+		case step.Test:
+			tests = append(tests, step.Output)
+		default:
+			defaults = append(defaults, step.Output)
+		}
+	}
+	if len(tests) > 0 {
+		fmt.Fprintf(&sb, "build test: phony %s\n\n", ninjaPaths(tests))
+	}
+	if len(defaults) > 0 {
+		fmt.Fprintf(&sb, "default %s\n", ninjaPaths(defaults))
+	}
+	return os.WriteFile("build.ninja", []byte(sb.String()), 0o644)
+}
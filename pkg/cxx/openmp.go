@@ -0,0 +1,89 @@
+package cxx
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// ompPragma matches an OpenMP pragma, like #pragma omp parallel for
+var ompPragma = regexp.MustCompile(`^\s*#\s*pragma\s+omp\b`)
+
+// usesOpenMP checks if any of the sources includes <omp.h> or has an OpenMP pragma
+func usesOpenMP(o *Options, srcs []string) bool {
+	for _, s := range srcs {
+		if contains(discoverIncludes(o, s), "omp.h") || hasOpenMPPragma(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasOpenMPPragma checks if the source has a #pragma omp line
+func hasOpenMPPragma(src string) bool {
+	f, err := os.Open(src)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if ompPragma.MatchString(sc.Text()) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAppleClang checks if the compiler is the clang that comes with Xcode, which is also what g++ is on macOS
+func isAppleClang(o *Options) bool {
+	return runtime.GOOS == "darwin" && strings.Contains(commandFirstLine(compilerCommand(o, "--version")), "Apple clang")
+}
+
+// compilerOpenMPDir returns the directory with the omp.h that comes with the compiler, like GCC does,
+// or "" if it has none
+func compilerOpenMPDir(o *Options) string {
+	out, err := compilerCommand(o, "-print-file-name=include/omp.h").Output()
+	if err != nil {
+		return ""
+	}
+	// Only the name is printed if the file was not found
+	if p := strings.TrimSpace(string(out)); filepath.IsAbs(p) && fileExists(p) {
+		return filepath.Dir(p)
+	}
+	return ""
+}
+
+// setupOpenMP adds the OpenMP flags for compiling and linking when the sources use OpenMP.
+// Apple clang has no -fopenmp, but can use libomp from Homebrew by passing -fopenmp to the preprocessor.
+func setupOpenMP(o *Options) {
+	if !usesOpenMP(o, o.Sources) || contains(o.ExtraCFlags, "-fopenmp") {
+		return
+	}
+	switch {
+	case o.Wasm || o.Wasi || isZig(o):
+		fmt.Println("OpenMP is not available for this build, the OpenMP pragmas are ignored.")
+	case isMSVC(o):
+		o.ExtraCFlags = append(o.ExtraCFlags, "/openmp")
+	case isAppleClang(o):
+		if keg := kegOnlyPrefix("omp.h"); keg != "" {
+			if inc := "-I" + filepath.Join(keg, "include"); !contains(o.ExtraCFlags, inc) {
+				o.ExtraCFlags = append(o.ExtraCFlags, inc)
+				o.ExtraLDFlags = append(o.ExtraLDFlags, "-L"+filepath.Join(keg, "lib"))
+			}
+		}
+		o.ExtraCFlags = append(o.ExtraCFlags, "-Xpreprocessor", "-fopenmp")
+		o.ExtraLDFlags = append(o.ExtraLDFlags, "-lomp")
+	default:
+		if dir := compilerOpenMPDir(o); dir != "" && !contains(o.SystemIncludeDirs, dir) {
+			// Not one of the system include directories, but the compiler finds it with -fopenmp
+			o.SystemIncludeDirs = append(o.SystemIncludeDirs, dir)
+		}
+		o.ExtraCFlags = append(o.ExtraCFlags, "-fopenmp")
+		o.ExtraLDFlags = append(o.ExtraLDFlags, "-fopenmp")
+	}
+}
@@ -0,0 +1,86 @@
+package cxx
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// packageManager is the package manager of a distro, and how packages are installed with it
+type packageManager struct {
+	Command string
+	Install string
+}
+
+// program returns the executable that installs the packages, which is pacman and not msys2 on Windows
+func (pm packageManager) program() string {
+	if fields := strings.Fields(pm.Install); len(fields) > 0 {
+		return fields[0]
+	}
+	return pm.Command
+}
+
+// packageManagers maps from a part of the detected distro name to its package manager, checked in order
+var packageManagers = []struct {
+	distro string
+	pm     packageManager
+}{
+	{"arch", packageManager{"pacman", "pacman -S"}},
+	{"manjaro", packageManager{"pacman", "pacman -S"}},
+	{"endeavour", packageManager{"pacman", "pacman -S"}},
+	{"fedora", packageManager{"dnf", "dnf install"}},
+	{"red hat", packageManager{"dnf", "dnf install"}},
+	{"rocky", packageManager{"dnf", "dnf install"}},
+	{"alma", packageManager{"dnf", "dnf install"}},
+	{"centos", packageManager{"dnf", "dnf install"}},
+	{"suse", packageManager{"zypper", "zypper install"}},
+	{"gentoo", packageManager{"emerge", "emerge"}},
+	{"void", packageManager{"xbps-install", "xbps-install"}},
+	{"alpine", packageManager{"apk", "apk add"}},
+	{"macos", packageManager{"brew", "brew install"}},
+	{"darwin", packageManager{"brew", "brew install"}},
+	{"os x", packageManager{"brew", "brew install"}},
+	{"freebsd", packageManager{"pkg", "pkg install"}},
+	{"ghostbsd", packageManager{"pkg", "pkg install"}},
+	{"dragonfly", packageManager{"pkg", "pkg install"}},
+	{"openbsd", packageManager{"pkg_add", "pkg_add"}},
+	{"netbsd", packageManager{"pkgin", "pkgin install"}},
+	{"windows", packageManager{"msys2", "pacman -S"}},
+	{"debian", packageManager{"apt", "apt install"}},
+	{"ubuntu", packageManager{"apt", "apt install"}},
+	{"mint", packageManager{"apt", "apt install"}},
+	{"pop", packageManager{"apt", "apt install"}},
+	{"elementary", packageManager{"apt", "apt install"}},
+}
+
+// distroPackageManager returns the package manager for the detected distro, and false if it is not known
+func distroPackageManager(distro string) (packageManager, bool) {
+	l := strings.ToLower(distro)
+	for _, p := range packageManagers {
+		if strings.Contains(l, p.distro) {
+			return p.pm, true
+		}
+	}
+	return packageManager{"apt", "apt install"}, false
+}
+
+// pkgConfigProvides is the package name format for the package managers that can install
+// a package by the name of the pkg-config module it provides
+var pkgConfigProvides = map[string]string{
+	"dnf":    "pkgconfig(%s)",
+	"zypper": "pkgconfig(%s)",
+	"apk":    "pc:%s",
+	"port":   "path:lib/pkgconfig/%s.pc",
+}
+
+// addPackagePrefix adds the include and library directories below a prefix like /usr/local, if they exist
+func addPackagePrefix(o *Options, prefix string) {
+	if inc := filepath.Join(prefix, "include"); dirExists(inc) {
+		if !contains(o.SystemIncludeDirs, inc) {
+			o.SystemIncludeDirs = append(o.SystemIncludeDirs, inc)
+		}
+		o.ExtraCFlags = append(o.ExtraCFlags, "-I"+inc)
+	}
+	if lib := filepath.Join(prefix, "lib"); dirExists(lib) {
+		o.ExtraLDFlags = append(o.ExtraLDFlags, "-L"+lib)
+	}
+}
@@ -0,0 +1,86 @@
+package cxx
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+)
+
+// pchCandidates are the headers that are used as the precompiled header when none is configured
+var pchCandidates = []string{"pch.hpp", "pch.h", "stdafx.h"}
+
+// findPCH returns the configured precompiled header, or the first of the usual names that exists, or ""
+func findPCH(o *Options) string {
+	if o.PCH != "" {
+		return o.PCH
+	}
+	for _, name := range pchCandidates {
+		if fileExists(name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// pchCompileCmd returns the command for precompiling the given header, with the same flags as for
+// compiling the sources. With clang, the .pch file is loaded with -include-pch. GCC picks up the .gch
+// file next to the header that is given to -include, so a header that includes the real one is placed
+// next to it, for when the .gch can not be used.
+func pchCompileCmd(o *Options, header, out string) string {
+	sf := ""
+	if o.Std != "" {
+		sf = "-std=" + o.Std
+	}
+	return joinNonEmpty([]string{o.CXX, sf, compileFlags(o), includeFlags(o), joinExtraCFlags(o.ExtraCFlags), "-x", "c++-header", header, "-o", out})
+}
+
+// buildPCH precompiles the precompiled header, if there is one, into the pch directory of the build
+// directory. Every set of compile flags gets a directory of its own, so that switching between debug
+// and release builds does not throw it away. It is built again when the header is changed.
+func buildPCH(o *Options) error {
+	header := findPCH(o)
+	if header == "" {
+		return nil
+	}
+	if !fileExists(header) {
+		return fmt.Errorf("the precompiled header %s does not exist", header)
+	}
+	if isMSVC(o) || isZig(o) || o.Wasm || o.Wasi {
+		fmt.Println("Precompiled headers are only supported with g++ and clang++, building without", header)
+		return nil
+	}
+	sum := sha256.Sum256([]byte(pchCompileCmd(o, header, "")))
+	dir := filepath.Join(o.BuildDir, "pch", fmt.Sprintf("%x", sum[:4]))
+	if err := makeDir(o, dir); err != nil {
+		return err
+	}
+	stub := filepath.Join(dir, filepath.Base(header))
+	out := stub + ".gch"
+	if isClang(o) {
+		out = stub + ".pch"
+	}
+	// Relative, since the path is not the same inside of a container
+	rel, err := filepath.Rel(dir, header)
+	if err != nil {
+		return err
+	}
+	if err := writeFile(o, stub, []byte(fmt.Sprintf("#include %q\n", filepath.ToSlash(rel)))); err != nil {
+		return err
+	}
+	if !stampIsNewer(out, header) {
+		if err := runCommand(pchCompileCmd(o, header, out), o); err != nil {
+			return err
+		}
+	}
+	if isClang(o) {
+		o.PCHFlags = "-include-pch " + out
+	} else {
+		o.PCHFlags = "-include " + stub
+	}
+	return nil
+}
+
+// usesPCH checks if the precompiled header is included when compiling the given source
+func usesPCH(o *Options, src string) bool {
+	return o.PCHFlags != "" && !isCSource(src) && !isCUDASource(src) && !isHIPSource(o, src) && !isAsmSource(src) && !isResourceSource(src)
+}
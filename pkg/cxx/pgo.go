@@ -0,0 +1,61 @@
+package cxx
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// pgoProfileDir is where the profiles from a pgo-gen build are written, and read by pgo-use
+func pgoProfileDir(o *Options) string {
+	return filepath.Join(o.BuildDir, "profile")
+}
+
+// clangProfileData is the merged profile that clang needs for -fprofile-use
+func clangProfileData(o *Options) string {
+	return filepath.Join(pgoProfileDir(o), "default.profdata")
+}
+
+// pgoFlags returns the flags for building instrumented executables with pgo-gen,
+// or optimized executables with the collected profiles with pgo-use
+func pgoFlags(o *Options) []string {
+	switch {
+	case o.PGO == "gen" && isClang(o):
+		return []string{"-fprofile-generate=" + pgoProfileDir(o)}
+	case o.PGO == "gen":
+		return []string{"-fprofile-generate=" + pgoProfileDir(o), "-fprofile-update=atomic"}
+	case o.PGO == "use" && isClang(o):
+		return []string{"-fprofile-use=" + clangProfileData(o), "-Wno-profile-instr-unprofiled"}
+	case o.PGO == "use":
+		return []string{"-fprofile-use=" + pgoProfileDir(o), "-Wno-missing-profile"}
+	}
+	return nil
+}
+
+// preparePGOBuild selects the build directory for PGO builds and removes the objects from the
+// previous phase, since the same sources are compiled with different flags by pgo-gen and pgo-use.
+// GCC finds the profiles by object path, so both phases use the same build directory.
+// For pgo-use with clang, the raw profiles are merged first.
+func preparePGOBuild(o *Options) error {
+	o.BuildDir = filepath.Join(o.BuildDir, "pgo")
+	removeDir(o, filepath.Join(o.BuildDir, "obj"))
+	removeFile(o, cachePath(o))
+	switch o.PGO {
+	case "gen":
+		removeDir(o, pgoProfileDir(o))
+		return makeDir(o, pgoProfileDir(o))
+	case "use":
+		if !dirExists(pgoProfileDir(o)) {
+			return fmt.Errorf("no profiles found in %s, build and run with pgo-gen first", pgoProfileDir(o))
+		}
+		if isClang(o) {
+			raw, _ := filepath.Glob(filepath.Join(pgoProfileDir(o), "*.profraw"))
+			if len(raw) == 0 {
+				return fmt.Errorf("no .profraw files found in %s", pgoProfileDir(o))
+			}
+			profdata := "llvm-profdata" + compilerVersionSuffix(o)
+			return runCommand(fmt.Sprintf("%s merge -output=%s %s", profdata, clangProfileData(o), strings.Join(raw, " ")), o)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package cxx
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// pkgConfigFile returns where the pkg-config file for the library is generated
+func pkgConfigFile(o *Options) string {
+	return filepath.Join(o.BuildDir, "lib"+o.LibName+".pc")
+}
+
+// pkgConfigContents returns a pkg-config file for the library, for when it is installed in PREFIX.
+// The pkg-config modules that the library was built with are required by it, privately for a shared library,
+// and -l flags from the configuration file are added to the private libraries.
+func pkgConfigContents(o *Options) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "prefix=%s\n", o.Prefix)
+	sb.WriteString("exec_prefix=${prefix}\n")
+	sb.WriteString("libdir=${exec_prefix}/lib\n")
+	sb.WriteString("includedir=${prefix}/include\n\n")
+	fmt.Fprintf(&sb, "Name: %s\n", o.LibName)
+	fmt.Fprintf(&sb, "Description: The %s library\n", o.LibName)
+	fmt.Fprintf(&sb, "Version: %s\n", o.LibVersion)
+	if len(o.PkgConfigPackages) > 0 {
+		requires := "Requires"
+		if o.Shared {
+			requires = "Requires.private"
+		}
+		fmt.Fprintf(&sb, "%s: %s\n", requires, strings.Join(o.PkgConfigPackages, " "))
+	}
+	sb.WriteString("Cflags: -I${includedir}\n")
+	fmt.Fprintf(&sb, "Libs: -L${libdir} -l%s\n", o.LibName)
+	if o.Config != nil {
+		var private []string
+		for _, f := range o.Config.LDFlags {
+			if strings.HasPrefix(f, "-l") {
+				private = append(private, f)
+			}
+		}
+		if len(private) > 0 {
+			fmt.Fprintf(&sb, "Libs.private: %s\n", strings.Join(private, " "))
+		}
+	}
+	return sb.String()
+}
+
+// writePkgConfigFile generates the pkg-config file for the library in the build directory
+func writePkgConfigFile(o *Options) error {
+	return writeFile(o, pkgConfigFile(o), []byte(pkgConfigContents(o)))
+}
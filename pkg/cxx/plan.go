@@ -0,0 +1,105 @@
+package cxx
+
+import (
+	"fmt"
+	"strings"
+)
+
+// buildStep is one command of a full build, together with the files it reads and writes
+type buildStep struct {
+	Output  string
+	Inputs  []string
+	Command string
+	Libs    []string // libraries that are linked in after the inputs
+	Kind    string   // "generate", "compile", "link", "shared", "archive" or "symlink"
+	Test    bool     // a step that is only needed for the test executables
+}
+
+// buildPlan returns every compile, link and archive step of a full build, including the test executables,
+// using the same commands as a build would run. This is used when exporting to other build systems.
+func buildPlan(o *Options) []buildStep {
+	var steps []buildStep
+	objs := map[string]string{}
+	for _, s := range o.Sources {
+		obj := objectPath(o, s)
+		objs[s] = obj
+		steps = append(steps, buildStep{Output: obj, Inputs: []string{s}, Command: planCommand(o, buildCompileCmd(o, s, obj)), Kind: "compile", Test: isTestSource(s)})
+	}
+	var libObjs, sharedObjs []string
+	for _, s := range o.Sources {
+		if isTestSource(s) {
+			continue
+		}
+		libObjs = append(libObjs, objs[s])
+		if s != o.MainSource && targetOf(o, s) == nil {
+			sharedObjs = append(sharedObjs, objs[s])
+		}
+	}
+	link := func(objs []string, out string) {
+		steps = append(steps, buildStep{Output: out, Inputs: objs, Command: planCommand(o, buildLinkCmd(o, objs, out)), Kind: "link"})
+	}
+	switch {
+	case o.Shared:
+		steps = append(steps, buildStep{Output: o.OutputName, Inputs: libObjs, Command: planCommand(o, buildSharedLinkCmd(o, libObjs)), Kind: "shared"})
+		target := o.OutputName
+		for _, l := range sharedLibraryLinks(o.OutputName, o.LibVersion) {
+			steps = append(steps, buildStep{Output: l, Inputs: []string{target}, Command: fmt.Sprintf("ln -sf %s %s", target, l), Kind: "symlink"})
+			target = l
+		}
+	case o.Lib:
+		steps = append(steps, buildStep{Output: o.OutputName, Inputs: libObjs, Command: fmt.Sprintf("rm -f %s && %s", o.OutputName, planCommand(o, buildArchiveCmd(o, libObjs))), Kind: "archive"})
+	default:
+		if o.MainSource != "" || len(o.Targets) == 0 {
+			if len(o.Targets) > 0 {
+				link(append([]string{objs[o.MainSource]}, sharedObjs...), ensureExeSuffix(o.OutputName, o.Win64Docker))
+			} else if o.OutputName != "" {
+				link(libObjs, ensureExeSuffix(o.OutputName, o.Win64Docker))
+			}
+		}
+		for _, t := range o.Targets {
+			var tobjs []string
+			for _, s := range t.Sources {
+				tobjs = append(tobjs, objs[s])
+			}
+			link(append(tobjs, sharedObjs...), t.Output)
+		}
+	}
+	// The tests get the same main function as with cxx2 test, when they use a test framework that provides it
+	generated := map[string]bool{}
+	for _, s := range o.TestSources {
+		exe := testExecutable(o, s)
+		in := append([]string{objs[s]}, sharedObjs...)
+		var libs []string
+		if m, contents := testMain(o, s); m != "" {
+			obj := objectPath(o, m)
+			if !generated[m] {
+				generated[m] = true
+				steps = append(steps, buildStep{Output: m, Command: generateCommand(m, contents), Kind: "generate", Test: true})
+				steps = append(steps, buildStep{Output: obj, Inputs: []string{m}, Command: planCommand(o, buildCompileCmd(o, m, obj)), Kind: "compile", Test: true})
+			}
+			in = append(in, obj)
+		} else if framework, _ := testFramework(o, s); framework == frameworkGoogleTest && !hasMainFunction(s) {
+			libs = gtestMainLibraries(o)
+		}
+		steps = append(steps, buildStep{Output: exe, Inputs: in, Libs: libs, Command: planCommand(o, buildLinkCmd(o, append(in, libs...), exe)), Kind: "link", Test: true})
+	}
+	return steps
+}
+
+// generateCommand returns a shell command that writes the given lines to the given file
+func generateCommand(path, contents string) string {
+	args := []string{"printf", shellQuote(`%s\n`)}
+	for _, line := range strings.Split(strings.TrimSuffix(contents, "\n"), "\n") {
+		args = append(args, shellQuote(line))
+	}
+	return strings.Join(args, " ") + " > " + path
+}
+
+// planCommand wraps the command in a docker or podman invocation when cross compiling with --win64-docker,
+// or when building a static executable in an Alpine container
+func planCommand(o *Options, line string) string {
+	if !o.Win64Docker && !o.StaticDocker {
+		return line
+	}
+	return containerCommand(o, line)
+}
@@ -0,0 +1,73 @@
+package cxx
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// profileTopLines is how many lines of the perf report summary that are shown
+const profileTopLines = 25
+
+// profilePath returns where the profile of running the given executable is stored, in the profiling build directory
+func profilePath(o *Options, exe string) string {
+	name := filepath.Base(exe) + ".perf.data"
+	if runtime.GOOS == "darwin" {
+		name = filepath.Base(exe) + ".trace"
+	}
+	return filepath.Join(o.BuildDir, name)
+}
+
+// profileCommand returns the command for recording a profile of running the given command, with call graphs,
+// with perf record on Linux and with the Time Profiler of Instruments on macOS
+func profileCommand(o *Options, cmd *exec.Cmd, exe string) (*exec.Cmd, error) {
+	if o.Valgrind {
+		return nil, fmt.Errorf("--profile can not be combined with --valgrind")
+	}
+	out := profilePath(o, exe)
+	if err := makeDir(o, filepath.Dir(out)); err != nil {
+		return nil, err
+	}
+	if runtime.GOOS == "darwin" {
+		if !haveCmd("xcrun") {
+			return nil, fmt.Errorf("xcrun is needed for profiling, install the Xcode command line tools with: xcode-select --install")
+		}
+		// xctrace does not replace an existing trace
+		removeDir(o, out)
+		args := []string{"xctrace", "record", "--template", "Time Profiler", "--output", out, "--launch", "--"}
+		return exec.Command("xcrun", append(args, cmd.Args...)...), nil
+	}
+	if !haveCmd("perf") {
+		return nil, fmt.Errorf("perf was not found, install it with: %s", installSuggestion(o.DetectedDistro, "perf"))
+	}
+	args := []string{"record", "--call-graph=fp", "-o", out, "--"}
+	return exec.Command("perf", append(args, cmd.Args...)...), nil
+}
+
+// reportProfile prints the functions where the most time was spent, from the profile of the given executable
+func reportProfile(o *Options, exe string) error {
+	data := profilePath(o, exe)
+	if runtime.GOOS == "darwin" {
+		fmt.Println("The profile was written to", data+", open it in Instruments with: open", data)
+		return nil
+	}
+	out, err := exec.Command("perf", "report", "--stdio", "--no-children", "--sort=symbol", "--percent-limit=0.5", "-g", "none", "-i", data).Output()
+	if err != nil {
+		return fmt.Errorf("perf report failed: %w", err)
+	}
+	fmt.Println("Top functions:")
+	shown := 0
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fmt.Println(line)
+		if shown++; shown == profileTopLines {
+			break
+		}
+	}
+	fmt.Println("The profile was written to", data+", see all of it with: perf report -i", data)
+	return nil
+}
@@ -0,0 +1,225 @@
+package cxx
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// qtInputs are the files of a Qt project that moc, uic and rcc generate code from
+type qtInputs struct {
+	mocHeaders []string // headers with Q_OBJECT, compiled as moc_NAME.cpp
+	mocSources []string // sources with Q_OBJECT, that #include "NAME.moc" themselves
+	forms      []string // .ui files, that become ui_NAME.h
+	resources  []string // .qrc files, that become qrc_NAME.cpp
+}
+
+func (q *qtInputs) empty() bool {
+	return len(q.mocHeaders) == 0 && len(q.mocSources) == 0 && len(q.forms) == 0 && len(q.resources) == 0
+}
+
+// hasQObject checks if the file declares a class with the Q_OBJECT or Q_GADGET macro, which moc needs to process
+func hasQObject(file string) bool {
+	b, err := os.ReadFile(file)
+	return err == nil && (bytes.Contains(b, []byte("Q_OBJECT")) || bytes.Contains(b, []byte("Q_GADGET")))
+}
+
+// findQtInputs walks the project for the files that moc, uic and rcc should process
+func findQtInputs(o *Options) (*qtInputs, error) {
+	q := &qtInputs{}
+	err := walkProject(o.BuildDir, func(path string) {
+		switch {
+		case strings.HasSuffix(path, ".ui"):
+			q.forms = append(q.forms, path)
+		case strings.HasSuffix(path, ".qrc"):
+			q.resources = append(q.resources, path)
+		case isHeader(path) && hasQObject(path):
+			q.mocHeaders = append(q.mocHeaders, path)
+		case contains(o.Sources, path) && hasQObject(path):
+			q.mocSources = append(q.mocSources, path)
+		}
+	})
+	return q, err
+}
+
+// qtVersion returns the pkg-config prefix of the Qt that is installed, preferring Qt6, or "" if there is none
+func qtVersion() string {
+	for _, v := range []string{"Qt6", "Qt5"} {
+		if exec.Command("pkg-config", "--exists", v+"Core").Run() == nil {
+			return v
+		}
+	}
+	return ""
+}
+
+// pkgConfigVariable returns a variable from the .pc file of the given module, or "" if it is not set
+func pkgConfigVariable(module, variable string) string {
+	out, err := exec.Command("pkg-config", "--variable="+variable, module).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// qtTool returns the path to moc, uic or rcc for the given Qt version. Qt6 places them in libexecdir
+// and Qt5 in host_bins, while some distros also have them in PATH with a -qt5 or -qt6 suffix.
+func qtTool(version, name string) string {
+	for _, variable := range []string{"libexecdir", "host_bins", "bindir"} {
+		if dir := pkgConfigVariable(version+"Core", variable); dir != "" {
+			if p := filepath.Join(dir, name); fileExists(p) {
+				return p
+			}
+		}
+	}
+	for _, n := range []string{name + "-" + strings.ToLower(version), name} {
+		if haveCmd(n) {
+			return n
+		}
+	}
+	return ""
+}
+
+// qtModules returns the Qt modules that the includes are from, like Widgets for <QtWidgets/QPushButton>
+// or <QPushButton>, which is looked up in the include directory of Qt. Core is always included.
+func qtModules(version string, includes []string, q *qtInputs) []string {
+	modules := []string{"Core"}
+	add := func(m string) {
+		if !contains(modules, m) {
+			modules = append(modules, m)
+		}
+	}
+	if len(q.forms) > 0 {
+		add("Gui")
+		add("Widgets")
+	}
+	includeDir := pkgConfigVariable(version+"Core", "includedir")
+	for _, inc := range includes {
+		if dir, _, ok := strings.Cut(inc, "/"); ok {
+			if strings.HasPrefix(dir, "Qt") {
+				add(strings.TrimPrefix(dir, "Qt"))
+			}
+			continue
+		}
+		if !strings.HasPrefix(inc, "Q") || includeDir == "" {
+			continue
+		}
+		if matches, _ := filepath.Glob(filepath.Join(includeDir, "Qt*", inc)); len(matches) > 0 {
+			add(strings.TrimPrefix(filepath.Base(filepath.Dir(matches[0])), "Qt"))
+		}
+	}
+	return modules
+}
+
+// qtGenerate runs the given Qt tool for the input, unless the output is newer
+func qtGenerate(o *Options, tool, in, out string, args ...string) error {
+	if stampIsNewer(out, in) {
+		return nil
+	}
+	return runVisible(o, tool, append(args, in, "-o", out)...)
+}
+
+// setupQt builds Qt projects, when there are classes with Q_OBJECT, .ui forms or .qrc resources.
+// moc, uic and rcc generate code into the qt directory of the build directory, the flags of the
+// Qt modules come from pkg-config, and the generated sources that should be compiled are returned.
+func setupQt(o *Options) ([]string, error) {
+	q, err := findQtInputs(o)
+	if err != nil || q.empty() {
+		return nil, err
+	}
+	version := qtVersion()
+	if version == "" {
+		return nil, fmt.Errorf("Qt was not found with pkg-config, install it with: %s", installSuggestion(o.DetectedDistro, "qt"))
+	}
+	for _, m := range qtModules(version, gatherAllIncludes(o, append(o.Sources, q.mocHeaders...)), q) {
+		flags, err := gatherPkgConfigFlags(version+m, o.DetectedDistro)
+		if err != nil {
+			return nil, fmt.Errorf("the Qt module %s%s was not found", version, m)
+		}
+		var added []string
+		for _, f := range strings.Fields(flags) {
+			if contains(o.ExtraCFlags, f) || contains(o.ExtraLDFlags, f) {
+				// The modules share the include directory of Qt and often also libraries
+				continue
+			}
+			added = append(added, f)
+			// So that includes like <QApplication> are not reported as missing
+			if dir, ok := strings.CutPrefix(f, "-I"); ok && !contains(o.SystemIncludeDirs, dir) {
+				o.SystemIncludeDirs = append(o.SystemIncludeDirs, dir)
+			}
+		}
+		mergePkgConfigFlags(strings.Join(added, " "), o)
+		if !contains(o.PkgConfigPackages, version+m) {
+			o.PkgConfigPackages = append(o.PkgConfigPackages, version+m)
+		}
+	}
+	genDir := filepath.Join(o.BuildDir, "qt")
+	if err := makeDir(o, genDir); err != nil {
+		return nil, err
+	}
+	// For ui_NAME.h and NAME.moc
+	o.IncludeDirs = append(o.IncludeDirs, genDir)
+	tools := map[string]string{}
+	for _, name := range []string{"moc", "uic", "rcc"} {
+		tools[name] = qtTool(version, name)
+	}
+	need := func(name string) (string, error) {
+		if tools[name] == "" {
+			return "", fmt.Errorf("%s was not found for %s", name, version)
+		}
+		return tools[name], nil
+	}
+	base := func(p string) string {
+		return strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+	}
+	var generated []string
+	for _, h := range q.mocHeaders {
+		moc, err := need("moc")
+		if err != nil {
+			return nil, err
+		}
+		out := filepath.Join(genDir, "moc_"+base(h)+".cpp")
+		if err := qtGenerate(o, moc, h, out); err != nil {
+			return nil, err
+		}
+		generated = append(generated, out)
+	}
+	for _, s := range q.mocSources {
+		moc, err := need("moc")
+		if err != nil {
+			return nil, err
+		}
+		if err := qtGenerate(o, moc, s, filepath.Join(genDir, base(s)+".moc")); err != nil {
+			return nil, err
+		}
+	}
+	for _, f := range q.forms {
+		uic, err := need("uic")
+		if err != nil {
+			return nil, err
+		}
+		if err := qtGenerate(o, uic, f, filepath.Join(genDir, "ui_"+base(f)+".h")); err != nil {
+			return nil, err
+		}
+	}
+	for _, r := range q.resources {
+		rcc, err := need("rcc")
+		if err != nil {
+			return nil, err
+		}
+		out := filepath.Join(genDir, "qrc_"+base(r)+".cpp")
+		// The files in the resource collection are found relative to the .qrc file
+		if err := qtGenerate(o, rcc, r, out, "--name", base(r)); err != nil {
+			return nil, err
+		}
+		generated = append(generated, out)
+	}
+	for _, g := range generated {
+		if !contains(o.Sources, g) {
+			o.Sources = append(o.Sources, g)
+		}
+	}
+	return generated, nil
+}
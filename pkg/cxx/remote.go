@@ -0,0 +1,97 @@
+package cxx
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// remoteSourcePatterns are the rsync patterns for the files that are only sent to the remote machine,
+// and never copied back from it
+var remoteSourcePatterns = []string{
+	"*.c", "*.cc", "*.cpp", "*.cxx", "*.c++", "*.cppm", "*.ixx", "*.mpp", "*.cu", "*.hip", "*.s", "*.S", "*.asm", "*.rc",
+	"*.vert", "*.frag", "*.comp", "*.geom", "*.tesc", "*.tese", "*.glsl",
+	"*.h", "*.hh", "*.hpp", "*.hxx", "*.h++", "*.inl", "*.cuh",
+	"*.y", "*.yy", "*.l", "*.ll",
+	"cxx.toml", ".cxx.toml", ".git/",
+}
+
+// remoteDir returns where the project is synced to on the remote machine, relative to the home directory there,
+// like .cache/cxx2/remote/1a2b3c4d/myproject. The hash keeps projects with the same name apart,
+// and the name is kept, since the executable is named after the directory.
+func remoteDir() string {
+	pwd := mustPwd()
+	sum := sha256.Sum256([]byte(pwd))
+	return fmt.Sprintf(".cache/cxx2/remote/%x/%s", sum[:4], filepath.Base(pwd))
+}
+
+// remoteArgs returns the arguments for cxx2 on the remote machine, which are the same as the local ones,
+// but with a build directory of its own, since the objects may be for another architecture
+func remoteArgs(o *Options, args []string) []string {
+	var out []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "--remote=") {
+			out = append(out, arg)
+		}
+	}
+	return append(out, "--build-dir="+path.Join(filepath.ToSlash(o.BuildDir), "remote"))
+}
+
+// shellQuote quotes the argument for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// runVisible prints and runs the given command, with the output going to stdout and stderr
+func runVisible(o *Options, name string, args ...string) error {
+	fmt.Println(name, strings.Join(args, " "))
+	if o.DryRun {
+		return nil
+	}
+	c := exec.Command(name, args...)
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	return c.Run()
+}
+
+// BuildRemote syncs the project to the machine in --remote= with rsync, builds it there with cxx2 and the same
+// arguments over ssh, and copies the outputs, test executables and reports back, also when the build failed
+func BuildRemote(o *Options, args []string) error {
+	for _, tool := range []string{"rsync", "ssh"} {
+		if !haveCmd(tool) {
+			return fmt.Errorf("%s is needed for --remote", tool)
+		}
+	}
+	host, dir := o.Remote, remoteDir()
+	if err := runVisible(o, "ssh", host, "mkdir -p "+shellQuote(dir)); err != nil {
+		return fmt.Errorf("could not connect to %s: %w", host, err)
+	}
+	up := []string{"-az", "--delete", "--exclude=.git/"}
+	if !filepath.IsAbs(o.BuildDir) {
+		// The remote build directory is kept between builds
+		up = append(up, "--exclude=/"+filepath.ToSlash(filepath.Clean(o.BuildDir))+"/")
+	}
+	if err := runVisible(o, "rsync", append(up, "./", host+":"+dir+"/")...); err != nil {
+		return err
+	}
+	command := "cd " + shellQuote(dir) + " && cxx2"
+	for _, arg := range remoteArgs(o, args) {
+		command += " " + shellQuote(arg)
+	}
+	buildErr := runVisible(o, "ssh", host, command)
+	if ExitCode(buildErr) == 127 {
+		return fmt.Errorf("cxx2 was not found on %s, install it there first", host)
+	}
+	down := []string{"-az"}
+	for _, p := range remoteSourcePatterns {
+		down = append(down, "--exclude="+p)
+	}
+	if err := runVisible(o, "rsync", append(down, host+":"+dir+"/", "./")...); err != nil && buildErr == nil {
+		return err
+	}
+	return buildErr
+}
@@ -0,0 +1,176 @@
+package cxx
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// warningRx matches the warnings of GCC, Clang and MSVC
+var warningRx = regexp.MustCompile(`(?m)(: warning:|: warning C\d+:)`)
+
+// buildReport is the machine-readable summary of a build, with durations in seconds
+type buildReport struct {
+	mu          sync.Mutex
+	start       time.Time
+	Success     bool            `json:"success"`
+	Error       string          `json:"error,omitempty"`
+	Compiler    string          `json:"compiler"`
+	BuildDir    string          `json:"build_dir"`
+	Duration    float64         `json:"duration"`
+	CPU         float64         `json:"cpu"`
+	CacheHits   int             `json:"cache_hits"`
+	CacheMisses int             `json:"cache_misses"`
+	Warnings    int             `json:"warnings"`
+	Compiles    []compileReport `json:"compiles"`
+	Links       []linkReport    `json:"links"`
+}
+
+// compileReport is what happened to one source. The status is "compiled", "cached" or "failed".
+type compileReport struct {
+	Source   string  `json:"source"`
+	Object   string  `json:"object,omitempty"`
+	Status   string  `json:"status"`
+	Duration float64 `json:"duration"`
+	Warnings int     `json:"warnings"`
+}
+
+// linkReport is one executable or library that was linked or archived. A single step build compiles
+// and links with one command, and then the time is counted for the compilation.
+type linkReport struct {
+	Output     string  `json:"output"`
+	Duration   float64 `json:"duration"`
+	Size       int64   `json:"size"`
+	SingleStep bool    `json:"single_step,omitempty"`
+}
+
+// countWarnings returns the number of compiler warnings in the given compiler output
+func countWarnings(output string) int {
+	return len(warningRx.FindAllString(output, -1))
+}
+
+// compiled records that the given source was compiled, or not if the object file was up to date
+func (r *buildReport) compiled(src, obj, status string, d time.Duration, output string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	warnings := countWarnings(stripANSI(output))
+	r.Compiles = append(r.Compiles, compileReport{Source: src, Object: obj, Status: status, Duration: d.Seconds(), Warnings: warnings})
+	r.Warnings += warnings
+	switch status {
+	case "cached":
+		r.CacheHits++
+	case "compiled":
+		r.CacheMisses++
+	}
+}
+
+// ran records the CPU time of a command that was run for the build
+func (r *buildReport) ran(state *os.ProcessState) {
+	if r == nil || state == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.CPU += (state.UserTime() + state.SystemTime()).Seconds()
+}
+
+// linked records that the given output was linked
+func (r *buildReport) linked(out string, d time.Duration, singleStep bool) {
+	if r == nil {
+		return
+	}
+	var size int64
+	if fi, err := os.Stat(out); err == nil {
+		size = fi.Size()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Links = append(r.Links, linkReport{Output: out, Duration: d.Seconds(), Size: size, SingleStep: singleStep})
+}
+
+// buildWithReport builds like build does, and then adds the build to the history, prints the timings for
+// --timings and writes a JSON report of the build for --report=, to stdout for --report=json, while the
+// output of the build goes to stderr, or else to the given file
+func buildWithReport(o *Options) error {
+	buildDir := o.BuildDir
+	report := &buildReport{start: time.Now(), Compiles: []compileReport{}, Links: []linkReport{}}
+	o.state.report = report
+	defer func() { o.state.report = nil }()
+	stdout := os.Stdout
+	toStdout := o.Report == "json"
+	if toStdout {
+		os.Stdout = os.Stderr
+		defer func() { os.Stdout = stdout }()
+	}
+	err := build(o)
+	report.Success = err == nil
+	if err != nil {
+		report.Error = err.Error()
+	}
+	report.Compiler = o.CXX
+	report.BuildDir = o.BuildDir
+	report.Duration = time.Since(report.start).Seconds()
+	recordBuild(buildDir, o, report)
+	if o.Timings {
+		printTimings(report)
+	}
+	if o.Report == "" {
+		return err
+	}
+	b, jerr := json.MarshalIndent(report, "", "  ")
+	if jerr != nil {
+		return jerr
+	}
+	b = append(b, '\n')
+	if toStdout {
+		stdout.Write(b)
+	} else if werr := os.WriteFile(o.Report, b, 0o644); werr != nil {
+		return werr
+	}
+	return err
+}
+
+// slowestShown is how many of the slowest compilations --timings shows
+const slowestShown = 10
+
+// printTimings prints the slowest compilations and links, and the total wall and CPU time of the build
+func printTimings(r *buildReport) {
+	var steps []compileReport
+	for _, c := range r.Compiles {
+		if c.Status != "cached" {
+			steps = append(steps, c)
+		}
+	}
+	for _, l := range r.Links {
+		if !l.SingleStep {
+			steps = append(steps, compileReport{Source: l.Output, Status: "linked", Duration: l.Duration})
+		}
+	}
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].Duration > steps[j].Duration })
+	if len(steps) > 0 {
+		fmt.Println("Slowest steps:")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, s := range steps[:min(len(steps), slowestShown)] {
+			fmt.Fprintf(w, "  %6.2fs\t%s\t%s\n", s.Duration, s.Status, s.Source)
+		}
+		w.Flush()
+		if len(steps) > slowestShown {
+			fmt.Printf("  ... and %d more\n", len(steps)-slowestShown)
+		}
+	}
+	fmt.Printf("Total: %.2fs wall time, %.2fs CPU time in %d compilation(s), %d cached\n", r.Duration, r.CPU, r.CacheMisses, r.CacheHits)
+}
+
+// validReport checks if the given --report= value is json or a .json file
+func validReport(s string) bool {
+	return s == "json" || strings.HasSuffix(strings.ToLower(s), ".json")
+}
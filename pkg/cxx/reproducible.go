@@ -0,0 +1,57 @@
+package cxx
+
+import (
+	"os"
+	"sort"
+)
+
+// reproducibleFlags returns the compilation flags for --reproducible. The project directory is left out of
+// the debug info and __FILE__, and __DATE__ and __TIME__ are errors, since they change from build to build.
+func reproducibleFlags(o *Options) []string {
+	if isMSVC(o) {
+		return []string{"/Brepro"}
+	}
+	dir := mustPwd()
+	if o.Win64Docker || o.StaticDocker {
+		// Where the project is mounted in the container
+		dir = "/home"
+	}
+	return []string{"-ffile-prefix-map=" + dir + "=.", "-Werror=date-time"}
+}
+
+// reproducibleLinkFlags returns the linker flags for --reproducible, for the linkers that write a timestamp
+func reproducibleLinkFlags(o *Options) []string {
+	switch {
+	case isMSVC(o):
+		return []string{"/Brepro"}
+	case onWindows(o) || o.Win64Docker:
+		return []string{"-Wl,--no-insert-timestamp"}
+	}
+	return nil
+}
+
+// setupReproducible sets SOURCE_DATE_EPOCH to the time of the last commit, unless it is set already,
+// for the compilers and tools that write the time of the build into what they produce
+func setupReproducible(o *Options) {
+	if os.Getenv("SOURCE_DATE_EPOCH") == "" {
+		if t := gitOutput("log", "-1", "--format=%ct"); t != "" {
+			verbosef(o, "Using the time of the last commit for SOURCE_DATE_EPOCH: %s\n", t)
+			os.Setenv("SOURCE_DATE_EPOCH", t)
+		}
+	}
+	if onMacOS(o) {
+		// For ar and ld64, which otherwise write modification times
+		os.Setenv("ZERO_AR_DATE", "1")
+	}
+}
+
+// linkOrder returns the object files in the order that they are linked in, which is sorted for --reproducible,
+// so that the output does not depend on the order that the sources were found or compiled in
+func linkOrder(o *Options, objs []string) []string {
+	if !o.Reproducible {
+		return objs
+	}
+	sorted := append([]string{}, objs...)
+	sort.Strings(sorted)
+	return sorted
+}
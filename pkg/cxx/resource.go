@@ -0,0 +1,67 @@
+package cxx
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// isResourceSource checks if the source is a Windows resource script, with icons, version info or a manifest
+func isResourceSource(s string) bool {
+	return strings.ToLower(filepath.Ext(s)) == ".rc"
+}
+
+// buildsForWindows checks if the executables that are built are for Windows
+func buildsForWindows(o *Options) bool {
+	return o.Win64Docker || onWindows(o) || isMSVC(o) || strings.Contains(o.Target, "mingw") || strings.Contains(o.Target, "windows")
+}
+
+// windres returns the windres command that matches the selected compiler,
+// for instance x86_64-w64-mingw32-windres for x86_64-w64-mingw32-g++
+func windres(o *Options) string {
+	if i := strings.LastIndex(o.CXX, "-"); i > 0 && strings.HasSuffix(o.CXX, "g++") {
+		return o.CXX[:i+1] + "windres"
+	}
+	return "windres"
+}
+
+// setupResources adds the resource scripts in the project to the sources when building for Windows,
+// so that they are compiled and linked into the executables. The added sources are returned.
+func setupResources(o *Options) ([]string, error) {
+	if !buildsForWindows(o) {
+		return nil, nil
+	}
+	var resources []string
+	if err := walkProject(o.BuildDir, func(path string) {
+		if isResourceSource(path) {
+			resources = append(resources, path)
+		}
+	}); err != nil {
+		return nil, err
+	}
+	resources = excludeSources(resources, o.Exclude)
+	if len(resources) == 0 {
+		return nil, nil
+	}
+	// The container has windres next to the compiler
+	if !o.Win64Docker {
+		tool := windres(o)
+		if isMSVC(o) {
+			tool = "rc"
+		}
+		if !haveCmd(tool) {
+			return nil, fmt.Errorf("%s is needed for the .rc files", tool)
+		}
+	}
+	o.Sources = append(o.Sources, resources...)
+	return resources, nil
+}
+
+// resourceCompileCmd returns the command that compiles the given resource script into the given object file,
+// with rc.exe for MSVC or else with windres
+func resourceCompileCmd(o *Options, src, obj string) string {
+	if isMSVC(o) {
+		return joinNonEmpty([]string{"rc", "/nologo", includeFlags(o), defineFlags(o), "/fo" + obj, src})
+	}
+	return joinNonEmpty([]string{windres(o), "-O", "coff", includeFlags(o), defineFlags(o), src, "-o", obj})
+}
@@ -0,0 +1,196 @@
+package cxx
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// diagnosticRx matches a diagnostic from GCC, Clang, clang-tidy or cppcheck, with the template that is used for it,
+// with the option or check that caused it at the end, if any
+var diagnosticRx = regexp.MustCompile(`^(.+?):(\d+):(\d+): (fatal error|error|warning|style|performance|portability|information): (.*?)(?: \[([^\]]+)\])?$`)
+
+// msvcDiagnosticRx matches a diagnostic from MSVC
+var msvcDiagnosticRx = regexp.MustCompile(`^(.+?)\((\d+)(?:,(\d+))?\): (fatal error|error|warning) ([A-Z]+\d+): (.*)$`)
+
+// diagnostic is one error or warning at a location in a file
+type diagnostic struct {
+	file         string
+	line, column int
+	level        string // error, warning or note
+	message      string
+	rule         string
+}
+
+// diagnosticLog is the diagnostics of each tool, without duplicates
+type diagnosticLog struct {
+	mu    sync.Mutex
+	tools map[string][]diagnostic
+	seen  map[string]bool
+}
+
+// sarifLevel returns the SARIF level for the given severity
+func sarifLevel(severity string) string {
+	switch severity {
+	case "fatal error", "error":
+		return "error"
+	case "information":
+		return "note"
+	}
+	return "warning"
+}
+
+// compilerToolName returns the name of the compiler that the diagnostics are reported for
+func compilerToolName(o *Options) string {
+	switch {
+	case isMSVC(o):
+		return "msvc"
+	case isClang(o):
+		return "clang"
+	}
+	return "gcc"
+}
+
+// parseDiagnostics returns the diagnostics in the given output of a compiler or an analyzer. Notes are left out,
+// since they belong to the diagnostic before them.
+func parseDiagnostics(output string) []diagnostic {
+	var ds []diagnostic
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		var d diagnostic
+		if m := diagnosticRx.FindStringSubmatch(line); m != nil {
+			d = diagnostic{file: m[1], level: sarifLevel(m[4]), message: m[5], rule: m[6]}
+			d.line, _ = strconv.Atoi(m[2])
+			d.column, _ = strconv.Atoi(m[3])
+		} else if m := msvcDiagnosticRx.FindStringSubmatch(line); m != nil {
+			d = diagnostic{file: m[1], level: sarifLevel(m[4]), message: m[6], rule: m[5]}
+			d.line, _ = strconv.Atoi(m[2])
+			d.column, _ = strconv.Atoi(m[3])
+		} else {
+			continue
+		}
+		ds = append(ds, d)
+	}
+	return ds
+}
+
+// key identifies the diagnostic, for leaving out the ones in headers that are reported for every source
+func (d diagnostic) key() string {
+	return fmt.Sprintf("%s:%d:%d:%s:%s", d.file, d.line, d.column, d.level, d.message)
+}
+
+// add records the given diagnostics of the given tool, leaving out the ones that are already recorded
+func (l *diagnosticLog) add(tool string, ds []diagnostic) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, d := range ds {
+		key := tool + "\x00" + d.key()
+		if l.seen[key] {
+			continue
+		}
+		l.seen[key] = true
+		l.tools[tool] = append(l.tools[tool], d)
+	}
+}
+
+// recordDiagnostics records the diagnostics in the given output of the given tool, for --sarif= and
+// cxx2 baseline, and prints them as workflow commands for --github-annotations
+func recordDiagnostics(o *Options, tool, output string) {
+	if o.state.diagnostics == nil && o.state.warningLog == nil && !o.GitHubAnnotations {
+		return
+	}
+	ds := parseDiagnostics(stripANSI(output))
+	o.state.diagnostics.add(tool, ds)
+	o.state.warningLog.add(tool, ds)
+	if o.GitHubAnnotations {
+		annotate(o, tool, ds)
+	}
+}
+
+// sarifURI returns the URI of the given file, relative to the project if it is in it
+func sarifURI(file string) (string, bool) {
+	if filepath.IsAbs(file) {
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, file); err == nil && !strings.HasPrefix(rel, "..") {
+				return filepath.ToSlash(rel), true
+			}
+		}
+		return "file://" + filepath.ToSlash(file), false
+	}
+	return filepath.ToSlash(filepath.Clean(file)), true
+}
+
+// sarif returns the diagnostics as a SARIF 2.1.0 log, with one run for each tool
+func (l *diagnosticLog) sarif() map[string]any {
+	var names []string
+	for tool := range l.tools {
+		names = append(names, tool)
+	}
+	sort.Strings(names)
+	runs := []any{}
+	for _, tool := range names {
+		var rules []any
+		ruleSeen := map[string]bool{}
+		results := []any{}
+		for _, d := range l.tools[tool] {
+			uri, relative := sarifURI(d.file)
+			location := map[string]any{"uri": uri}
+			if relative {
+				location["uriBaseId"] = "%SRCROOT%"
+			}
+			region := map[string]any{"startLine": d.line}
+			if d.column > 0 {
+				region["startColumn"] = d.column
+			}
+			result := map[string]any{
+				"level":     d.level,
+				"message":   map[string]any{"text": d.message},
+				"locations": []any{map[string]any{"physicalLocation": map[string]any{"artifactLocation": location, "region": region}}},
+			}
+			if d.rule != "" {
+				result["ruleId"] = d.rule
+				if !ruleSeen[d.rule] {
+					ruleSeen[d.rule] = true
+					rules = append(rules, map[string]any{"id": d.rule})
+				}
+			}
+			results = append(results, result)
+		}
+		driver := map[string]any{"name": tool}
+		if len(rules) > 0 {
+			driver["rules"] = rules
+		}
+		runs = append(runs, map[string]any{"tool": map[string]any{"driver": driver}, "results": results})
+	}
+	return map[string]any{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs":    runs,
+	}
+}
+
+// buildWithSARIF builds with the given build function, while collecting the diagnostics, and then writes
+// them to the SARIF file, also when the build failed
+func buildWithSARIF(o *Options, build func(*Options) error) error {
+	diagnostics := &diagnosticLog{tools: map[string][]diagnostic{}, seen: map[string]bool{}}
+	o.state.diagnostics = diagnostics
+	defer func() { o.state.diagnostics = nil }()
+	err := build(o)
+	b, jerr := json.MarshalIndent(diagnostics.sarif(), "", "  ")
+	if jerr != nil {
+		return jerr
+	}
+	if werr := os.WriteFile(o.SARIF, append(b, '\n'), 0o644); werr != nil {
+		return werr
+	}
+	return err
+}
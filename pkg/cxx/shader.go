@@ -0,0 +1,154 @@
+package cxx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// shaderStages are the GLSL shader stages, as used in the file extensions
+var shaderStages = []string{"vert", "frag", "comp", "geom", "tesc", "tese"}
+
+// shaderStage returns the stage of the given GLSL shader, from the extension, like "vert" for tri.vert
+// or tri.vert.glsl. Other .glsl files give "" and rely on a #pragma shader_stage.
+func shaderStage(file string) string {
+	name := strings.TrimSuffix(strings.ToLower(filepath.Base(file)), ".glsl")
+	for _, stage := range shaderStages {
+		if strings.HasSuffix(name, "."+stage) {
+			return stage
+		}
+	}
+	return ""
+}
+
+// isShader checks if the file is a GLSL shader that should be compiled. A .glsl file without
+// a stage in the name or a #pragma shader_stage is taken to be included by other shaders.
+func isShader(file string) bool {
+	if shaderStage(file) != "" {
+		return true
+	}
+	if strings.ToLower(filepath.Ext(file)) != ".glsl" {
+		return false
+	}
+	b, err := os.ReadFile(file)
+	return err == nil && strings.Contains(string(b), "#pragma shader_stage")
+}
+
+// shaderCompiler returns glslc from shaderc, or glslangValidator, or "" if neither is installed
+func shaderCompiler() string {
+	for _, tool := range []string{"glslc", "glslangValidator"} {
+		if haveCmd(tool) {
+			return tool
+		}
+	}
+	return ""
+}
+
+// shaderCommand returns the arguments for compiling the given shader into SPIR-V with the given tool
+func shaderCommand(tool, file, spv string) []string {
+	stage := shaderStage(file)
+	if tool == "glslangValidator" {
+		args := []string{"-V"}
+		if stage != "" {
+			args = append(args, "-S", stage)
+		}
+		return append(args, "-o", spv, file)
+	}
+	var args []string
+	if stage != "" {
+		args = append(args, "-fshader-stage="+stage)
+	}
+	return append(args, "-o", spv, file)
+}
+
+// shaderSymbol returns the name of the array that a shader is embedded as, like tri_vert_spv for tri.vert
+func shaderSymbol(file string) string {
+	var sb strings.Builder
+	for _, r := range filepath.Base(file) + ".spv" {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String()
+}
+
+// writeShaderHeader writes a header with the SPIR-V words as an array of uint32_t,
+// that can be given directly to vkCreateShaderModule
+func writeShaderHeader(o *Options, file, spv, header string) error {
+	b, err := os.ReadFile(spv)
+	if err != nil {
+		return err
+	}
+	if len(b)%4 != 0 {
+		return fmt.Errorf("%s is not valid SPIR-V", spv)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "// Generated by cxx2 from %s\n#pragma once\n\n#include <cstdint>\n\n", filepath.ToSlash(file))
+	fmt.Fprintf(&sb, "inline constexpr std::uint32_t %s[] = {", shaderSymbol(file))
+	for i := 0; i < len(b); i += 4 {
+		if i%32 == 0 {
+			sb.WriteString("\n   ")
+		}
+		fmt.Fprintf(&sb, " 0x%08x,", binary.LittleEndian.Uint32(b[i:]))
+	}
+	sb.WriteString("\n};\n")
+	return writeFile(o, header, []byte(sb.String()))
+}
+
+// setupShaders compiles the GLSL shaders in the project into SPIR-V, in the shaders directory of
+// the build directory. With embed_shaders, a header like tri.vert.spv.h is also generated for each
+// shader, with the tri_vert_spv array, so that no shader files are needed next to the executable.
+// Shaders are tracked in the compile cache, so that they are only compiled again when they change.
+func setupShaders(o *Options) error {
+	var shaders []string
+	if err := walkProject(o.BuildDir, func(path string) {
+		if isShader(path) {
+			shaders = append(shaders, path)
+		}
+	}); err != nil || len(shaders) == 0 {
+		return err
+	}
+	tool := shaderCompiler()
+	if tool == "" {
+		return fmt.Errorf("glslc is needed for the shaders, install it with: %s", installSuggestion(o.DetectedDistro, "glslc"))
+	}
+	outDir := filepath.Join(o.BuildDir, "shaders")
+	if err := prepareBuildDir(o); err != nil {
+		return err
+	}
+	if err := makeDir(o, outDir); err != nil {
+		return err
+	}
+	if o.EmbedShaders {
+		o.IncludeDirs = append(o.IncludeDirs, outDir)
+	}
+	cc, err := loadCache(o)
+	if err != nil {
+		return err
+	}
+	for _, s := range shaders {
+		spv := filepath.Join(outDir, filepath.Base(s)+".spv")
+		header := ""
+		if o.EmbedShaders {
+			header = spv + ".h"
+		}
+		if !inputChanged(cc, s, spv, header) {
+			continue
+		}
+		if err := runVisible(o, tool, shaderCommand(tool, s, spv)...); err != nil {
+			return err
+		}
+		if header != "" {
+			if err := writeShaderHeader(o, s, spv, header); err != nil {
+				return err
+			}
+		}
+		updateTimestamp(s, cc)
+	}
+	saveCache(o, cc)
+	return nil
+}
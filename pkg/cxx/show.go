@@ -0,0 +1,78 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// showConfiguration prints how the build is configured after everything has been detected and resolved:
+// the distro, the compiler, the sources, the include directories, the flags from pkg-config and every
+// command of a full build, in the same way as the build would run them
+func showConfiguration(o *Options) error {
+	version := commandFirstLine(compilerCommand(o, "--version"))
+	if version == "" {
+		version = "not found"
+	}
+	list := func(items []string) string {
+		if len(items) == 0 {
+			return "none"
+		}
+		return strings.Join(items, " ")
+	}
+	var testSources []string
+	for _, s := range o.Sources {
+		if isTestSource(s) {
+			testSources = append(testSources, s)
+		}
+	}
+	var sources []string
+	for _, s := range o.Sources {
+		if !isTestSource(s) {
+			sources = append(sources, s)
+		}
+	}
+	var includeDirs []string
+	for _, d := range o.IncludeDirs {
+		// The same as for includeFlags
+		if dirExists(d) {
+			includeDirs = append(includeDirs, d)
+		}
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Distro:\t%s\n", o.DetectedDistro)
+	fmt.Fprintf(w, "Compiler:\t%s (%s)\n", o.CXX, version)
+	fmt.Fprintf(w, "Standard:\t%s\n", o.Std)
+	if hasSources(o.Sources, isCSource) {
+		fmt.Fprintf(w, "C compiler:\t%s\n", cCompiler(o))
+		fmt.Fprintf(w, "C standard:\t%s\n", o.CStd)
+	}
+	fmt.Fprintf(w, "Mode:\t%s\n", buildMode(o))
+	fmt.Fprintf(w, "Build directory:\t%s\n", o.BuildDir)
+	fmt.Fprintf(w, "Output:\t%s\n", list(append(nonEmpty(o.OutputName), targetOutputs(o)...)))
+	fmt.Fprintf(w, "Main source:\t%s\n", list(nonEmpty(o.MainSource)))
+	fmt.Fprintf(w, "Sources:\t%s\n", list(sources))
+	fmt.Fprintf(w, "Tests:\t%s\n", list(testSources))
+	fmt.Fprintf(w, "Benchmarks:\t%s\n", list(o.BenchSources))
+	fmt.Fprintf(w, "Include directories:\t%s\n", list(includeDirs))
+	fmt.Fprintf(w, "System include directories:\t%s\n", list(o.SystemIncludeDirs))
+	fmt.Fprintf(w, "pkg-config packages:\t%s\n", list(o.PkgConfigPackages))
+	fmt.Fprintf(w, "pkg-config flags:\t%s\n", list(o.PkgConfigFlags))
+	fmt.Fprintf(w, "Compilation flags:\t%s\n", list(strings.Fields(compileFlags(o))))
+	fmt.Fprintf(w, "Linker flags:\t%s\n", list(append(linkerFlags(o), o.ExtraLDFlags...)))
+	w.Flush()
+	fmt.Println("Commands:")
+	for _, step := range buildPlan(o) {
+		fmt.Println("  " + step.Command)
+	}
+	return nil
+}
+
+// nonEmpty returns a list with the given string, or an empty list if it is empty
+func nonEmpty(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return []string{s}
+}
@@ -0,0 +1,90 @@
+package cxx
+
+import "fmt"
+
+// smallFlags returns the flags for optimizing for size, with every function and variable in its own section,
+// so that the linker can leave out the ones that are not used
+func smallFlags(o *Options) []string {
+	if isMSVC(o) {
+		return []string{"/O1", "/Gy", "/Gw"}
+	}
+	return []string{"-Os", "-ffunction-sections", "-fdata-sections"}
+}
+
+// smallLinkFlags returns the flags for leaving out the unused sections and the symbols when linking
+func smallLinkFlags(o *Options) []string {
+	switch {
+	case isMSVC(o):
+		return []string{"/OPT:REF", "/OPT:ICF"}
+	case onMacOS(o):
+		// ld64 has no -s, the executables are stripped afterwards
+		return []string{"-Wl,-dead_strip"}
+	case o.SplitDebug:
+		// The debug info is split out after linking, and the rest of the symbols are stripped then
+		return []string{"-Wl,--gc-sections"}
+	}
+	return []string{"-Wl,--gc-sections", "-s"}
+}
+
+// stripCommand returns the strip command for the outputs, or "" if there is none
+func stripCommand(o *Options) string {
+	switch {
+	case o.Win64Docker:
+		// Run in the container
+		return "x86_64-w64-mingw32-strip"
+	case o.Target != "" && haveCmd(o.Target+"-strip"):
+		return o.Target + "-strip"
+	case o.Target == "" && haveCmd("strip"):
+		return "strip"
+	case haveCmd("llvm-strip"):
+		return "llvm-strip"
+	}
+	return ""
+}
+
+// stripOutputs strips the executables and the shared library that were built in small mode, and prints their
+// sizes before and after, and compared to the last normal build of the same output, if there is one
+func stripOutputs(o *Options, buildDir string) error {
+	if isMSVC(o) || o.Wasm || o.Wasi || (o.Lib && !o.Shared) {
+		// MSVC keeps the debug info in .pdb files, and there is no strip for WebAssembly or for archives here
+		return nil
+	}
+	strip := stripCommand(o)
+	if strip == "" {
+		return fmt.Errorf("strip was not found, install it with: %s", installSuggestion(o.DetectedDistro, "binutils"))
+	}
+	history := readHistory(buildDir)
+	for _, out := range linkedOutputs(o) {
+		if !fileExists(out) {
+			continue
+		}
+		before := fileSize(out)
+		args := out
+		if o.Shared && onMacOS(o) {
+			// Keep the symbols that are needed for dynamic linking
+			args = "-x " + out
+		} else if o.Shared {
+			args = "--strip-unneeded " + out
+		}
+		if err := runCommand(strip+" "+args, o); err != nil {
+			return err
+		}
+		if o.DryRun {
+			continue
+		}
+		after := fileSize(out)
+		if after < before {
+			fmt.Printf("Size of %s: %s, %s before stripping\n", out, formatSize(after), formatSize(before))
+		} else {
+			fmt.Printf("Size of %s: %s\n", out, formatSize(after))
+		}
+		for i := len(history) - 1; i >= 0; i-- {
+			if r := history[i]; r.Success && r.Output == out && r.Mode == "normal" && r.Size > 0 {
+				fmt.Printf("Size of %s in the last normal build: %s (%+.1f%%)\n", out, formatSize(r.Size),
+					100*float64(after-r.Size)/float64(r.Size))
+				break
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package cxx
+
+import "fmt"
+
+// linkedOutputs returns the executables and the shared library that were linked, but not static libraries
+func linkedOutputs(o *Options) []string {
+	var outs []string
+	if (o.MainSource != "" && !o.Lib) || o.Shared {
+		outs = append(outs, o.OutputName)
+	}
+	return append(outs, targetOutputs(o)...)
+}
+
+// objcopyCommand returns the objcopy command for the outputs, or "" if there is none
+func objcopyCommand(o *Options) string {
+	switch {
+	case o.Win64Docker:
+		// Run in the container
+		return "x86_64-w64-mingw32-objcopy"
+	case o.Target != "" && haveCmd(o.Target+"-objcopy"):
+		return o.Target + "-objcopy"
+	case o.Target == "" && haveCmd("objcopy"):
+		return "objcopy"
+	case haveCmd("llvm-objcopy"):
+		return "llvm-objcopy"
+	}
+	return ""
+}
+
+// splitDebugInfo moves the debug info of the executables and the shared library into a .debug file next to each
+// of them, which the debuggers find through the debuglink that is added, or into a .dSYM bundle on macOS.
+// MSVC already writes the debug info to .pdb files.
+func splitDebugInfo(o *Options) error {
+	if isMSVC(o) || o.Wasm || o.Wasi {
+		return nil
+	}
+	for _, out := range linkedOutputs(o) {
+		if !o.DryRun && !fileExists(out) {
+			continue
+		}
+		var cmds []string
+		debug := out + ".debug"
+		if onMacOS(o) {
+			debug = out + ".dSYM"
+			cmds = []string{"dsymutil " + out + " -o " + debug, "strip -S " + out}
+		} else {
+			objcopy := objcopyCommand(o)
+			if objcopy == "" {
+				return fmt.Errorf("objcopy was not found, install it with: %s", installSuggestion(o.DetectedDistro, "binutils"))
+			}
+			cmds = []string{
+				objcopy + " --only-keep-debug " + out + " " + debug,
+				objcopy + " --strip-debug " + out,
+				objcopy + " --add-gnu-debuglink=" + debug + " " + out,
+			}
+		}
+		for _, cmd := range cmds {
+			if err := runCommand(cmd, o); err != nil {
+				return err
+			}
+		}
+		if !o.DryRun {
+			fmt.Printf("Wrote the debug info of %s to %s\n", out, debug)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package cxx
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// staticImage is the Alpine image with a C++ compiler that fully static executables are built in,
+// when there is no musl toolchain on the host
+const staticImage = "cxx2-static-alpine"
+
+// staticDockerfile is used for building the staticImage, the first time it is needed
+const staticDockerfile = "FROM alpine:latest\nRUN apk add --no-cache g++ linux-headers\n"
+
+// glibcStaticHeaders are headers for functions that glibc loads shared libraries for at runtime,
+// like getaddrinfo and getpwnam, which defeats the purpose of a static executable
+var glibcStaticHeaders = []string{"netdb.h", "pwd.h", "grp.h", "dlfcn.h", "iconv.h", "nss.h"}
+
+// onMusl checks if the C library of the host is musl, like on Alpine and Void musl
+func onMusl() bool {
+	matches, _ := filepath.Glob("/lib/ld-musl-*.so.1")
+	return len(matches) > 0
+}
+
+// haveStaticLibrary checks if the compiler can find the given static library, like libc.a
+func haveStaticLibrary(o *Options, lib string) bool {
+	out, err := compilerCommand(o, "-print-file-name="+lib).Output()
+	if err != nil {
+		return false
+	}
+	// Only the name is printed if the library was not found
+	return filepath.IsAbs(strings.TrimSpace(string(out)))
+}
+
+// glibcStaticProblem returns why linking statically with glibc would be a problem, or "" if it would be fine
+func glibcStaticProblem(o *Options) string {
+	for _, lib := range []string{"libc.a", "libstdc++.a"} {
+		if !haveStaticLibrary(o, lib) {
+			return lib + " is not installed"
+		}
+	}
+	for _, inc := range gatherAllIncludes(o, o.Sources) {
+		if contains(glibcStaticHeaders, inc) {
+			return "<" + inc + "> needs shared libraries at runtime with glibc"
+		}
+	}
+	return ""
+}
+
+// muslCompiler returns a musl compiler on the host, or "" if there is none.
+// musl-gcc only handles C, since it comes without a C++ standard library, so it is only used if there are no C++ sources.
+func muslCompiler(o *Options) string {
+	arch := map[string]string{"amd64": "x86_64", "arm64": "aarch64", "386": "i686", "riscv64": "riscv64"}[runtime.GOARCH]
+	if arch != "" && haveCmd(arch+"-linux-musl-g++") {
+		return arch + "-linux-musl-g++"
+	}
+	for _, s := range o.Sources {
+		if strings.ToLower(filepath.Ext(s)) != ".c" {
+			return ""
+		}
+	}
+	if haveCmd("musl-gcc") {
+		return "musl-gcc"
+	}
+	return ""
+}
+
+// setupStatic prepares a fully static build. With glibc, the build is done with a musl compiler,
+// or else in an Alpine container, if linking statically with glibc would be a problem.
+func setupStatic(o *Options) error {
+	if o.Shared {
+		return fmt.Errorf("a shared library can not be fully static")
+	}
+	o.ExtraLDFlags = append(o.ExtraLDFlags, "-static")
+	if o.Win64Docker || o.Target != "" || runtime.GOOS != "linux" || onMusl() || isZig(o) {
+		return nil
+	}
+	problem := glibcStaticProblem(o)
+	if problem == "" {
+		return nil
+	}
+	if cxx := muslCompiler(o); cxx != "" {
+		fmt.Printf("Building with %s, since %s\n", cxx, problem)
+		o.CXX = cxx
+		return nil
+	}
+	if haveContainerEngine() {
+		fmt.Printf("Building in an Alpine container, since %s\n", problem)
+		if err := buildImage(staticImage, staticDockerfile); err != nil {
+			return err
+		}
+		o.StaticDocker = true
+		o.CXX = "g++"
+		// The compiler cache and the linker of the host are not available inside the container
+		o.Launcher = ""
+		o.Linker = ""
+		return nil
+	}
+	fmt.Printf("Linking statically with glibc, even though %s. Install a musl toolchain, docker or podman to avoid this.\n", problem)
+	return nil
+}
+
+// verifyStatic checks that the given executable is statically linked, with file, or else with ldd
+func verifyStatic(exe string) error {
+	if out, err := exec.Command("file", "-L", exe).Output(); err == nil {
+		s := string(out)
+		if strings.Contains(s, "statically linked") || strings.Contains(s, "static-pie linked") {
+			fmt.Printf("%s is statically linked\n", exe)
+			return nil
+		}
+		if strings.Contains(s, "dynamically linked") {
+			return fmt.Errorf("%s is dynamically linked", exe)
+		}
+	}
+	out, _ := exec.Command("ldd", exe).CombinedOutput()
+	s := string(out)
+	if strings.Contains(s, "not a dynamic executable") || strings.Contains(s, "statically linked") {
+		fmt.Printf("%s is statically linked\n", exe)
+		return nil
+	}
+	if strings.Contains(s, "=>") {
+		return fmt.Errorf("%s is dynamically linked:\n%s", exe, strings.TrimSpace(s))
+	}
+	fmt.Printf("Could not check if %s is statically linked, file or ldd is needed\n", exe)
+	return nil
+}
+
+// verifyStaticOutputs checks that the executables that were built are statically linked
+func verifyStaticOutputs(o *Options) error {
+	if o.Win64Docker || o.Lib {
+		return nil
+	}
+	var exes []string
+	if o.MainSource != "" {
+		exes = append(exes, o.OutputName)
+	}
+	for _, t := range o.Targets {
+		exes = append(exes, t.Output)
+	}
+	for _, exe := range exes {
+		if err := verifyStatic(exe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package cxx
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// stdRx matches the names of the C and C++ standards, like c++20, gnu++2c, c99 and gnu17
+var stdRx = regexp.MustCompile(`^(c|gnu)(\+\+)?[0-9][0-9a-z]$`)
+
+// stdVersions are the first major versions of GCC and clang with adequate support for each standard, which
+// can be later than the first versions that accept its -std= flag, like GCC 4.7 for c++11 and 4.9 for c++14.
+// The gnu variants are looked up with the c prefix.
+var stdVersions = map[string][2]int{
+	"c++98": {4, 3}, "c++03": {4, 3}, "c++11": {5, 3}, "c++14": {5, 4}, "c++17": {7, 5},
+	"c++2a": {8, 6}, "c++20": {10, 10}, "c++2b": {11, 12}, "c++23": {11, 17}, "c++2c": {14, 17}, "c++26": {14, 17},
+	"c89": {4, 3}, "c90": {4, 3}, "c99": {4, 3}, "c11": {5, 3}, "c17": {8, 6}, "c18": {8, 6},
+	"c2x": {9, 9}, "c23": {14, 18}, "c2y": {15, 19},
+}
+
+// isStdName checks if the given argument is the name of a C or C++ standard
+func isStdName(s string) bool {
+	return stdRx.MatchString(s)
+}
+
+// isCStd checks if the given standard is a C standard, like c11 or gnu17, and not a C++ standard
+func isCStd(std string) bool {
+	return isStdName(std) && !strings.Contains(std, "++")
+}
+
+// setStd sets the C++ standard, or the C standard if it is one, from std=, --std= or the configuration file
+func setStd(o *Options, std string) error {
+	if !isStdName(std) {
+		return fmt.Errorf("%s is not a C or C++ standard, like c++17, c++20, c++23, c++2c, c99 or c17", std)
+	}
+	if isCStd(std) {
+		o.CStd = std
+	} else {
+		o.Std = std
+	}
+	return nil
+}
+
+// compilerMajorVersion returns the major version of the compiler, or 0 if it is not known
+func compilerMajorVersion(o *Options) int {
+	out, err := compilerCommand(o, "-dumpversion").Output()
+	if err != nil {
+		return 0
+	}
+	major, _, _ := strings.Cut(strings.TrimSpace(string(out)), ".")
+	n, _ := strconv.Atoi(major)
+	return n
+}
+
+// checkStd checks that the compiler is new enough for the selected standards. The compilers that do not
+// report a version that can be compared, or that run in a container, are not checked.
+func checkStd(o *Options) error {
+	if isMSVC(o) || isZig(o) || o.Wasm || o.Win64Docker || o.StaticDocker || (onMacOS(o) && isClang(o)) {
+		// Apple clang has versions of its own, and the rest either report no version or another one
+		return nil
+	}
+	major := 0
+	for _, std := range append(nonEmpty(o.Std), nonEmpty(o.CStd)...) {
+		key := std
+		if rest, ok := strings.CutPrefix(std, "gnu"); ok {
+			key = "c" + rest
+		}
+		minimum, ok := stdVersions[key]
+		if !ok {
+			// Newer than this table, which the compiler is left to complain about
+			continue
+		}
+		if major == 0 {
+			if major = compilerMajorVersion(o); major == 0 {
+				return nil
+			}
+		}
+		name, need := "GCC", minimum[0]
+		if isClang(o) {
+			name, need = "clang", minimum[1]
+		}
+		if major < need {
+			return fmt.Errorf("-std=%s needs %s %d or later, but %s is version %d, upgrade it or select an older standard with std=", std, name, need, o.CXX, major)
+		}
+	}
+	return nil
+}
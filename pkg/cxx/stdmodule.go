@@ -0,0 +1,199 @@
+package cxx
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// cCompatHeaders are the standard headers that come from C, which can not be used as header units
+var cCompatHeaders = []string{
+	"cassert", "ccomplex", "cctype", "cerrno", "cfenv", "cfloat", "cinttypes", "ciso646",
+	"climits", "clocale", "cmath", "cstdio", "cstdlib", "cstring", "ctime", "cwchar", "cwctype", "tgmath",
+}
+
+// stdModulesManifest is the modules.json file that libc++ and libstdc++ install next to the library,
+// that lists the sources of the std and std.compat modules
+type stdModulesManifest struct {
+	Modules []struct {
+		LogicalName  string `json:"logical-name"`
+		SourcePath   string `json:"source-path"`
+		IsStdLibrary bool   `json:"is-std-library"`
+	} `json:"modules"`
+}
+
+// stdModuleSources returns the sources of the std and std.compat modules of the standard library
+// that the compiler uses, keyed by module name, or nil if the standard library does not have them
+func stdModuleSources(o *Options) map[string]string {
+	if isMSVC(o) {
+		dir := filepath.Join(os.Getenv("VCToolsInstallDir"), "modules")
+		if !fileExists(filepath.Join(dir, "std.ixx")) {
+			return nil
+		}
+		return map[string]string{"std": filepath.Join(dir, "std.ixx"), "std.compat": filepath.Join(dir, "std.compat.ixx")}
+	}
+	name := "libstdc++.modules.json"
+	if isClang(o) {
+		name = "libc++.modules.json"
+	}
+	manifest := commandFirstLine(compilerCommand(o, "-print-file-name="+name))
+	if !filepath.IsAbs(manifest) || !fileExists(manifest) {
+		return nil
+	}
+	b, err := os.ReadFile(manifest)
+	if err != nil {
+		return nil
+	}
+	var m stdModulesManifest
+	if json.Unmarshal(b, &m) != nil {
+		return nil
+	}
+	srcs := map[string]string{}
+	for _, mod := range m.Modules {
+		if !mod.IsStdLibrary {
+			continue
+		}
+		src := mod.SourcePath
+		if !filepath.IsAbs(src) {
+			src = filepath.Join(filepath.Dir(manifest), src)
+		}
+		if fileExists(src) {
+			srcs[mod.LogicalName] = filepath.Clean(src)
+		}
+	}
+	return srcs
+}
+
+// setupImportStd is for import_std. If the sources import std or std.compat, the modules are built from
+// the sources that come with the standard library. Otherwise, with g++, the standard headers that are
+// included are built as header units, which g++ then imports instead of including them every time.
+func setupImportStd(o *Options, units map[string]*ModuleUnit) error {
+	imported := map[string]bool{}
+	for _, u := range units {
+		for _, imp := range u.Imports {
+			if imp == "std" || imp == "std.compat" {
+				imported[imp] = true
+			}
+		}
+	}
+	if len(imported) > 0 {
+		srcs := stdModuleSources(o)
+		if srcs == nil {
+			return fmt.Errorf("import std is not supported by the standard library of %s", o.CXX)
+		}
+		var added []string
+		for name := range imported {
+			src, ok := srcs[name]
+			if !ok {
+				return fmt.Errorf("the standard library of %s does not have the %s module", o.CXX, name)
+			}
+			units[src] = &ModuleUnit{Provides: name, Interface: true, StdLibrary: true}
+			if name == "std.compat" {
+				// std.compat imports std
+				units[src].Imports = []string{"std"}
+				if _, ok := units[srcs["std"]]; !ok {
+					units[srcs["std"]] = &ModuleUnit{Provides: "std", Interface: true, StdLibrary: true}
+					added = append(added, srcs["std"])
+				}
+			}
+			added = append(added, src)
+		}
+		sort.Strings(added)
+		o.Sources = append(added, o.Sources...)
+		return nil
+	}
+	if isClang(o) || isMSVC(o) {
+		fmt.Println("Standard header units are only built with g++, add import std; to the sources instead")
+		return nil
+	}
+	var headers []string
+	for _, inc := range gatherAllIncludes(o, o.Sources) {
+		if isHeaderUnit(inc) {
+			headers = append(headers, inc)
+		}
+	}
+	sort.Strings(headers)
+	o.HeaderUnits = resolveStdHeaders(o, headers)
+	return nil
+}
+
+// isHeaderUnit checks if the given include is a standard C++ header, that can be built as a header unit
+func isHeaderUnit(inc string) bool {
+	return contains(stdIncludesSkipList, inc) && !contains(cCompatHeaders, inc)
+}
+
+// resolveStdHeaders returns the paths of the given standard headers, as found by the compiler,
+// keyed by header name
+func resolveStdHeaders(o *Options, headers []string) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	var src strings.Builder
+	for _, h := range headers {
+		fmt.Fprintf(&src, "#include <%s>\n", h)
+	}
+	args := []string{"-x", "c++", "-E", "-H", "-", "-o", os.DevNull}
+	if o.Std != "" {
+		args = append(args, "-std="+o.Std)
+	}
+	cmd := compilerCommand(o, args...)
+	cmd.Stdin = strings.NewReader(src.String())
+	// -H lists the included headers on stderr, the ones that are included directly with a single dot
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil
+	}
+	paths := map[string]string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		p, ok := strings.CutPrefix(line, ". ")
+		if !ok {
+			continue
+		}
+		for _, h := range headers {
+			if filepath.Base(p) == h {
+				paths[h] = filepath.Clean(p)
+			}
+		}
+	}
+	return paths
+}
+
+// headerUnitPath returns the compiled header unit for the given standard header
+func headerUnitPath(o *Options, header string) string {
+	return filepath.Join(moduleDir(o), "std", header+".gcm")
+}
+
+// buildHeaderUnits builds the standard headers that are included into header units, with the same flags
+// as the sources are compiled with. They are built again when the flags change.
+func buildHeaderUnits(o *Options) error {
+	if len(o.HeaderUnits) == 0 {
+		return nil
+	}
+	if err := makeDir(o, filepath.Join(moduleDir(o), "std")); err != nil {
+		return err
+	}
+	sf := ""
+	if o.Std != "" {
+		sf = "-std=" + o.Std
+	}
+	flags := joinNonEmpty([]string{o.CXX, sf, compileFlags(o), joinExtraCFlags(o.ExtraCFlags), moduleFlags(o, "")})
+	stamp := filepath.Join(moduleDir(o), "std", "flags")
+	old, _ := os.ReadFile(stamp)
+	rebuild := string(old) != flags
+	headers := make([]string, 0, len(o.HeaderUnits))
+	for h := range o.HeaderUnits {
+		headers = append(headers, h)
+	}
+	sort.Strings(headers)
+	for _, h := range headers {
+		if rebuild || !fileExists(headerUnitPath(o, h)) {
+			if err := runCommand(flags+" -x c++-system-header "+h, o); err != nil {
+				return err
+			}
+		}
+	}
+	return writeFile(o, stamp, []byte(flags))
+}
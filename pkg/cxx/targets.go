@@ -0,0 +1,135 @@
+package cxx
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// targetParents are directories where every subdirectory with a main function is its own executable
+var targetParents = []string{"cmd", "tools"}
+
+// targetBinDir is where the executables of a multi-target project are placed
+const targetBinDir = "bin"
+
+// Target is an executable built from the sources in its own directory,
+// linked together with the shared sources from the rest of the project
+type Target struct {
+	Name    string
+	Dir     string
+	Sources []string
+	Output  string
+}
+
+// discoverTargets looks for subdirectories that contain a main function, like cmd/foo and cmd/bar.
+// Multiple targets are used if such directories are found below one of the targetParents,
+// or if at least two subdirectories have a main function.
+func discoverTargets(srcs []string, docker bool) []*Target {
+	dirs := map[string]bool{}
+	underParent := false
+	for _, s := range srcs {
+		dir := filepath.ToSlash(filepath.Dir(s))
+		if dir == "." || isTestSource(s) || !hasMainFunction(s) {
+			continue
+		}
+		dirs[dir] = true
+		first, _, _ := strings.Cut(dir, "/")
+		if contains(targetParents, first) {
+			underParent = true
+		}
+	}
+	if len(dirs) < 2 && !underParent {
+		return nil
+	}
+	var targets []*Target
+	for dir := range dirs {
+		name := filepath.Base(dir)
+		targets = append(targets, &Target{
+			Name:   name,
+			Dir:    dir,
+			Output: filepath.Join(targetBinDir, ensureExeSuffix(name, docker)),
+		})
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Dir < targets[j].Dir })
+	for _, s := range srcs {
+		if t := findTarget(targets, s); t != nil && !isTestSource(s) {
+			t.Sources = append(t.Sources, s)
+		}
+	}
+	return targets
+}
+
+// findTarget returns the target with the most specific directory that contains the given source
+func findTarget(targets []*Target, src string) *Target {
+	src = filepath.ToSlash(src)
+	var found *Target
+	for _, t := range targets {
+		if strings.HasPrefix(src, t.Dir+"/") && (found == nil || len(t.Dir) > len(found.Dir)) {
+			found = t
+		}
+	}
+	return found
+}
+
+// targetOf returns the target that the given source belongs to, or nil if it is shared
+func targetOf(o *Options, src string) *Target {
+	return findTarget(o.Targets, src)
+}
+
+// sharedSources returns the sources that do not belong to any target
+func sharedSources(o *Options, srcs []string) []string {
+	var out []string
+	for _, s := range srcs {
+		if targetOf(o, s) == nil {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func targetOutputs(o *Options) []string {
+	var out []string
+	for _, t := range o.Targets {
+		out = append(out, t.Output)
+	}
+	return out
+}
+
+// buildTargets compiles the shared sources once, and then links one executable per target,
+// plus the primary executable if there is a main function among the shared sources
+func buildTargets(o *Options, cc *CompileCache) error {
+	// Compile everything in one go, for the most parallelism
+	var shared, all []string
+	for _, s := range o.Sources {
+		if isTestSource(s) {
+			continue
+		}
+		all = append(all, s)
+		if s != o.MainSource && targetOf(o, s) == nil {
+			shared = append(shared, objectPath(o, s))
+		}
+	}
+	if _, e := compileAll(o, cc, all); e != nil {
+		return e
+	}
+	if o.MainSource != "" {
+		on := ensureExeSuffix(o.OutputName, o.Win64Docker)
+		if e := linkObjects(o, append([]string{objectPath(o, o.MainSource)}, shared...), on); e != nil {
+			return e
+		}
+		o.OutputName = on
+	}
+	if e := makeDir(o, targetBinDir); e != nil {
+		return e
+	}
+	for _, t := range o.Targets {
+		var objs []string
+		for _, s := range t.Sources {
+			objs = append(objs, objectPath(o, s))
+		}
+		if e := linkObjects(o, append(objs, shared...), t.Output); e != nil {
+			return e
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sync/atomic"
+)
+
+// ANSI escape sequences for the status lines at a terminal
+const (
+	ansiReset = "\033[0m"
+	ansiBold  = "\033[1m"
+	ansiDim   = "\033[2m"
+	ansiGreen = "\033[32m"
+	ansiCyan  = "\033[36m"
+)
+
+// ansiRx matches ANSI escape sequences, like the colors in compiler diagnostics
+var ansiRx = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// stripANSI removes the ANSI escape sequences from the given output
+func stripANSI(s string) string {
+	return ansiRx.ReplaceAllString(s, "")
+}
+
+// stdoutIsTerminal checks if stdout is a terminal that supports colors
+func stdoutIsTerminal() bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// fancyOutput checks if the output is for someone at a terminal, and then status lines with colors are shown
+// instead of the commands, unless --verbose is given. When piped, the commands are shown as they are.
+func fancyOutput(o *Options) bool {
+	return !o.Verbose && !o.DryRun && stdoutIsTerminal()
+}
+
+// colorize returns the given text in the given color, if the output is for a terminal
+func colorize(o *Options, color, s string) string {
+	if !fancyOutput(o) {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// echoCommand prints the given command line, dimmed at a terminal, unless --quiet is given
+func echoCommand(o *Options, line string) {
+	if o.Quiet || o.DryRun {
+		return
+	}
+	fmt.Println(colorize(o, ansiDim, line))
+}
+
+// status prints what is being done at a terminal, like "Linking main", unless --quiet is given
+func status(o *Options, action, subject string) {
+	if o.Quiet {
+		return
+	}
+	fmt.Println(colorize(o, ansiBold+ansiGreen, action), subject)
+}
+
+// whyf prints why a source is compiled or not, with --why or --verbose
+func whyf(o *Options, format string, args ...any) {
+	if o.Why {
+		fmt.Printf(format, args...)
+	} else {
+		verbosef(o, format, args...)
+	}
+}
+
+// verbosef prints details about the decisions that are made during the build, with --verbose
+func verbosef(o *Options, format string, args ...any) {
+	if o.Verbose && !o.Quiet {
+		fmt.Print(colorize(o, ansiDim, fmt.Sprintf(format, args...)))
+	}
+}
+
+// compileProgress counts the compilations of a build, for the [n/total] counter
+type compileProgress struct {
+	done  atomic.Int32
+	total int
+}
+
+// next returns the counter for the next compilation
+func (p *compileProgress) next() string {
+	n := int(p.done.Add(1))
+	return fmt.Sprintf("[%d/%d]", n, max(n, p.total))
+}
+
+// diagnosticsColorFlag returns the flag that keeps the colors of the diagnostics from GCC and Clang,
+// which are captured and then passed through, or "" if the output is not for a terminal
+func diagnosticsColorFlag(o *Options, src string) string {
+	if !fancyOutput(o) || isMSVC(o) || isCUDASource(src) || isNASMSource(src) || isResourceSource(src) {
+		return ""
+	}
+	return "-fdiagnostics-color=always"
+}
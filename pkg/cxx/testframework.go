@@ -0,0 +1,187 @@
+package cxx
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The test frameworks that are recognized in test sources
+const (
+	frameworkGoogleTest = "gtest"
+	frameworkCatch2     = "catch2"
+	frameworkDoctest    = "doctest"
+)
+
+// catchSummaryRx matches the totals that both Catch2 and doctest print when tests fail, and that doctest always prints
+var catchSummaryRx = regexp.MustCompile(`(?m)test cases:\s*(\d+) \|\s*(\d+) passed \|\s*(\d+) failed`)
+
+// catchPassedRx matches what Catch2 prints when all tests passed
+var catchPassedRx = regexp.MustCompile(`(?m)^All tests passed \(\d+ assertions? in (\d+) test cases?\)`)
+
+// testFramework returns the test framework that the given test source includes, and the included header,
+// or empty strings if none is recognized
+func testFramework(o *Options, src string) (string, string) {
+	for _, inc := range discoverIncludes(o, src) {
+		switch {
+		case inc == "gtest/gtest.h":
+			return frameworkGoogleTest, inc
+		case inc == "catch.hpp" || filepath.Dir(inc) == "catch2":
+			return frameworkCatch2, inc
+		case inc == "doctest.h" || inc == "doctest/doctest.h":
+			return frameworkDoctest, inc
+		}
+	}
+	return "", ""
+}
+
+// testMainDefine returns the macro that makes the given header of a single header test framework define main,
+// or "" if the framework provides main with a library instead. Catch2 v3 headers are not single headers,
+// and main is linked in from pkg-config's catch2-with-main.
+func testMainDefine(framework, header string) string {
+	switch {
+	case framework == frameworkCatch2 && filepath.Base(header) == "catch.hpp":
+		return "CATCH_CONFIG_MAIN"
+	case framework == frameworkDoctest:
+		return "DOCTEST_CONFIG_IMPLEMENT_WITH_MAIN"
+	}
+	return ""
+}
+
+// testMain returns where the generated source that defines main for the given test source is placed in the
+// build directory, and its contents, or "" if the test source defines main itself or does not use a single
+// header test framework
+func testMain(o *Options, src string) (string, string) {
+	framework, header := testFramework(o, src)
+	define := testMainDefine(framework, header)
+	if define == "" || hasMainFunction(src) || definesTestMain(src, define) {
+		return "", ""
+	}
+	return filepath.Join(o.BuildDir, "testmain", framework+"_main.cpp"), "#define " + define + "\n#include \"" + header + "\"\n"
+}
+
+// writeTestMain writes the generated source that defines main for a single header test framework.
+// It is only written if changed, so that it is not rebuilt.
+func writeTestMain(o *Options, path, contents string) error {
+	if b, err := os.ReadFile(path); err == nil && string(b) == contents {
+		return nil
+	}
+	if err := makeDir(o, filepath.Dir(path)); err != nil {
+		return err
+	}
+	return writeFile(o, path, []byte(contents))
+}
+
+// definesTestMain checks if the given test source defines the given macro for making the test framework define main
+func definesTestMain(src, define string) bool {
+	b, err := os.ReadFile(src)
+	return err == nil && bytes.Contains(b, []byte("#define "+define))
+}
+
+// testFilterArgs returns the arguments that make a test executable that uses the given framework
+// only run the tests that match the given filter
+func testFilterArgs(framework, filter string) []string {
+	switch framework {
+	case frameworkGoogleTest:
+		return []string{"--gtest_filter=" + filter}
+	case frameworkDoctest:
+		return []string{"--test-case=" + filter}
+	case frameworkCatch2:
+		// A test spec
+		return []string{filter}
+	}
+	return nil
+}
+
+// setupTestFrameworks adds the include directories that are needed when test sources include catch.hpp
+// or doctest.h directly, while the system installs them in a catch2/ or doctest/ subdirectory
+func setupTestFrameworks(o *Options) {
+	for _, s := range o.TestSources {
+		framework, header := testFramework(o, s)
+		if header != "catch.hpp" && header != "doctest.h" || headerFound(o, header) {
+			continue
+		}
+		sub := "catch2"
+		if framework == frameworkDoctest {
+			sub = "doctest"
+		}
+		for _, d := range o.SystemIncludeDirs {
+			if dir := filepath.Join(d, sub); fileExists(filepath.Join(dir, header)) && !contains(o.IncludeDirs, dir) {
+				o.IncludeDirs = append(o.IncludeDirs, dir)
+			}
+		}
+	}
+}
+
+// headerFound checks if the given header is found in one of the include directories
+func headerFound(o *Options, header string) bool {
+	for _, d := range append(append([]string{}, o.IncludeDirs...), o.SystemIncludeDirs...) {
+		if fileExists(filepath.Join(d, header)) {
+			return true
+		}
+	}
+	return false
+}
+
+// testNameMatches checks if the name of the given test source, without the extension, contains the given filter
+// or matches it as a glob pattern
+func testNameMatches(src, filter string) bool {
+	name := strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	return strings.Contains(name, filter) || globMatch(filter, name)
+}
+
+// selectTests returns the test sources that were given on the command line, or all of them, and leaves out
+// the ones that do not match the filter. Tests that use a known framework are kept, since the filter is
+// passed on to them for selecting test cases.
+func selectTests(o *Options) ([]string, error) {
+	tests := o.TestSources
+	if len(o.TestPaths) > 0 {
+		tests = nil
+		for _, p := range o.TestPaths {
+			found := false
+			for _, s := range o.TestSources {
+				if filepath.Clean(s) == p {
+					tests = append(tests, s)
+					found = true
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("%s is not one of the test sources", p)
+			}
+		}
+	}
+	if o.TestFilter == "" {
+		return tests, nil
+	}
+	var selected []string
+	for _, s := range tests {
+		if framework, _ := testFramework(o, s); framework != "" || testNameMatches(s, o.TestFilter) {
+			selected = append(selected, s)
+		}
+	}
+	return selected, nil
+}
+
+// testCounts returns the number of passed and failed tests from the output of a test executable that uses
+// the given framework, and false if the output has no summary
+func testCounts(framework, output string) (passed, failed int, ok bool) {
+	switch framework {
+	case frameworkGoogleTest:
+		return gtestCounts(output)
+	case frameworkCatch2, frameworkDoctest:
+		if m := catchSummaryRx.FindStringSubmatch(output); m != nil {
+			passed, _ = strconv.Atoi(m[2])
+			failed, _ = strconv.Atoi(m[3])
+			return passed, failed, true
+		}
+		if m := catchPassedRx.FindStringSubmatch(output); m != nil {
+			passed, _ = strconv.Atoi(m[1])
+			return passed, 0, true
+		}
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,184 @@
+package cxx
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// testRun is a test executable that is about to be run
+type testRun struct {
+	exe       string
+	framework string
+	args      []string
+}
+
+// parseTimeout parses a timeout like 30s or 2m, or a number of seconds
+func parseTimeout(s string) (time.Duration, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return time.Duration(n) * time.Second, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout: %s", s)
+	}
+	return d, nil
+}
+
+// runWithTimeout runs the given command and kills it if it runs for longer than the given timeout, if it is not 0
+func runWithTimeout(cmd *exec.Cmd, timeout time.Duration) error {
+	if timeout <= 0 {
+		return cmd.Run()
+	}
+	// Processes that were started by the command, and that still hold on to its output, are not waited for
+	cmd.WaitDelay = time.Second
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	timer := time.AfterFunc(timeout, func() {
+		cmd.Process.Kill()
+	})
+	err := cmd.Wait()
+	if !timer.Stop() {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	return err
+}
+
+// printTAPResult prints the result of a test as a Test Anything Protocol test line, with the output of the test
+// as diagnostics
+func printTAPResult(n int, r testRun, failed bool, details string, output []byte) {
+	status := "ok"
+	if failed {
+		status = "not ok"
+	}
+	fmt.Printf("%s %d - %s # %s\n", status, n, r.exe, strings.ReplaceAll(details, "#", ""))
+	for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+		if line != "" {
+			fmt.Println("#", line)
+		}
+	}
+}
+
+// testResult is the outcome of running a test executable once
+type testResult struct {
+	passed, failed int
+	counted        bool // if the test framework reported the counts
+	output         []byte
+	err            error
+	elapsed        time.Duration
+}
+
+// runTest runs the given test executable once and captures its output
+func runTest(o *Options, r testRun) testResult {
+	var out bytes.Buffer
+	start := time.Now()
+	err := runProgramTo(o, r.exe, r.args, &out, &out, o.TestTimeout)
+	res := testResult{output: out.Bytes(), err: err, elapsed: time.Since(start)}
+	res.passed, res.failed, res.counted = testCounts(r.framework, out.String())
+	if err != nil && res.failed == 0 {
+		// Crashed, possibly before the summary was written
+		res.failed = 1
+	} else if !res.counted && err == nil {
+		res.passed = 1
+	}
+	return res
+}
+
+// runTests runs the given test executables, with up to o.Jobs of them running in parallel. The output of each
+// test is captured and printed in one piece when it is done, after a PASS or FAIL line, followed by a summary.
+// With --tap, the results are printed as Test Anything Protocol instead, numbered in the order they finished.
+// Failing tests are run again up to o.Retries times, and the ones that pass on a retry are reported as flaky.
+func runTests(o *Options, runs []testRun) error {
+	if o.DryRun {
+		for _, r := range runs {
+			if err := runProgramTo(o, r.exe, r.args, os.Stdout, os.Stderr, o.TestTimeout); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var (
+		mu             sync.Mutex
+		wg             sync.WaitGroup
+		passed, failed int
+		failing, flaky []string
+	)
+	if o.TAP {
+		fmt.Println("TAP version 13")
+		fmt.Printf("1..%d\n", len(runs))
+	}
+	done := 0
+	sem := make(chan struct{}, max(o.Jobs, 1))
+	for _, r := range runs {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			res := runTest(o, r)
+			attempts := 1
+			for res.failed > 0 && attempts <= o.Retries {
+				res = runTest(o, r)
+				attempts++
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			passed += res.passed
+			failed += res.failed
+			status := "PASS"
+			if res.failed > 0 {
+				status = "FAIL"
+				failing = append(failing, r.exe)
+			} else if attempts > 1 {
+				status = "FLAKY"
+				flaky = append(flaky, r.exe)
+			}
+			details := fmt.Sprintf("%.2fs", res.elapsed.Seconds())
+			if r.framework != "" && res.counted {
+				details += fmt.Sprintf(", %d passed, %d failed", res.passed, res.failed)
+			}
+			if res.err != nil {
+				details += ", " + res.err.Error()
+			}
+			if attempts > 1 {
+				details += fmt.Sprintf(", attempt %d of %d", attempts, o.Retries+1)
+			}
+			done++
+			if o.TAP {
+				printTAPResult(done, r, res.failed > 0, details, res.output)
+				return
+			}
+			fmt.Printf("--- %s: %s (%s)\n", status, r.exe, details)
+			if len(res.output) > 0 {
+				os.Stdout.Write(res.output)
+				if !bytes.HasSuffix(res.output, []byte("\n")) {
+					fmt.Println()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if o.TAP {
+		fmt.Print("# ")
+	}
+	if len(flaky) > 0 {
+		sort.Strings(flaky)
+		fmt.Printf("Tests: %d passed, %d failed, %d flaky: %s\n", passed, failed, len(flaky), strings.Join(flaky, ", "))
+	} else {
+		fmt.Printf("Tests: %d passed, %d failed\n", passed, failed)
+	}
+	if failed > 0 {
+		sort.Strings(failing)
+		return fmt.Errorf("failing tests in %s", strings.Join(failing, ", "))
+	}
+	return nil
+}
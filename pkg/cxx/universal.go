@@ -0,0 +1,88 @@
+package cxx
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// universalArchs are the architectures of a macOS universal binary
+var universalArchs = []string{"arm64", "x86_64"}
+
+// setupUniversal checks that a universal binary can be built
+func setupUniversal(o *Options) error {
+	if !o.Universal {
+		return nil
+	}
+	if !onMacOS(o) {
+		return fmt.Errorf("universal binaries can only be built on macOS")
+	}
+	if o.Coverage || o.PGO != "" {
+		return fmt.Errorf("coverage and PGO are not supported for universal binaries")
+	}
+	if !haveCmd("lipo") {
+		return fmt.Errorf("lipo was not found, install the Xcode command line tools with: xcode-select --install")
+	}
+	return nil
+}
+
+// archOptions returns a copy of the options for building for one of the universalArchs,
+// with the objects, the cache and the outputs in a build directory of its own
+func archOptions(o *Options, arch string) *Options {
+	a := *o
+	a.BuildDir = filepath.Join(o.BuildDir, arch)
+	a.ExtraCFlags = append(append([]string{}, o.ExtraCFlags...), "-arch", arch)
+	a.ExtraLDFlags = append(append([]string{}, o.ExtraLDFlags...), "-arch", arch)
+	if o.OutputName != "" {
+		a.OutputName = filepath.Join(a.BuildDir, filepath.Base(o.OutputName))
+	}
+	a.Targets = make([]*Target, len(o.Targets))
+	for i, t := range o.Targets {
+		c := *t
+		c.Output = filepath.Join(a.BuildDir, t.Output)
+		a.Targets[i] = &c
+	}
+	return &a
+}
+
+// buildUniversal builds everything once for each of the universalArchs,
+// and then merges the outputs into universal binaries with lipo
+func buildUniversal(o *Options, normalSources, testSources []string) error {
+	thin := map[string][]string{}
+	for _, arch := range universalArchs {
+		a := archOptions(o, arch)
+		if err := prepareBuildDir(a); err != nil {
+			return err
+		}
+		if a.Force {
+			forceRebuild(a)
+		}
+		cc, _ := loadCache(a)
+		if err := buildGitDependencies(a, cc); err != nil {
+			return fmt.Errorf("%s: %w", arch, err)
+		}
+		if err := buildOutputs(a, cc, normalSources, testSources); err != nil {
+			return fmt.Errorf("%s: %w", arch, err)
+		}
+		if o.Lib || o.MainSource != "" {
+			thin[o.OutputName] = append(thin[o.OutputName], a.OutputName)
+		}
+		for i, t := range a.Targets {
+			thin[o.Targets[i].Output] = append(thin[o.Targets[i].Output], t.Output)
+		}
+	}
+	for out, files := range thin {
+		if dir := filepath.Dir(out); dir != "." {
+			if err := makeDir(o, dir); err != nil {
+				return err
+			}
+		}
+		if err := runCommand(fmt.Sprintf("lipo -create -output %s %s", out, strings.Join(files, " ")), o); err != nil {
+			return err
+		}
+	}
+	if o.Shared {
+		return linkSharedLibraryNames(o)
+	}
+	return nil
+}
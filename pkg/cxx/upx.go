@@ -0,0 +1,46 @@
+package cxx
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// compressOutputs compresses the executables with upx, for --upx, and prints how much smaller they became.
+// Not having upx, or an executable that upx can not compress, is not an error.
+func compressOutputs(o *Options) {
+	if !o.Opt && !o.Small {
+		fmt.Println("Not compressing with upx, since it is only used for opt and small builds")
+		return
+	}
+	if o.Lib || o.Wasm || o.Wasi {
+		fmt.Println("Not compressing with upx, since it only compresses executables")
+		return
+	}
+	if !haveCmd("upx") {
+		fmt.Printf("Not compressing with upx, since it is not installed, install it with: %s\n", installSuggestion(o.DetectedDistro, "upx"))
+		return
+	}
+	for _, exe := range linkedOutputs(o) {
+		// upx runs on the host, also for executables that were built in a container
+		args := []string{"-qq", "--best", exe}
+		if o.DryRun {
+			fmt.Println("upx", strings.Join(args, " "))
+			continue
+		}
+		if !fileExists(exe) {
+			continue
+		}
+		echoCommand(o, "upx "+strings.Join(args, " "))
+		before := fileSize(exe)
+		if out, err := exec.Command("upx", args...).CombinedOutput(); err != nil {
+			// Like for unsupported formats, or executables that are already compressed
+			lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+			fmt.Printf("Not compressing %s with upx: %s\n", exe, strings.TrimSpace(lines[len(lines)-1]))
+			continue
+		}
+		after := fileSize(exe)
+		fmt.Printf("Compressed %s with upx: %s -> %s (%.0f%%)\n", exe, formatSize(before), formatSize(after),
+			100*float64(after)/float64(before))
+	}
+}
@@ -0,0 +1,81 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	valgrindErrors     = regexp.MustCompile(`ERROR SUMMARY: ([\d,]+) errors?`)
+	valgrindDefinitely = regexp.MustCompile(`definitely lost: ([\d,]+) bytes in ([\d,]+) blocks?`)
+)
+
+// valgrindLogPattern returns the pattern for the valgrind logs of the given executable, in the build directory.
+// %p is replaced with the process ID by valgrind, so that MPI processes get logs of their own.
+func valgrindLogPattern(o *Options, exe string) string {
+	return filepath.Join(o.BuildDir, "valgrind", filepath.Base(exe)+".%p.log")
+}
+
+// valgrindCommand returns the command for running the given command under valgrind memcheck,
+// with a full leak check and origins of uninitialized values
+func valgrindCommand(o *Options, cmd *exec.Cmd) (*exec.Cmd, error) {
+	if !haveCmd("valgrind") {
+		return nil, fmt.Errorf("valgrind was not found, install it with: %s", installSuggestion(o.DetectedDistro, "valgrind"))
+	}
+	if len(cmd.Args) > 1 {
+		// Run with node, wine or wasmtime
+		return nil, fmt.Errorf("valgrind can only run native executables")
+	}
+	pattern := valgrindLogPattern(o, cmd.Args[0])
+	if err := makeDir(o, filepath.Dir(pattern)); err != nil {
+		return nil, err
+	}
+	old, _ := filepath.Glob(strings.Replace(pattern, "%p", "*", 1))
+	for _, f := range old {
+		removeFile(o, f)
+	}
+	args := []string{"--tool=memcheck", "--leak-check=full", "--show-leak-kinds=definite,possible",
+		"--errors-for-leak-kinds=definite", "--track-origins=yes", "--num-callers=30", "--log-file=" + pattern}
+	return exec.Command("valgrind", append(args, cmd.Args...)...), nil
+}
+
+// checkValgrindLogs prints the logs from running the given executable under valgrind, and returns an error
+// if memcheck found invalid memory accesses, uses of uninitialized values or memory that was definitely lost
+func checkValgrindLogs(o *Options, exe string) error {
+	logs, _ := filepath.Glob(strings.Replace(valgrindLogPattern(o, exe), "%p", "*", 1))
+	if len(logs) == 0 {
+		return fmt.Errorf("valgrind did not write a log for %s", exe)
+	}
+	errs, lost := 0, 0
+	for _, f := range logs {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		log := string(b)
+		if m := valgrindErrors.FindStringSubmatch(log); m != nil {
+			n, _ := strconv.Atoi(strings.ReplaceAll(m[1], ",", ""))
+			errs += n
+		}
+		if m := valgrindDefinitely.FindStringSubmatch(log); m != nil {
+			n, _ := strconv.Atoi(strings.ReplaceAll(m[1], ",", ""))
+			lost += n
+		}
+		if errs > 0 {
+			fmt.Print(log)
+		}
+	}
+	if errs == 0 {
+		fmt.Println("valgrind: no memory errors and no leaks were found in", exe)
+		return nil
+	}
+	if lost > 0 {
+		return fmt.Errorf("valgrind found %d error(s) in %s, and %d bytes that were definitely lost", errs, exe, lost)
+	}
+	return fmt.Errorf("valgrind found %d error(s) in %s", errs, exe)
+}
@@ -0,0 +1,111 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	// vcpkgManifest is the file that lists the vcpkg dependencies of a project
+	vcpkgManifest = "vcpkg.json"
+
+	// vcpkgInstallRoot is where vcpkg installs the dependencies of a manifest, relative to the project
+	vcpkgInstallRoot = "vcpkg_installed"
+
+	// vcpkgStamp is written after a successful vcpkg install, so that it only runs again when the manifest changes
+	vcpkgStamp = ".cxx2-stamp"
+)
+
+// vcpkgTriplet returns the vcpkg triplet to use, from $VCPKG_DEFAULT_TRIPLET or the current platform
+func vcpkgTriplet(o *Options) string {
+	if t := os.Getenv("VCPKG_DEFAULT_TRIPLET"); t != "" {
+		return t
+	}
+	if o.Win64Docker {
+		return "x64-mingw-static"
+	}
+	if o.Wasm {
+		return "wasm32-emscripten"
+	}
+	goarch := targetArch(o)
+	arch := map[string]string{"amd64": "x64", "386": "x86", "arm64": "arm64", "arm": "arm"}[goarch]
+	if arch == "" {
+		arch = goarch
+	}
+	system := runtime.GOOS
+	if system == "darwin" {
+		system = "osx"
+	}
+	return arch + "-" + system
+}
+
+// vcpkgCommand returns the vcpkg executable in $VCPKG_ROOT or in PATH, or "" if vcpkg is not installed
+func vcpkgCommand() string {
+	if root := os.Getenv("VCPKG_ROOT"); root != "" {
+		if p := filepath.Join(root, "vcpkg"); fileExists(p) {
+			return p
+		}
+	}
+	if p, err := exec.LookPath("vcpkg"); err == nil {
+		return p
+	}
+	return ""
+}
+
+// vcpkgInstall runs vcpkg install for the manifest of the project, unless it is unchanged since the last install
+func vcpkgInstall(o *Options) error {
+	stamp := filepath.Join(vcpkgInstallRoot, vcpkgStamp)
+	if stampIsNewer(stamp, vcpkgManifest) {
+		return nil
+	}
+	vcpkg := vcpkgCommand()
+	if vcpkg == "" {
+		return fmt.Errorf("found %s, but vcpkg is not installed and VCPKG_ROOT is not set", vcpkgManifest)
+	}
+	// vcpkg runs on the host, also when building with Docker
+	args := []string{"install", "--triplet=" + vcpkgTriplet(o)}
+	fmt.Println(vcpkg, strings.Join(args, " "))
+	if o.DryRun {
+		return nil
+	}
+	c := exec.Command(vcpkg, args...)
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return err
+	}
+	return writeFile(o, stamp, nil)
+}
+
+// setupVcpkg installs the dependencies of a vcpkg.json manifest and adds the include and library
+// directories of the installed triplet. Without a manifest, the packages that are installed in
+// $VCPKG_ROOT are used, if it is set. The .pc files of the installed packages are made
+// available to pkg-config, so that the required -l flags are found for the included headers.
+func setupVcpkg(o *Options) error {
+	var installed string
+	if fileExists(vcpkgManifest) {
+		if err := vcpkgInstall(o); err != nil {
+			return err
+		}
+		installed = filepath.Join(vcpkgInstallRoot, vcpkgTriplet(o))
+	} else if root := os.Getenv("VCPKG_ROOT"); root != "" {
+		installed = filepath.Join(root, "installed", vcpkgTriplet(o))
+	}
+	if installed == "" || !dirExists(installed) {
+		return nil
+	}
+	addPackagePrefix(o, installed)
+	pc, err := filepath.Abs(filepath.Join(installed, "lib", "pkgconfig"))
+	if err != nil || !dirExists(pc) {
+		return nil
+	}
+	paths := filepath.SplitList(os.Getenv("PKG_CONFIG_PATH"))
+	if !contains(paths, pc) {
+		os.Setenv("PKG_CONFIG_PATH", strings.Join(append([]string{pc}, paths...), string(os.PathListSeparator)))
+	}
+	return nil
+}
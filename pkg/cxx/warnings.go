@@ -0,0 +1,81 @@
+package cxx
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// WarningProfile is a named set of changes to the warning flags, from a [warnings.NAME] table:
+//
+//	[warnings.gamejam]
+//	remove = ["-Wshadow", "-Wpedantic"]
+//	add = ["-Wno-unused-parameter"]
+//
+// The flags in remove are taken out of the flags that would otherwise be used, then the flags in add are added.
+type WarningProfile struct {
+	Add    []string
+	Remove []string
+}
+
+// warningProfiles returns the warning profiles in the [warnings.NAME] tables
+func warningProfiles(tables map[string]map[string]any) map[string]WarningProfile {
+	profiles := make(map[string]WarningProfile)
+	for name, t := range tables {
+		if name, ok := strings.CutPrefix(name, "warnings."); ok {
+			profiles[name] = WarningProfile{Add: configStrings(t, "add"), Remove: configStrings(t, "remove")}
+		}
+	}
+	return profiles
+}
+
+// resolveWarnings checks that the profile given with --warnings=, or with warnings = in the configuration file,
+// is defined. strict and sloppy can also be given, for the modes of the same name, unless they are redefined.
+func resolveWarnings(o *Options) error {
+	if o.Warnings == "" {
+		return nil
+	}
+	if _, ok := warningProfile(o); ok {
+		return nil
+	}
+	switch o.Warnings {
+	case "strict":
+		o.Strict = true
+		return nil
+	case "sloppy":
+		o.Sloppy = true
+		return nil
+	}
+	var names []string
+	if o.Config != nil {
+		for name := range o.Config.WarningProfiles {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("there is no warning profile named %s, they are defined in [warnings.NAME] tables in %s", o.Warnings, configFilenames[0])
+	}
+	sort.Strings(names)
+	return fmt.Errorf("there is no warning profile named %s, the profiles are: %s", o.Warnings, strings.Join(names, ", "))
+}
+
+// warningProfile returns the selected warning profile, if it is defined in the configuration file
+func warningProfile(o *Options) (WarningProfile, bool) {
+	if o.Warnings == "" || o.Config == nil {
+		return WarningProfile{}, false
+	}
+	p, ok := o.Config.WarningProfiles[o.Warnings]
+	return p, ok
+}
+
+// applyWarningProfile removes and adds the flags of the selected warning profile
+func applyWarningProfile(o *Options, flags []string) []string {
+	p, ok := warningProfile(o)
+	if !ok {
+		return flags
+	}
+	for _, flag := range p.Remove {
+		flags = removeFromSlice(flags, flag)
+	}
+	return append(flags, p.Add...)
+}
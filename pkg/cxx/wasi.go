@@ -0,0 +1,54 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// wasiSDKPath returns where wasi-sdk is installed, from $WASI_SDK_PATH or the default /opt/wasi-sdk,
+// or "" if it is not installed
+func wasiSDKPath() string {
+	for _, dir := range []string{os.Getenv("WASI_SDK_PATH"), "/opt/wasi-sdk"} {
+		if dir != "" && fileExists(filepath.Join(dir, "bin", "clang++")) {
+			return dir
+		}
+	}
+	return ""
+}
+
+// setupWasi switches to the clang++ of wasi-sdk, with the WASI sysroot, for building .wasm modules.
+// The rest of the cross compilation setup is done by setupCross.
+func setupWasi(o *Options) error {
+	if !o.Wasi {
+		return nil
+	}
+	if o.Wasm || o.Target != "" || o.CrossPreset != "" {
+		return fmt.Errorf("--wasi can not be combined with other cross compilation options")
+	}
+	if o.Shared {
+		return fmt.Errorf("shared libraries are not supported with --wasi, build a static library instead")
+	}
+	sdk := wasiSDKPath()
+	if sdk == "" {
+		return fmt.Errorf("wasi-sdk was not found in /opt/wasi-sdk, install it or set WASI_SDK_PATH")
+	}
+	o.CXX = filepath.Join(sdk, "bin", "clang++")
+	if o.Sysroot == "" {
+		o.Sysroot = filepath.Join(sdk, "share", "wasi-sysroot")
+	}
+	// Newer versions of wasi-sdk name the target after the preview 1 of WASI
+	o.Target = "wasm32-wasi"
+	if dirExists(filepath.Join(o.Sysroot, "include", "wasm32-wasip1")) {
+		o.Target = "wasm32-wasip1"
+	}
+	return nil
+}
+
+// wasiSystemIncludeDirs returns the include directories of the WASI sysroot
+func wasiSystemIncludeDirs(o *Options) []string {
+	return []string{
+		filepath.Join(o.Sysroot, "include", o.Target),
+		filepath.Join(o.Sysroot, "include"),
+	}
+}
@@ -0,0 +1,137 @@
+package cxx
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// emscriptenCompiler returns em++ from PATH or from the Emscripten SDK in $EMSDK, or "" if it is not installed
+func emscriptenCompiler() string {
+	if haveCmd("em++") {
+		return "em++"
+	}
+	if sdk := os.Getenv("EMSDK"); sdk != "" {
+		if p := filepath.Join(sdk, "upstream", "emscripten", "em++"); fileExists(p) {
+			return p
+		}
+	}
+	return ""
+}
+
+// setupWasm switches to em++ for building WebAssembly with Emscripten
+func setupWasm(o *Options) error {
+	if !o.Wasm {
+		return nil
+	}
+	if o.Win64Docker || o.Target != "" || o.CrossPreset != "" {
+		return fmt.Errorf("--wasm can not be combined with other cross compilation options")
+	}
+	if o.Coverage || o.PGO == "gen" {
+		return fmt.Errorf("coverage and pgo-gen are not supported with --wasm")
+	}
+	if o.Shared {
+		return fmt.Errorf("shared libraries are not supported with --wasm, build a static library instead")
+	}
+	em := emscriptenCompiler()
+	if em == "" {
+		return fmt.Errorf("em++ was not found, install the Emscripten SDK and run: source ./emsdk_env.sh")
+	}
+	o.CXX = em
+	if o.Linker == "" || o.Linker == "auto" {
+		// em++ links with wasm-ld
+		o.Linker = "none"
+	}
+	if o.Distributed {
+		fmt.Println("Distributed compilation is not available with --wasm.")
+		o.Distributed = false
+	}
+	return nil
+}
+
+// wasmSystemIncludeDirs returns the include directory of the Emscripten sysroot
+func wasmSystemIncludeDirs(o *Options) []string {
+	emConfig := filepath.Join(filepath.Dir(o.CXX), "em-config")
+	if !fileExists(emConfig) {
+		emConfig = "em-config"
+	}
+	out, err := exec.Command(emConfig, "CACHE").Output()
+	if err != nil {
+		return nil
+	}
+	if inc := filepath.Join(strings.TrimSpace(string(out)), "sysroot", "include"); dirExists(inc) {
+		return []string{inc}
+	}
+	return nil
+}
+
+// wasmOutputName gives the executable a .html extension, unless it already ends with .html or .js.
+// em++ places the .js and .wasm files next to it. With --wasi, the extension is .wasm.
+func wasmOutputName(o *Options, name string) string {
+	if name == "" {
+		return name
+	}
+	if o.Wasi && filepath.Ext(name) != ".wasm" {
+		return name + ".wasm"
+	}
+	if !o.Wasm {
+		return name
+	}
+	switch filepath.Ext(name) {
+	case ".html", ".js":
+		return name
+	}
+	return name + ".html"
+}
+
+// wasmCompanions returns the files that em++ generates together with the given output
+func wasmCompanions(name string) []string {
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	switch filepath.Ext(name) {
+	case ".html":
+		return []string{base + ".js", base + ".wasm"}
+	case ".js":
+		return []string{base + ".wasm"}
+	}
+	return nil
+}
+
+// programCommand returns the command for running the given program, which is emrun for
+// Emscripten .html output, node for Emscripten .js output, wasmtime or wasmer for WASI modules,
+// wine for Windows executables on other platforms and else the executable itself
+func programCommand(exe string) (*exec.Cmd, error) {
+	switch filepath.Ext(exe) {
+	case ".html":
+		if !haveCmd("emrun") {
+			return nil, fmt.Errorf("emrun is needed for running %s", exe)
+		}
+		return exec.Command("emrun", exe), nil
+	case ".js":
+		if !haveCmd("node") {
+			return nil, fmt.Errorf("node is needed for running %s", exe)
+		}
+		return exec.Command("node", exe), nil
+	case ".wasm":
+		// The current directory is made available to the module
+		if haveCmd("wasmtime") {
+			return exec.Command("wasmtime", "--dir=.", exe), nil
+		}
+		if haveCmd("wasmer") {
+			return exec.Command("wasmer", "run", "--dir=.", exe), nil
+		}
+		return nil, fmt.Errorf("wasmtime or wasmer is needed for running %s", exe)
+	case ".exe":
+		if runtime.GOOS == "windows" {
+			break
+		}
+		wine := wineCommand()
+		if wine == "" {
+			return nil, fmt.Errorf("wine is needed for running %s", exe)
+		}
+		return exec.Command(wine, exe), nil
+	}
+	return exec.Command(runnable(exe)), nil
+}
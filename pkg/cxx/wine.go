@@ -0,0 +1,30 @@
+package cxx
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+)
+
+// wineCommand returns wine64 or wine, for running Windows executables on other platforms,
+// or "" if neither is installed
+func wineCommand() string {
+	if runtime.GOOS == "windows" {
+		return ""
+	}
+	for _, wine := range []string{"wine64", "wine"} {
+		if haveCmd(wine) {
+			return wine
+		}
+	}
+	return ""
+}
+
+// ExitCode returns the exit code of the program that the error is from, or 1 for other errors
+func ExitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
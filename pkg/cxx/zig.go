@@ -0,0 +1,72 @@
+package cxx
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// compilerExecutable returns the executable of the compiler, like zig for "zig c++"
+func compilerExecutable(o *Options) string {
+	f := strings.Fields(o.CXX)
+	if len(f) == 0 {
+		return o.CXX
+	}
+	return f[0]
+}
+
+// compilerCommand returns the command for running the compiler with the given arguments,
+// also when the compiler is a command with a subcommand, like "zig c++"
+func compilerCommand(o *Options, args ...string) *exec.Cmd {
+	f := strings.Fields(o.CXX)
+	if len(f) == 0 {
+		return exec.Command(o.CXX, args...)
+	}
+	return exec.Command(f[0], append(f[1:], args...)...)
+}
+
+// isZig checks if the compiler is zig, which is used as "zig c++"
+func isZig(o *Options) bool {
+	return strings.TrimSuffix(filepath.Base(compilerExecutable(o)), ".exe") == "zig"
+}
+
+// setupZig switches to "zig c++" with "zig", or when zig is given as the compiler. zig comes with
+// clang, a linker and the C and C++ standard libraries for many targets, so --target= and --arm64
+// work without any cross toolchain being installed.
+func setupZig(o *Options) error {
+	if !o.Zig && !isZig(o) {
+		return nil
+	}
+	if o.Win64Docker || o.Wasm || o.Wasi || o.Android || o.MSVC {
+		return fmt.Errorf("zig can not be combined with other toolchains, use --target= instead, like --target=x86_64-windows-gnu")
+	}
+	if o.Coverage || o.PGO != "" {
+		return fmt.Errorf("coverage and PGO are not supported with zig")
+	}
+	zig := "zig"
+	if isZig(o) {
+		zig = compilerExecutable(o)
+	}
+	if !haveCmd(zig) {
+		return fmt.Errorf("zig was not found, install it with: %s", installSuggestion(o.DetectedDistro, "zig"))
+	}
+	o.CXX = zig + " c++"
+	if o.Linker == "" || o.Linker == "auto" {
+		// zig links with its own copy of lld
+		o.Linker = "none"
+	}
+	if o.Distributed {
+		fmt.Println("Distributed compilation is not available with zig.")
+		o.Distributed = false
+	}
+	return nil
+}
+
+// zigFlags returns the flags that zig needs instead of the ones that are given to other compilers
+func zigFlags(o *Options) []string {
+	if o.Target == "" {
+		return nil
+	}
+	return []string{"-target", o.Target}
+}